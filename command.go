@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gohbase/config"
+	"gohbase/schema"
+	"gohbase/utils"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// usage 子命令用法
+const usage = `用法:
+  %[1]s [-config 文件] config check                      校验配置并输出生效的配置
+  %[1]s [-config 文件] schema plan  [-compression 算法]  对比线上集群与表结构定义，只输出差异
+  %[1]s [-config 文件] schema apply [-compression 算法]  创建缺少的命名空间、表和列族，并修改列族属性
+  %[1]s [-config 文件] migrate moviedata [-verify-only]  把旧的moviedata表复制到拆分后的表并逐行校验
+`
+
+// runCommand 执行子命令，返回进程退出码
+func runCommand(path string, args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		return checkConfig(path)
+	case len(args) >= 2 && args[0] == "schema" && (args[1] == "plan" || args[1] == "apply"):
+		return schemaCommand(path, args[1] == "apply", args[2:])
+	case len(args) >= 2 && args[0] == "migrate" && args[1] == "moviedata":
+		return migrateCommand(path, args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %v\n\n"+usage, args, os.Args[0])
+		return 2
+	}
+}
+
+// checkConfig 加载并校验配置，校验通过时输出合并环境变量后生效的配置
+func checkConfig(path string) int {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, warning := range config.DeprecatedEnv() {
+		fmt.Fprintln(os.Stderr, "警告: "+warning)
+	}
+
+	out, err := cfg.Marshal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "输出配置失败: %v\n", err)
+		return 1
+	}
+
+	source := "默认值和环境变量"
+	if path != "" {
+		source = path
+	}
+	fmt.Printf("# 配置有效（来源: %s）\n%s", source, out)
+	return 0
+}
+
+// schemaCommand 对比或同步HBase表结构，apply为false时只输出变更计划
+func schemaCommand(path string, apply bool, args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	compression := flags.String("compression", "", "覆盖所有列族的压缩算法，如 GZ、SNAPPY、NONE")
+	timeout := flags.Duration("timeout", 5*time.Minute, "整个命令的超时时间")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := schema.ValidateCompression(*compression); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	admin, err := schema.NewAdmin(&cfg.HBase)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer admin.Close()
+
+	plan, err := schema.BuildPlan(ctx, admin, schema.Desired(cfg.Tables, cfg.WriteGenerator.Namespace, *compression))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取线上表结构失败: %v\n", err)
+		return 1
+	}
+	if !apply || plan.Empty() {
+		plan.Print(os.Stdout)
+		return 0
+	}
+
+	done, err := schema.Apply(ctx, admin, plan, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v（已完成 %d/%d 项）\n", err, done, len(plan.Changes))
+		return 1
+	}
+	fmt.Printf("已完成 %d 项变更\n", done)
+	return 0
+}
+
+// migrateCommand 把moviedata表复制到拆分后的表，完成后逐行校验，存在不一致时返回1
+func migrateCommand(path string, args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	batch := flags.Int("batch", 500, "每批处理的旧表行数，每批完成后保存检查点")
+	workers := flags.Int("workers", 8, "并发写入或校验的协程数")
+	checkpoint := flags.String("checkpoint", "moviedata-migration.json", "检查点文件，中断后重新执行会从检查点继续")
+	restart := flags.Bool("restart", false, "忽略已有的检查点，从头开始迁移")
+	verifyOnly := flags.Bool("verify-only", false, "只校验，不复制数据")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config.Set(cfg)
+	utils.InitResilience(&cfg.Resilience)
+	if err := utils.InitHBase(&cfg.HBase); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer utils.CloseHBase()
+	if !utils.HBaseAvailable() {
+		fmt.Fprintln(os.Stderr, "无法连接HBase")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := utils.MigrationOptions{
+		BatchSize:  *batch,
+		Workers:    *workers,
+		Checkpoint: *checkpoint,
+		Progress:   os.Stdout,
+	}
+
+	if !*verifyOnly {
+		if *restart {
+			if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "删除检查点失败: %v\n", err)
+				return 1
+			}
+		}
+		stats, err := utils.MigrateMovieData(ctx, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "迁移中断: %v（已完成 %d 行，重新执行会从行键 %q 之后继续）\n", err, stats.Rows, stats.LastRow)
+			return 1
+		}
+		fmt.Printf("迁移完成，共 %d 行\n", stats.Rows)
+	}
+
+	report, err := utils.VerifyMovieData(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验中断: %v\n", err)
+		return 1
+	}
+	for _, example := range report.Examples {
+		fmt.Println("! " + example)
+	}
+	if report.Mismatched > 0 {
+		fmt.Fprintf(os.Stderr, "校验失败: 旧表 %d 行对应的新表 %d 行中有 %d 行缺失或不一致\n", report.Rows, report.Checked, report.Mismatched)
+		return 1
+	}
+	fmt.Printf("校验通过: 旧表 %d 行对应的新表 %d 行全部一致\n", report.Rows, report.Checked)
+	return 0
+}
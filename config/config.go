@@ -1,50 +1,635 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config 应用配置
 type Config struct {
-	HBase  HBaseConfig
-	Server ServerConfig
+	Server         ServerConfig         `yaml:"server"`
+	HBase          HBaseConfig          `yaml:"hbase"`
+	Tables         TableConfig          `yaml:"tables"`
+	Cache          CacheConfig          `yaml:"cache"`
+	WriteGenerator WriteGeneratorConfig `yaml:"write_generator"`
+	Limits         LimitConfig          `yaml:"limits"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	Timeouts       TimeoutConfig        `yaml:"timeouts"`
+	CacheControl   CacheControlConfig   `yaml:"cache_control"`
+	Resilience     ResilienceConfig     `yaml:"resilience"`
+	Admin          AdminConfig          `yaml:"admin"`
 }
 
 // HBaseConfig HBase数据库配置
+// 原生RPC方式下HMaster地址由ZooKeeper发现，因此不需要单独配置主机和端口；
+// 只开放了Thrift服务的集群可以把Transport设为thrift，通过Thrift2网关访问
 type HBaseConfig struct {
-	Host       string
-	ZkQuorum   string
-	ZkPort     string
-	MasterPort string
-	ThriftPort string
+	Transport string `yaml:"transport"` // 访问方式：native 原生RPC，thrift 通过Thrift2网关
+
+	ZkQuorum  string        `yaml:"zk_quorum"`  // 逗号分隔的ZooKeeper地址，如 zk1:2181,zk2:2181,zk3
+	ZkPort    string        `yaml:"zk_port"`    // zk_quorum中未写端口的主机使用的端口
+	ZkRoot    string        `yaml:"zk_root"`    // HBase在ZooKeeper中的根节点
+	ZkTimeout time.Duration `yaml:"zk_timeout"` // ZooKeeper会话超时时间
+
+	RegionLookupTimeout time.Duration `yaml:"region_lookup_timeout"` // 查找region位置的超时时间
+	RegionReadTimeout   time.Duration `yaml:"region_read_timeout"`   // 读取region响应的超时时间
+	RPCQueueSize        int           `yaml:"rpc_queue_size"`        // 每个region批量发送的RPC数量
+	FlushInterval       time.Duration `yaml:"flush_interval"`        // RPC批量发送的最长等待时间
+	EffectiveUser       string        `yaml:"effective_user"`        // 以该用户身份访问HBase，为空时使用进程用户
+
+	ProbeTable      string        `yaml:"probe_table"`       // 连接探测读取的表，为空时使用tables.movies
+	ProbeRow        string        `yaml:"probe_row"`         // 连接探测读取的行，行不存在也视为可用
+	ProbeTimeout    time.Duration `yaml:"probe_timeout"`     // 单次连接探测的超时时间
+	ProbeInterval   time.Duration `yaml:"probe_interval"`    // HBase可用时的探测间隔
+	ProbeBackoff    time.Duration `yaml:"probe_backoff"`     // HBase不可用时首次重新探测的等待时间
+	ProbeMaxBackoff time.Duration `yaml:"probe_max_backoff"` // HBase不可用时探测等待时间上限
+
+	ThriftHost     string        `yaml:"thrift_host"`      // Thrift2网关地址，transport为thrift时使用
+	ThriftPort     string        `yaml:"thrift_port"`      // Thrift2网关端口
+	ThriftFramed   bool          `yaml:"thrift_framed"`    // 使用framed传输，需与网关的 -f 启动参数一致
+	ThriftTimeout  time.Duration `yaml:"thrift_timeout"`   // 单次Thrift调用（含建立连接）的超时时间
+	ThriftPoolSize int           `yaml:"thrift_pool_size"` // 保留的空闲Thrift连接数
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string
+	Port            string        `yaml:"port"`
+	LogLevel        string        `yaml:"log_level"`        // 日志级别：debug、info、warn、error
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 关闭时等待请求处理完成和后台任务排空的总时长
+
+	Compression CompressionConfig `yaml:"compression"`
+}
+
+// CompressionConfig 响应压缩，按客户端的 Accept-Encoding 选择brotli或gzip
+type CompressionConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	MinSize     int  `yaml:"min_size"`     // 响应体达到该字节数才压缩，过小的响应压缩后反而更大
+	GzipLevel   int  `yaml:"gzip_level"`   // gzip压缩级别，1-9
+	BrotliLevel int  `yaml:"brotli_level"` // brotli压缩级别，0-11
+}
+
+// TableConfig HBase表名
+// 表名可以写成 namespace:table 指定命名空间，否则使用Namespace
+type TableConfig struct {
+	Namespace    string `yaml:"namespace"` // 表所在的命名空间，为空时使用HBase的default命名空间
+	Movies       string `yaml:"movies"`
+	Links        string `yaml:"links"`
+	AvgRatings   string `yaml:"avg_ratings"`
+	Ratings      string `yaml:"ratings"`
+	MovieRatings string `yaml:"movie_ratings"`
+	Tags         string `yaml:"tags"`
+	MovieData    string `yaml:"movie_data"` // 旧版宽表，只作为 migrate moviedata 命令的数据源
+
+	CacheInvalidations string `yaml:"cache_invalidations"` // 跨实例缓存失效事件，cache.invalidation.bus为hbase时使用
+}
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	DefaultTTL      time.Duration `yaml:"default_ttl"`      // 缓存项默认过期时间
+	CleanupInterval time.Duration `yaml:"cleanup_interval"` // 过期项清理间隔
+	RatingStatsTTL  time.Duration `yaml:"rating_stats_ttl"` // avg_ratings表中统计数据的有效期
+	MaxEntries      int           `yaml:"max_entries"`      // 最大缓存项数，0表示不限制
+	MaxMemoryMB     int           `yaml:"max_memory_mb"`    // 缓存项估算内存上限（MB），0表示不限制
+	StaleTTL        time.Duration `yaml:"stale_ttl"`        // 缓存项过期后仍可提供旧值并在后台刷新的时长，0表示不提供
+
+	// 按键前缀（如 movie_detail、search）区分的缓存策略，文件中写出的前缀整体覆盖默认策略
+	Policies map[string]CachePolicy `yaml:"policies"`
+
+	SnapshotFile     string            `yaml:"snapshot_file"`     // 关闭时保存、启动时恢复缓存快照的文件，为空表示不使用快照
+	SnapshotPrefixes []string          `yaml:"snapshot_prefixes"` // 写入快照的缓存项前缀
+	Warmup           CacheWarmupConfig `yaml:"warmup"`
+
+	Backend string        `yaml:"backend"` // 缓存方式：memory 只使用进程内缓存，tiered 进程内缓存（L1）加Redis共享缓存（L2）
+	L1TTL   time.Duration `yaml:"l1_ttl"`  // tiered方式下进程内缓存项的最长有效期，0表示与共享缓存相同
+	Redis   RedisConfig   `yaml:"redis"`   // 共享缓存使用的Redis，backend为tiered时使用
+
+	Invalidation CacheInvalidationConfig `yaml:"invalidation"`
+}
+
+// CacheInvalidationConfig 跨实例缓存失效广播配置
+// 写入数据的实例发布失效事件，其他实例在 publish_interval + poll_interval 内删除各自进程内的缓存项
+type CacheInvalidationConfig struct {
+	Bus             string        `yaml:"bus"`              // memory 只在进程内广播（单实例部署）；hbase 通过tables.cache_invalidations表广播给所有实例
+	PublishInterval time.Duration `yaml:"publish_interval"` // 合并发布失效事件的间隔
+	PollInterval    time.Duration `yaml:"poll_interval"`    // 读取其他实例发布的事件的间隔
+	MaxClockSkew    time.Duration `yaml:"max_clock_skew"`   // 实例之间允许的时钟偏差，读取时多回看这段时间，避免漏掉时钟较慢的实例发布的事件
+}
+
+// 缓存失效广播方式
+const (
+	InvalidationBusMemory = "memory"
+	InvalidationBusHBase  = "hbase"
+)
+
+// 缓存方式
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendTiered = "tiered"
+)
+
+// RedisConfig Redis（或兼容Redis协议的服务）连接配置
+type RedisConfig struct {
+	Addr      string        `yaml:"addr"`       // 地址，如 localhost:6379
+	Password  string        `yaml:"password"`   // 为空时不认证
+	DB        int           `yaml:"db"`         // 数据库编号
+	KeyPrefix string        `yaml:"key_prefix"` // 所有键的前缀，多个服务共用一个Redis时区分各自的数据
+	PoolSize  int           `yaml:"pool_size"`  // 保留的空闲连接数
+	Timeout   time.Duration `yaml:"timeout"`    // 单次访问（含建立连接）的超时时间，超时按未命中处理
+}
+
+// CacheWarmupConfig 启动时的缓存预热，预热完成前就绪检查不通过
+type CacheWarmupConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TopMovies   int           `yaml:"top_movies"`  // 预热评分人数最多的电影详情和评分统计的数量
+	ListPages   int           `yaml:"list_pages"`  // 预热电影列表（默认每页数量）的前几页
+	Concurrency int           `yaml:"concurrency"` // 同时进行的加载数
+	Timeout     time.Duration `yaml:"timeout"`     // 预热的最长时间，超时后未完成的部分放弃，服务照常就绪
+}
+
+// CachePolicy 一类缓存项的策略
+type CachePolicy struct {
+	TTL        time.Duration `yaml:"ttl"`         // 过期时间，0表示使用cache.default_ttl
+	MaxEntries int           `yaml:"max_entries"` // 该类缓存项的最大数量，0表示只受总容量限制
+	CacheEmpty bool          `yaml:"cache_empty"` // 是否缓存空结果，如不存在的电影、没有匹配的搜索
+	Jitter     float64       `yaml:"jitter"`      // 过期时间随机延长的最大比例，避免同时写入的缓存项同时过期
+}
+
+// MaxBytes 缓存内存上限的字节数
+func (c CacheConfig) MaxBytes() int64 {
+	return int64(c.MaxMemoryMB) << 20
+}
+
+// WriteGeneratorConfig 随机评分写入服务配置
+type WriteGeneratorConfig struct {
+	Interval   time.Duration `yaml:"interval"`     // 每批写入的间隔
+	MinBatch   int           `yaml:"min_batch"`    // 每批最少写入数
+	MaxBatch   int           `yaml:"max_batch"`    // 每批最多写入数
+	Workers    int           `yaml:"workers"`      // 写入工作协程数
+	QueueSize  int           `yaml:"queue_size"`   // 写入队列长度
+	MaxMovieID int           `yaml:"max_movie_id"` // 随机电影ID的上限
+	MaxUserID  int           `yaml:"max_user_id"`  // 随机用户ID的上限
+	Namespace  string        `yaml:"namespace"`    // 随机写入使用的命名空间，为空时与tables.namespace相同
+}
+
+// LimitConfig 接口参数限制
+type LimitConfig struct {
+	DefaultPerPage     int `yaml:"default_per_page"`
+	MaxPerPage         int `yaml:"max_per_page"`
+	DefaultRandomCount int `yaml:"default_random_count"`
+	MaxRandomCount     int `yaml:"max_random_count"`
+	MaxLogLines        int `yaml:"max_log_lines"` // 日志查询和推送的最大条数
+	TotalMovies        int `yaml:"total_movies"`  // 电影总数，用于分页和随机选择
+}
+
+// TimeoutConfig 各接口的请求处理截止时间
+type TimeoutConfig struct {
+	Default      time.Duration `yaml:"default"`       // 未单独配置的接口
+	MovieList    time.Duration `yaml:"movie_list"`    // GET /api/movies
+	MovieDetail  time.Duration `yaml:"movie_detail"`  // GET /api/movies/:id
+	RandomMovies time.Duration `yaml:"random_movies"` // GET/POST /api/movies/random
+	Search       time.Duration `yaml:"search"`        // GET /api/movies/search
+	Ratings      time.Duration `yaml:"ratings"`       // GET /api/ratings/movie/:id
+	Health       time.Duration `yaml:"health"`        // GET /readyz 依赖检查
+}
+
+// CacheControlConfig 各接口响应的 Cache-Control max-age，0表示客户端每次都需要用ETag重新验证（no-cache）
+type CacheControlConfig struct {
+	MovieList    time.Duration `yaml:"movie_list"`    // GET /api/movies
+	MovieDetail  time.Duration `yaml:"movie_detail"`  // GET /api/movies/:id
+	RandomMovies time.Duration `yaml:"random_movies"` // GET /api/movies/random
+	Search       time.Duration `yaml:"search"`        // GET /api/movies/search
+	Ratings      time.Duration `yaml:"ratings"`       // GET /api/ratings/movie/:id
+}
+
+// ResilienceConfig HBase调用的重试与熔断策略，按操作类型分别配置
+type ResilienceConfig struct {
+	Get     RetryConfig   `yaml:"get"`
+	Scan    RetryConfig   `yaml:"scan"` // 仅在扫描尚未返回任何行时重试
+	Put     RetryConfig   `yaml:"put"`  // Put非幂等，默认不重试
+	Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// RetryConfig 指数退避重试策略
+type RetryConfig struct {
+	MaxRetries     int           `yaml:"max_retries"`     // 最大重试次数，0表示不重试
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // 首次重试前的等待时间
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // 单次等待时间上限
+	Multiplier     float64       `yaml:"multiplier"`      // 每次重试等待时间的增长倍数
 }
 
-// GetConfig 获取配置
-func GetConfig() *Config {
+// BreakerConfig 按表熔断的策略
+type BreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"` // 连续失败多少次后熔断
+	OpenTimeout      time.Duration `yaml:"open_timeout"`      // 熔断后多久进入半开状态放行探测请求
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Token string `yaml:"token"` // 管理接口的访问令牌，为空时管理接口不可用
+}
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // 导出方式: none、otlp、stdout、file
+	Endpoint    string  `yaml:"endpoint"`     // OTLP HTTP接收端地址，如 http://localhost:4318
+	FilePath    string  `yaml:"file"`         // file 导出方式的输出文件
+	ServiceName string  `yaml:"service_name"` // 上报的服务名
+	SampleRatio float64 `yaml:"sample_ratio"` // 采样比例，0~1
+}
+
+// Default 获取默认配置
+func Default() *Config {
 	return &Config{
+		Server: ServerConfig{
+			Port:            "5000",
+			LogLevel:        "info",
+			ShutdownTimeout: 15 * time.Second,
+			Compression: CompressionConfig{
+				Enabled:     true,
+				MinSize:     1024,
+				GzipLevel:   6,
+				BrotliLevel: 4,
+			},
+		},
 		HBase: HBaseConfig{
-			Host:       getEnv("HBASE_HOST", "192.168.2.24"),
-			ZkQuorum:   getEnv("HBASE_ZKQUORUM", "192.168.2.24"),
-			ZkPort:     getEnv("HBASE_ZKPORT", "2181"),
-			MasterPort: getEnv("HBASE_MASTERPORT", "16000"),
-			ThriftPort: getEnv("HBASE_THRIFTPORT", "9090"),
+			Transport: TransportNative,
+
+			ZkQuorum:  "localhost",
+			ZkPort:    "2181",
+			ZkRoot:    "/hbase",
+			ZkTimeout: 30 * time.Second,
+
+			RegionLookupTimeout: 30 * time.Second,
+			RegionReadTimeout:   30 * time.Second,
+			RPCQueueSize:        100,
+			FlushInterval:       20 * time.Millisecond,
+
+			ProbeRow:        "1",
+			ProbeTimeout:    5 * time.Second,
+			ProbeInterval:   15 * time.Second,
+			ProbeBackoff:    time.Second,
+			ProbeMaxBackoff: 30 * time.Second,
+
+			ThriftHost:     "localhost",
+			ThriftPort:     "9090",
+			ThriftTimeout:  10 * time.Second,
+			ThriftPoolSize: 8,
 		},
-		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "5000"),
+		Tables: TableConfig{
+			Movies:       "movies",
+			Links:        "links",
+			AvgRatings:   "avg_ratings",
+			Ratings:      "ratings",
+			MovieRatings: "movie_ratings",
+			Tags:         "tags",
+			MovieData:    "moviedata",
+
+			CacheInvalidations: "cache_invalidations",
+		},
+		Cache: CacheConfig{
+			DefaultTTL:      5 * time.Minute,
+			CleanupInterval: 10 * time.Minute,
+			RatingStatsTTL:  24 * time.Hour,
+			MaxEntries:      10000,
+			MaxMemoryMB:     256,
+			StaleTTL:        time.Minute,
+			Policies: map[string]CachePolicy{
+				"movie_detail":       {TTL: 10 * time.Minute, MaxEntries: 5000, CacheEmpty: true, Jitter: 0.1},
+				"movie_rating_stats": {TTL: 5 * time.Minute, MaxEntries: 5000, Jitter: 0.1},
+				"movie_tags":         {TTL: 10 * time.Minute, MaxEntries: 5000, CacheEmpty: true, Jitter: 0.1},
+				"movies_by_rating":   {TTL: 5 * time.Minute, MaxEntries: 200, CacheEmpty: true, Jitter: 0.1},
+				"scan_movies":        {TTL: 10 * time.Minute, MaxEntries: 1000, CacheEmpty: true, Jitter: 0.1},
+				"search":             {TTL: 30 * time.Minute, MaxEntries: 1000, CacheEmpty: true, Jitter: 0.1},
+				"random_movies":      {TTL: time.Hour, MaxEntries: 100},
+			},
+			SnapshotPrefixes: []string{"movie_detail", "movie_rating_stats", "movie_tags", "scan_movies"},
+			Warmup: CacheWarmupConfig{
+				TopMovies:   100,
+				ListPages:   3,
+				Concurrency: 4,
+				Timeout:     2 * time.Minute,
+			},
+			Backend: CacheBackendMemory,
+			L1TTL:   30 * time.Second,
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "movieapi:",
+				PoolSize:  16,
+				Timeout:   200 * time.Millisecond,
+			},
+			Invalidation: CacheInvalidationConfig{
+				Bus:             InvalidationBusMemory,
+				PublishInterval: 100 * time.Millisecond,
+				PollInterval:    time.Second,
+				MaxClockSkew:    5 * time.Second,
+			},
+		},
+		WriteGenerator: WriteGeneratorConfig{
+			Interval:   3 * time.Second,
+			MinBatch:   1,
+			MaxBatch:   5,
+			Workers:    5,
+			QueueSize:  20,
+			MaxMovieID: 100,
+			MaxUserID:  1000,
 		},
+		Limits: LimitConfig{
+			DefaultPerPage:     12,
+			MaxPerPage:         50,
+			DefaultRandomCount: 6,
+			MaxRandomCount:     20,
+			MaxLogLines:        500,
+			TotalMovies:        9742,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			FilePath:    "traces.json",
+			ServiceName: "movie-api",
+			SampleRatio: 1.0,
+		},
+		Timeouts: TimeoutConfig{
+			Default:      10 * time.Second,
+			MovieList:    5 * time.Second,
+			MovieDetail:  3 * time.Second,
+			RandomMovies: 5 * time.Second,
+			Search:       15 * time.Second,
+			Ratings:      10 * time.Second,
+			Health:       3 * time.Second,
+		},
+		CacheControl: CacheControlConfig{
+			MovieList:    time.Minute,
+			MovieDetail:  time.Minute,
+			RandomMovies: 0,
+			Search:       30 * time.Second,
+			Ratings:      30 * time.Second,
+		},
+		Resilience: ResilienceConfig{
+			Get:  defaultRetryConfig(3),
+			Scan: defaultRetryConfig(2),
+			Put:  defaultRetryConfig(0),
+			Breaker: BreakerConfig{
+				FailureThreshold: 5,
+				OpenTimeout:      30 * time.Second,
+			},
+		},
+	}
+}
+
+// Names 返回逻辑表名（与配置文件中的键一致）到配置表名的映射
+func (t TableConfig) Names() map[string]string {
+	return map[string]string{
+		"movies":        t.Movies,
+		"links":         t.Links,
+		"avg_ratings":   t.AvgRatings,
+		"ratings":       t.Ratings,
+		"movie_ratings": t.MovieRatings,
+		"tags":          t.Tags,
+		"movie_data":    t.MovieData,
+
+		"cache_invalidations": t.CacheInvalidations,
+	}
+}
+
+// Qualified 返回带命名空间的表名，namespace为空时使用t.Namespace
+// 已经写明命名空间的表名保持不变，default命名空间不加前缀
+func (t TableConfig) Qualified(namespace string) TableConfig {
+	if namespace == "" {
+		namespace = t.Namespace
+	}
+	qualify := func(name string) string {
+		if namespace == "" || namespace == "default" || strings.Contains(name, ":") {
+			return name
+		}
+		return namespace + ":" + name
 	}
+	return TableConfig{
+		Namespace:    namespace,
+		Movies:       qualify(t.Movies),
+		Links:        qualify(t.Links),
+		AvgRatings:   qualify(t.AvgRatings),
+		Ratings:      qualify(t.Ratings),
+		MovieRatings: qualify(t.MovieRatings),
+		Tags:         qualify(t.Tags),
+		MovieData:    qualify(t.MovieData),
+
+		CacheInvalidations: qualify(t.CacheInvalidations),
+	}
+}
+
+// HBase访问方式
+const (
+	TransportNative = "native"
+	TransportThrift = "thrift"
+)
+
+// ThriftAddr Thrift2网关的地址
+func (h HBaseConfig) ThriftAddr() string {
+	return net.JoinHostPort(h.ThriftHost, h.ThriftPort)
+}
+
+// Quorum 构建ZooKeeper连接字符串，未写端口的主机补上ZkPort
+func (h HBaseConfig) Quorum() string {
+	hosts := strings.Split(h.ZkQuorum, ",")
+	for i, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host != "" && !strings.Contains(host, ":") {
+			host = host + ":" + h.ZkPort
+		}
+		hosts[i] = host
+	}
+	return strings.Join(hosts, ",")
+}
+
+// removedEnv 已经移除的环境变量及原因
+var removedEnv = map[string]string{
+	"HBASE_HOST":       "请使用 HBASE_ZKQUORUM 配置ZooKeeper地址",
+	"HBASE_MASTERPORT": "HMaster地址由ZooKeeper发现",
+}
+
+// DeprecatedEnv 返回仍被设置但已不再生效的环境变量提示
+func DeprecatedEnv() []string {
+	var warnings []string
+	for _, key := range sortedKeys(removedEnv) {
+		if os.Getenv(key) != "" {
+			warnings = append(warnings, fmt.Sprintf("环境变量 %s 已不再使用：%s", key, removedEnv[key]))
+		}
+	}
+	return warnings
+}
+
+// defaultRetryConfig 默认的重试退避参数
+func defaultRetryConfig(maxRetries int) RetryConfig {
+	return RetryConfig{
+		MaxRetries:     maxRetries,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2.0,
+	}
+}
+
+// applyEnv 使用环境变量覆盖配置，未设置的环境变量保持原值，返回无法解析的环境变量
+func (c *Config) applyEnv() []string {
+	env := &envReader{}
+
+	c.Server.Port = env.str("SERVER_PORT", c.Server.Port)
+	c.Server.LogLevel = env.str("LOG_LEVEL", c.Server.LogLevel)
+	c.Server.ShutdownTimeout = env.duration("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	c.Server.Compression.Enabled = env.bool("COMPRESSION_ENABLED", c.Server.Compression.Enabled)
+	c.Server.Compression.MinSize = env.int("COMPRESSION_MIN_SIZE", c.Server.Compression.MinSize)
+
+	c.Tables.Namespace = env.str("TABLE_NAMESPACE", c.Tables.Namespace)
+	c.WriteGenerator.Namespace = env.str("WRITE_GENERATOR_NAMESPACE", c.WriteGenerator.Namespace)
+
+	c.HBase.Transport = env.str("HBASE_TRANSPORT", c.HBase.Transport)
+	c.HBase.ZkQuorum = env.str("HBASE_ZKQUORUM", c.HBase.ZkQuorum)
+	c.HBase.ZkPort = env.str("HBASE_ZKPORT", c.HBase.ZkPort)
+	c.HBase.ZkRoot = env.str("HBASE_ZKROOT", c.HBase.ZkRoot)
+	c.HBase.ZkTimeout = env.duration("HBASE_ZK_TIMEOUT", c.HBase.ZkTimeout)
+	c.HBase.RegionLookupTimeout = env.duration("HBASE_REGION_LOOKUP_TIMEOUT", c.HBase.RegionLookupTimeout)
+	c.HBase.RegionReadTimeout = env.duration("HBASE_REGION_READ_TIMEOUT", c.HBase.RegionReadTimeout)
+	c.HBase.RPCQueueSize = env.int("HBASE_RPC_QUEUE_SIZE", c.HBase.RPCQueueSize)
+	c.HBase.FlushInterval = env.duration("HBASE_FLUSH_INTERVAL", c.HBase.FlushInterval)
+	c.HBase.EffectiveUser = env.str("HBASE_EFFECTIVE_USER", c.HBase.EffectiveUser)
+	c.HBase.ProbeTable = env.str("HBASE_PROBE_TABLE", c.HBase.ProbeTable)
+	c.HBase.ProbeRow = env.str("HBASE_PROBE_ROW", c.HBase.ProbeRow)
+	c.HBase.ProbeTimeout = env.duration("HBASE_PROBE_TIMEOUT", c.HBase.ProbeTimeout)
+	c.HBase.ProbeInterval = env.duration("HBASE_PROBE_INTERVAL", c.HBase.ProbeInterval)
+	c.HBase.ProbeBackoff = env.duration("HBASE_PROBE_BACKOFF", c.HBase.ProbeBackoff)
+	c.HBase.ProbeMaxBackoff = env.duration("HBASE_PROBE_MAX_BACKOFF", c.HBase.ProbeMaxBackoff)
+	c.HBase.ThriftHost = env.str("HBASE_THRIFTHOST", c.HBase.ThriftHost)
+	c.HBase.ThriftPort = env.str("HBASE_THRIFTPORT", c.HBase.ThriftPort)
+	c.HBase.ThriftFramed = env.bool("HBASE_THRIFT_FRAMED", c.HBase.ThriftFramed)
+	c.HBase.ThriftTimeout = env.duration("HBASE_THRIFT_TIMEOUT", c.HBase.ThriftTimeout)
+	c.HBase.ThriftPoolSize = env.int("HBASE_THRIFT_POOL_SIZE", c.HBase.ThriftPoolSize)
+
+	c.Cache.DefaultTTL = env.duration("CACHE_DEFAULT_TTL", c.Cache.DefaultTTL)
+	c.Cache.CleanupInterval = env.duration("CACHE_CLEANUP_INTERVAL", c.Cache.CleanupInterval)
+	c.Cache.RatingStatsTTL = env.duration("CACHE_RATING_STATS_TTL", c.Cache.RatingStatsTTL)
+	c.Cache.MaxEntries = env.int("CACHE_MAX_ENTRIES", c.Cache.MaxEntries)
+	c.Cache.MaxMemoryMB = env.int("CACHE_MAX_MEMORY_MB", c.Cache.MaxMemoryMB)
+	c.Cache.StaleTTL = env.duration("CACHE_STALE_TTL", c.Cache.StaleTTL)
+	c.Cache.SnapshotFile = env.str("CACHE_SNAPSHOT_FILE", c.Cache.SnapshotFile)
+	c.Cache.Warmup.Enabled = env.bool("CACHE_WARMUP_ENABLED", c.Cache.Warmup.Enabled)
+	c.Cache.Warmup.TopMovies = env.int("CACHE_WARMUP_TOP_MOVIES", c.Cache.Warmup.TopMovies)
+	c.Cache.Warmup.ListPages = env.int("CACHE_WARMUP_LIST_PAGES", c.Cache.Warmup.ListPages)
+	c.Cache.Backend = env.str("CACHE_BACKEND", c.Cache.Backend)
+	c.Cache.L1TTL = env.duration("CACHE_L1_TTL", c.Cache.L1TTL)
+	c.Cache.Redis.Addr = env.str("REDIS_ADDR", c.Cache.Redis.Addr)
+	c.Cache.Redis.Password = env.str("REDIS_PASSWORD", c.Cache.Redis.Password)
+	c.Cache.Redis.DB = env.int("REDIS_DB", c.Cache.Redis.DB)
+	c.Cache.Redis.KeyPrefix = env.str("REDIS_KEY_PREFIX", c.Cache.Redis.KeyPrefix)
+	c.Cache.Invalidation.Bus = env.str("CACHE_INVALIDATION_BUS", c.Cache.Invalidation.Bus)
+	c.Cache.Invalidation.PollInterval = env.duration("CACHE_INVALIDATION_POLL_INTERVAL", c.Cache.Invalidation.PollInterval)
+
+	c.Admin.Token = env.str("ADMIN_TOKEN", c.Admin.Token)
+
+	c.Tracing.Exporter = env.str("TRACING_EXPORTER", c.Tracing.Exporter)
+	c.Tracing.Endpoint = env.str("TRACING_ENDPOINT", c.Tracing.Endpoint)
+	c.Tracing.FilePath = env.str("TRACING_FILE", c.Tracing.FilePath)
+	c.Tracing.ServiceName = env.str("TRACING_SERVICE_NAME", c.Tracing.ServiceName)
+	c.Tracing.SampleRatio = env.float("TRACING_SAMPLE_RATIO", c.Tracing.SampleRatio)
+
+	c.Timeouts.Default = env.duration("TIMEOUT_DEFAULT", c.Timeouts.Default)
+	c.Timeouts.MovieList = env.duration("TIMEOUT_MOVIE_LIST", c.Timeouts.MovieList)
+	c.Timeouts.MovieDetail = env.duration("TIMEOUT_MOVIE_DETAIL", c.Timeouts.MovieDetail)
+	c.Timeouts.RandomMovies = env.duration("TIMEOUT_RANDOM_MOVIES", c.Timeouts.RandomMovies)
+	c.Timeouts.Search = env.duration("TIMEOUT_SEARCH", c.Timeouts.Search)
+	c.Timeouts.Ratings = env.duration("TIMEOUT_RATINGS", c.Timeouts.Ratings)
+	c.Timeouts.Health = env.duration("TIMEOUT_HEALTH", c.Timeouts.Health)
+
+	c.CacheControl.MovieList = env.duration("CACHE_CONTROL_MOVIE_LIST", c.CacheControl.MovieList)
+	c.CacheControl.MovieDetail = env.duration("CACHE_CONTROL_MOVIE_DETAIL", c.CacheControl.MovieDetail)
+	c.CacheControl.RandomMovies = env.duration("CACHE_CONTROL_RANDOM_MOVIES", c.CacheControl.RandomMovies)
+	c.CacheControl.Search = env.duration("CACHE_CONTROL_SEARCH", c.CacheControl.Search)
+	c.CacheControl.Ratings = env.duration("CACHE_CONTROL_RATINGS", c.CacheControl.Ratings)
+
+	env.retry("GET", &c.Resilience.Get)
+	env.retry("SCAN", &c.Resilience.Scan)
+	env.retry("PUT", &c.Resilience.Put)
+	c.Resilience.Breaker.FailureThreshold = env.int("BREAKER_FAILURE_THRESHOLD", c.Resilience.Breaker.FailureThreshold)
+	c.Resilience.Breaker.OpenTimeout = env.duration("BREAKER_OPEN_TIMEOUT", c.Resilience.Breaker.OpenTimeout)
+
+	return env.problems
+}
+
+// envReader 读取环境变量，记录格式错误的值
+type envReader struct {
+	problems []string
 }
 
-// getEnv 获取环境变量，若环境变量不存在则返回默认值
-func getEnv(key, defaultValue string) string {
+// str 获取环境变量，若环境变量不存在则返回默认值
+func (e *envReader) str(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
 	return value
 }
+
+// float 获取浮点型环境变量
+func (e *envReader) float(key string, defaultValue float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("环境变量 %s=%q 不是有效的数字", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// duration 获取时长型环境变量（如 5s、1m）
+func (e *envReader) duration(key string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("环境变量 %s=%q 不是有效的时长，应形如 5s、1m", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// int 获取整型环境变量
+func (e *envReader) int(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("环境变量 %s=%q 不是有效的整数", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// bool 获取布尔型环境变量（true/false/1/0）
+func (e *envReader) bool(key string, defaultValue bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("环境变量 %s=%q 不是有效的布尔值，应为 true 或 false", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// retry 读取指定操作类型的重试策略，如 RETRY_GET_MAX、RETRY_GET_BACKOFF
+func (e *envReader) retry(op string, conf *RetryConfig) {
+	conf.MaxRetries = e.int("RETRY_"+op+"_MAX", conf.MaxRetries)
+	conf.InitialBackoff = e.duration("RETRY_"+op+"_BACKOFF", conf.InitialBackoff)
+	conf.MaxBackoff = e.duration("RETRY_"+op+"_MAX_BACKOFF", conf.MaxBackoff)
+	conf.Multiplier = e.float("RETRY_"+op+"_MULTIPLIER", conf.Multiplier)
+}
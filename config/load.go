@@ -0,0 +1,469 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath 未指定配置文件时尝试读取的文件
+const DefaultPath = "config.yaml"
+
+// ValidationError 配置校验失败，包含所有发现的问题
+type ValidationError struct {
+	Problems []string
+}
+
+// Error 逐行列出所有问题
+func (e *ValidationError) Error() string {
+	return "配置无效:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// current 当前生效的配置
+var current atomic.Pointer[Config]
+
+// Current 获取当前生效的配置，未加载时返回默认配置
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Default()
+}
+
+// Set 设置当前生效的配置
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// ResolvePath 确定配置文件路径：优先使用参数，其次是CONFIG_FILE环境变量，最后是存在的config.yaml
+func ResolvePath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv("CONFIG_FILE"); env != "" {
+		return env
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	return ""
+}
+
+// Load 加载配置：默认值 → 配置文件（可选）→ 环境变量，然后严格校验
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := c.applyEnv()
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return c, nil
+}
+
+// loadFile 读取YAML配置文件，出现未知字段时报错
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件 %s 失败: %v", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// validate 检查配置取值，返回所有问题
+func (c *Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	positive := func(name string, d time.Duration) {
+		if d <= 0 {
+			add("%s 必须大于0，当前为 %v", name, d)
+		}
+	}
+	atLeast := func(name string, value, min int) {
+		if value < min {
+			add("%s 不能小于 %d，当前为 %d", name, min, value)
+		}
+	}
+
+	// server
+	if !validPort(c.Server.Port) {
+		add("server.port 必须是1-65535之间的端口号，当前为 %q", c.Server.Port)
+	}
+	switch strings.ToLower(c.Server.LogLevel) {
+	case "trace", "debug", "info", "warn", "warning", "error":
+	default:
+		add("server.log_level 必须是 trace、debug、info、warn、error 之一，当前为 %q", c.Server.LogLevel)
+	}
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	atLeast("server.compression.min_size", c.Server.Compression.MinSize, 0)
+	if c.Server.Compression.GzipLevel < 1 || c.Server.Compression.GzipLevel > 9 {
+		add("server.compression.gzip_level 必须在1到9之间，当前为 %d", c.Server.Compression.GzipLevel)
+	}
+	if c.Server.Compression.BrotliLevel < 0 || c.Server.Compression.BrotliLevel > 11 {
+		add("server.compression.brotli_level 必须在0到11之间，当前为 %d", c.Server.Compression.BrotliLevel)
+	}
+
+	// hbase
+	switch c.HBase.Transport {
+	case TransportNative:
+	case TransportThrift:
+		if strings.TrimSpace(c.HBase.ThriftHost) == "" {
+			add("hbase.transport 为 thrift 时 hbase.thrift_host 不能为空")
+		}
+		if !validPort(c.HBase.ThriftPort) {
+			add("hbase.thrift_port 必须是1-65535之间的端口号，当前为 %q", c.HBase.ThriftPort)
+		}
+		positive("hbase.thrift_timeout", c.HBase.ThriftTimeout)
+		atLeast("hbase.thrift_pool_size", c.HBase.ThriftPoolSize, 1)
+	default:
+		add("hbase.transport 必须是 native 或 thrift，当前为 %q", c.HBase.Transport)
+	}
+	if strings.TrimSpace(c.HBase.ZkQuorum) == "" {
+		add("hbase.zk_quorum 不能为空")
+	}
+	for _, host := range strings.Split(c.HBase.ZkQuorum, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			add("hbase.zk_quorum 中有空的主机地址: %q", c.HBase.ZkQuorum)
+			continue
+		}
+		if name, port, ok := strings.Cut(host, ":"); ok && (name == "" || !validPort(port)) {
+			add("hbase.zk_quorum 中的地址 %q 无效，应形如 host 或 host:2181", host)
+		}
+	}
+	if !validPort(c.HBase.ZkPort) {
+		add("hbase.zk_port 必须是1-65535之间的端口号，当前为 %q", c.HBase.ZkPort)
+	}
+	if !strings.HasPrefix(c.HBase.ZkRoot, "/") {
+		add("hbase.zk_root 必须以 / 开头，当前为 %q", c.HBase.ZkRoot)
+	}
+	positive("hbase.zk_timeout", c.HBase.ZkTimeout)
+	positive("hbase.region_lookup_timeout", c.HBase.RegionLookupTimeout)
+	positive("hbase.region_read_timeout", c.HBase.RegionReadTimeout)
+	atLeast("hbase.rpc_queue_size", c.HBase.RPCQueueSize, 1)
+	if c.HBase.FlushInterval < 0 {
+		add("hbase.flush_interval 不能为负数")
+	}
+	if c.HBase.ProbeRow == "" {
+		add("hbase.probe_row 不能为空")
+	}
+	positive("hbase.probe_timeout", c.HBase.ProbeTimeout)
+	positive("hbase.probe_interval", c.HBase.ProbeInterval)
+	positive("hbase.probe_backoff", c.HBase.ProbeBackoff)
+	if c.HBase.ProbeMaxBackoff < c.HBase.ProbeBackoff {
+		add("hbase.probe_max_backoff (%v) 不能小于 hbase.probe_backoff (%v)", c.HBase.ProbeMaxBackoff, c.HBase.ProbeBackoff)
+	}
+
+	// tables
+	if c.Tables.Namespace != "" && !namespacePattern.MatchString(c.Tables.Namespace) {
+		add("tables.namespace 只能包含字母、数字和下划线，当前为 %q", c.Tables.Namespace)
+	}
+	if c.WriteGenerator.Namespace != "" && !namespacePattern.MatchString(c.WriteGenerator.Namespace) {
+		add("write_generator.namespace 只能包含字母、数字和下划线，当前为 %q", c.WriteGenerator.Namespace)
+	}
+	tables := c.Tables.Names()
+	for _, key := range sortedKeys(tables) {
+		name := tables[key]
+		if strings.TrimSpace(name) == "" {
+			add("tables.%s 不能为空", key)
+			continue
+		}
+		ns, table, qualified := strings.Cut(name, ":")
+		if !qualified {
+			ns, table = "", name
+		}
+		if (qualified && !namespacePattern.MatchString(ns)) || !tablePattern.MatchString(table) {
+			add("tables.%s 的表名 %q 无效，应形如 table 或 namespace:table", key, name)
+		}
+	}
+
+	// cache
+	positive("cache.default_ttl", c.Cache.DefaultTTL)
+	if c.Cache.CleanupInterval < 0 {
+		add("cache.cleanup_interval 不能为负数，设为0表示不清理")
+	}
+	positive("cache.rating_stats_ttl", c.Cache.RatingStatsTTL)
+	atLeast("cache.max_entries", c.Cache.MaxEntries, 0)
+	atLeast("cache.max_memory_mb", c.Cache.MaxMemoryMB, 0)
+	if c.Cache.StaleTTL < 0 {
+		add("cache.stale_ttl 不能为负数，设为0表示不提供过期的旧值")
+	}
+	prefixes := make([]string, 0, len(c.Cache.Policies))
+	for prefix := range c.Cache.Policies {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		policy := c.Cache.Policies[prefix]
+		name := "cache.policies." + prefix
+		if prefix == "" || strings.Contains(prefix, ":") {
+			add("%s: 键前缀不能为空或包含冒号", name)
+		}
+		if policy.TTL < 0 {
+			add("%s.ttl 不能为负数，设为0表示使用 cache.default_ttl", name)
+		}
+		atLeast(name+".max_entries", policy.MaxEntries, 0)
+		if policy.Jitter < 0 || policy.Jitter >= 1 {
+			add("%s.jitter 应在 [0, 1) 之间，当前为 %v", name, policy.Jitter)
+		}
+	}
+
+	for _, prefix := range c.Cache.SnapshotPrefixes {
+		if prefix == "" || strings.Contains(prefix, ":") {
+			add("cache.snapshot_prefixes 中的前缀 %q 无效，不能为空或包含冒号", prefix)
+		}
+	}
+	atLeast("cache.warmup.top_movies", c.Cache.Warmup.TopMovies, 0)
+	atLeast("cache.warmup.list_pages", c.Cache.Warmup.ListPages, 0)
+	if c.Cache.Warmup.Enabled {
+		atLeast("cache.warmup.concurrency", c.Cache.Warmup.Concurrency, 1)
+		positive("cache.warmup.timeout", c.Cache.Warmup.Timeout)
+	}
+
+	switch c.Cache.Backend {
+	case CacheBackendMemory:
+	case CacheBackendTiered:
+		if host, port, err := net.SplitHostPort(c.Cache.Redis.Addr); err != nil || host == "" || !validPort(port) {
+			add("cache.redis.addr 应形如 host:6379，当前为 %q", c.Cache.Redis.Addr)
+		}
+		atLeast("cache.redis.db", c.Cache.Redis.DB, 0)
+		atLeast("cache.redis.pool_size", c.Cache.Redis.PoolSize, 1)
+		positive("cache.redis.timeout", c.Cache.Redis.Timeout)
+		if c.Cache.L1TTL < 0 {
+			add("cache.l1_ttl 不能为负数，设为0表示与共享缓存相同")
+		}
+	default:
+		add("cache.backend 必须是 memory 或 tiered，当前为 %q", c.Cache.Backend)
+	}
+	if bus := c.Cache.Invalidation.Bus; bus != InvalidationBusMemory && bus != InvalidationBusHBase {
+		add("cache.invalidation.bus 必须是 memory 或 hbase，当前为 %q", bus)
+	}
+	positive("cache.invalidation.publish_interval", c.Cache.Invalidation.PublishInterval)
+	positive("cache.invalidation.poll_interval", c.Cache.Invalidation.PollInterval)
+	if c.Cache.Invalidation.MaxClockSkew < 0 {
+		add("cache.invalidation.max_clock_skew 不能为负数")
+	}
+
+	// write_generator
+	positive("write_generator.interval", c.WriteGenerator.Interval)
+	atLeast("write_generator.min_batch", c.WriteGenerator.MinBatch, 1)
+	if c.WriteGenerator.MaxBatch < c.WriteGenerator.MinBatch {
+		add("write_generator.max_batch (%d) 不能小于 write_generator.min_batch (%d)", c.WriteGenerator.MaxBatch, c.WriteGenerator.MinBatch)
+	}
+	atLeast("write_generator.workers", c.WriteGenerator.Workers, 1)
+	atLeast("write_generator.queue_size", c.WriteGenerator.QueueSize, 1)
+	atLeast("write_generator.max_movie_id", c.WriteGenerator.MaxMovieID, 1)
+	atLeast("write_generator.max_user_id", c.WriteGenerator.MaxUserID, 1)
+
+	// limits
+	atLeast("limits.default_per_page", c.Limits.DefaultPerPage, 1)
+	if c.Limits.MaxPerPage < c.Limits.DefaultPerPage {
+		add("limits.max_per_page (%d) 不能小于 limits.default_per_page (%d)", c.Limits.MaxPerPage, c.Limits.DefaultPerPage)
+	}
+	atLeast("limits.default_random_count", c.Limits.DefaultRandomCount, 1)
+	if c.Limits.MaxRandomCount < c.Limits.DefaultRandomCount {
+		add("limits.max_random_count (%d) 不能小于 limits.default_random_count (%d)", c.Limits.MaxRandomCount, c.Limits.DefaultRandomCount)
+	}
+	atLeast("limits.max_log_lines", c.Limits.MaxLogLines, 1)
+	atLeast("limits.total_movies", c.Limits.TotalMovies, 1)
+
+	// tracing
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint != "" {
+			if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				add("tracing.endpoint 必须是完整的URL，如 http://localhost:4318，当前为 %q", c.Tracing.Endpoint)
+			}
+		}
+	case "file":
+		if c.Tracing.FilePath == "" {
+			add("tracing.exporter 为 file 时 tracing.file 不能为空")
+		}
+	default:
+		add("tracing.exporter 必须是 none、otlp、stdout、file 之一，当前为 %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio 必须在0到1之间，当前为 %v", c.Tracing.SampleRatio)
+	}
+
+	// timeouts，0表示不设置截止时间
+	timeouts := map[string]time.Duration{
+		"timeouts.default":       c.Timeouts.Default,
+		"timeouts.movie_list":    c.Timeouts.MovieList,
+		"timeouts.movie_detail":  c.Timeouts.MovieDetail,
+		"timeouts.random_movies": c.Timeouts.RandomMovies,
+		"timeouts.search":        c.Timeouts.Search,
+		"timeouts.ratings":       c.Timeouts.Ratings,
+		"timeouts.health":        c.Timeouts.Health,
+	}
+	for _, name := range sortedKeys(timeouts) {
+		if timeouts[name] < 0 {
+			add("%s 不能为负数，设为0表示不限制", name)
+		}
+	}
+
+	// cache_control，0表示每次重新验证
+	maxAges := map[string]time.Duration{
+		"cache_control.movie_list":    c.CacheControl.MovieList,
+		"cache_control.movie_detail":  c.CacheControl.MovieDetail,
+		"cache_control.random_movies": c.CacheControl.RandomMovies,
+		"cache_control.search":        c.CacheControl.Search,
+		"cache_control.ratings":       c.CacheControl.Ratings,
+	}
+	for _, name := range sortedKeys(maxAges) {
+		if maxAges[name] < 0 {
+			add("%s 不能为负数，设为0表示每次重新验证", name)
+		}
+	}
+
+	// resilience
+	for _, op := range []struct {
+		name string
+		conf RetryConfig
+	}{
+		{"resilience.get", c.Resilience.Get},
+		{"resilience.scan", c.Resilience.Scan},
+		{"resilience.put", c.Resilience.Put},
+	} {
+		if op.conf.MaxRetries < 0 {
+			add("%s.max_retries 不能为负数", op.name)
+		}
+		if op.conf.MaxRetries > 0 {
+			positive(op.name+".initial_backoff", op.conf.InitialBackoff)
+			if op.conf.MaxBackoff < op.conf.InitialBackoff {
+				add("%s.max_backoff (%v) 不能小于 initial_backoff (%v)", op.name, op.conf.MaxBackoff, op.conf.InitialBackoff)
+			}
+			if op.conf.Multiplier < 1 {
+				add("%s.multiplier 不能小于1，当前为 %v", op.name, op.conf.Multiplier)
+			}
+		}
+	}
+	if c.Resilience.Breaker.FailureThreshold < 0 {
+		add("resilience.breaker.failure_threshold 不能为负数，设为0表示关闭熔断")
+	}
+	if c.Resilience.Breaker.FailureThreshold > 0 {
+		positive("resilience.breaker.open_timeout", c.Resilience.Breaker.OpenTimeout)
+	}
+
+	// admin，为空表示不开放管理接口
+	if token := c.Admin.Token; token != "" && len(token) < minAdminTokenLength {
+		add("admin.token 不能少于 %d 个字符，当前为 %d 个", minAdminTokenLength, len(token))
+	}
+
+	return problems
+}
+
+// minAdminTokenLength 管理令牌的最短长度
+const minAdminTokenLength = 16
+
+// HBase命名空间和表名允许的字符
+var (
+	namespacePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	tablePattern     = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+)
+
+// validPort 检查端口号
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// sortedKeys 按字母顺序返回键，保证错误信息的顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reload 应用重新加载的配置
+// 只有运行时可以安全修改的设置会生效，其余设置保留旧值，返回需要重启才能生效的配置项
+func Reload(next *Config) []string {
+	prev := Current()
+	applied := *next
+
+	var restartRequired []string
+	keep := func(name string, changed bool) {
+		if changed {
+			restartRequired = append(restartRequired, name)
+		}
+	}
+
+	keep("server.port", prev.Server.Port != next.Server.Port)
+	applied.Server.Port = prev.Server.Port
+	keep("hbase", prev.HBase != next.HBase)
+	applied.HBase = prev.HBase
+	keep("tables", prev.Tables != next.Tables)
+	applied.Tables = prev.Tables
+	keep("tracing", prev.Tracing != next.Tracing)
+	applied.Tracing = prev.Tracing
+	keep("timeouts", prev.Timeouts != next.Timeouts)
+	applied.Timeouts = prev.Timeouts
+	keep("cache.cleanup_interval", prev.Cache.CleanupInterval != next.Cache.CleanupInterval)
+	applied.Cache.CleanupInterval = prev.Cache.CleanupInterval
+	keep("cache.warmup", prev.Cache.Warmup != next.Cache.Warmup)
+	applied.Cache.Warmup = prev.Cache.Warmup
+	keep("cache.backend", prev.Cache.Backend != next.Cache.Backend)
+	applied.Cache.Backend = prev.Cache.Backend
+	keep("cache.l1_ttl", prev.Cache.L1TTL != next.Cache.L1TTL)
+	applied.Cache.L1TTL = prev.Cache.L1TTL
+	keep("cache.redis", prev.Cache.Redis != next.Cache.Redis)
+	applied.Cache.Redis = prev.Cache.Redis
+	keep("cache.invalidation", prev.Cache.Invalidation != next.Cache.Invalidation)
+	applied.Cache.Invalidation = prev.Cache.Invalidation
+	keep("write_generator.workers", prev.WriteGenerator.Workers != next.WriteGenerator.Workers)
+	applied.WriteGenerator.Workers = prev.WriteGenerator.Workers
+	keep("write_generator.queue_size", prev.WriteGenerator.QueueSize != next.WriteGenerator.QueueSize)
+	applied.WriteGenerator.QueueSize = prev.WriteGenerator.QueueSize
+
+	Set(&applied)
+	return restartRequired
+}
+
+// Marshal 将配置序列化为YAML，用于展示生效的配置，管理令牌和密码不输出原文
+func (c *Config) Marshal() ([]byte, error) {
+	redacted := *c
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "******"
+	}
+	if redacted.Cache.Redis.Password != "" {
+		redacted.Cache.Redis.Password = "******"
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redacted); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
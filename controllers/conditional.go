@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gohbase/middleware"
+	"gohbase/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondCacheable 按 Accept 选择的格式（JSON、MessagePack或Protobuf）返回可被HTTP缓存的结果：
+// 设置 ETag（响应内容的哈希）、Last-Modified（lastModified非零时）、Cache-Control（maxAge为0时要求每次重新验证）和 X-Cache-Hit；
+// GET请求的 If-None-Match 或 If-Modified-Since 表明客户端的副本仍然有效时返回304，不发送响应体
+// 不完整的结果使用 no-store，避免被客户端或代理缓存
+func respondCacheable(c *gin.Context, ctx context.Context, maxAge time.Duration, lastModified time.Time, payload interface{}) {
+	format := negotiateFormat(c, payload)
+	if format == "" {
+		respondNotAcceptable(c, payload)
+		return
+	}
+	body, contentType, err := encodePayload(format, payload)
+	if err != nil {
+		respondError(c, err, "序列化响应失败")
+		return
+	}
+
+	setCacheHit(c, ctx)
+	// 同一地址的响应格式取决于Accept，不同格式的ETag不同
+	c.Header("Vary", "Accept")
+	etag := bodyETag(body)
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	switch {
+	case utils.IsPartial(ctx):
+		c.Header("Cache-Control", "no-store")
+	case maxAge > 0:
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second)))
+	default:
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	if c.Request.Method == http.MethodGet && notModified(c.Request, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// setCacheHit 设置 X-Cache-Hit，结果完全来自缓存时为true
+func setCacheHit(c *gin.Context, ctx context.Context) {
+	c.Header(middleware.CacheHitHeader, strconv.FormatBool(utils.CacheHit(ctx)))
+}
+
+// bodyETag 根据响应内容生成强ETag，内容相同的响应ETag相同，与由哪个实例返回无关
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified 判断客户端缓存的副本是否仍然有效
+// 同时带有两个条件时以 If-None-Match 为准；If-Modified-Since 精确到秒
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// etagMatches 判断 If-None-Match 中是否有与etag相同的值，按弱比较忽略 W/ 前缀
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// timeOf 返回可选时间的值，nil时返回零值
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
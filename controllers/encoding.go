@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+// mimeProtobuf2 Protobuf的另一种常见MIME类型
+const mimeProtobuf2 = "application/protobuf"
+
+// protoMarshaler 支持Protobuf编码的响应，消息定义见 models/movie.proto
+type protoMarshaler interface {
+	MarshalProto() ([]byte, error)
+}
+
+// msgpackHandle MessagePack编码设置：字段名沿用json标签，map按键排序保证相同数据的编码结果相同，时间使用timestamp扩展类型
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.Canonical = true
+	return h
+}()
+
+// negotiateFormat 根据 Accept 选择响应格式，未指定时使用JSON；支持的格式都不被接受时返回空字符串
+// 所有响应都支持JSON和MessagePack，实现了protoMarshaler的响应还支持Protobuf
+func negotiateFormat(c *gin.Context, payload interface{}) string {
+	offered := []string{binding.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK}
+	if _, ok := payload.(protoMarshaler); ok {
+		offered = append(offered, binding.MIMEPROTOBUF, mimeProtobuf2)
+	}
+	return c.NegotiateFormat(offered...)
+}
+
+// encodePayload 按选定的格式编码响应，返回响应体和Content-Type
+func encodePayload(format string, payload interface{}) ([]byte, string, error) {
+	switch format {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		var body []byte
+		if err := codec.NewEncoderBytes(&body, msgpackHandle).Encode(payload); err != nil {
+			return nil, "", err
+		}
+		return body, format, nil
+	case binding.MIMEPROTOBUF, mimeProtobuf2:
+		body, err := payload.(protoMarshaler).MarshalProto()
+		return body, format, err
+	default:
+		body, err := json.Marshal(payload)
+		return body, binding.MIMEJSON + "; charset=utf-8", err
+	}
+}
+
+// respondNotAcceptable 客户端不接受任何支持的格式时返回406
+func respondNotAcceptable(c *gin.Context, payload interface{}) {
+	formats := []string{binding.MIMEJSON, binding.MIMEMSGPACK}
+	if _, ok := payload.(protoMarshaler); ok {
+		formats = append(formats, binding.MIMEPROTOBUF)
+	}
+	c.JSON(http.StatusNotAcceptable, gin.H{
+		"status":  "error",
+		"message": "不支持请求的响应格式，可用的格式: " + strings.Join(formats, ", "),
+	})
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"gohbase/middleware"
+	"gohbase/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest 客户端在响应前断开连接（沿用nginx的499约定）
+const StatusClientClosedRequest = 499
+
+// respondError 根据错误类型返回对应的状态码：超时返回504，熔断或降级返回503，客户端取消返回499，其余返回500
+func respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		middleware.Logger(c).Warnf("%s: 请求超时: %v", message, err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{
+			"status":  "error",
+			"code":    "deadline_exceeded",
+			"message": message + "：请求处理超时，请稍后重试",
+		})
+	case errors.Is(err, utils.ErrCircuitOpen), errors.Is(err, utils.ErrHBaseUnavailable):
+		middleware.Logger(c).Warnf("%s: %v", message, err)
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "error",
+			"code":    "circuit_open",
+			"message": message + "：数据服务暂时不可用，请稍后重试",
+		})
+	case errors.Is(err, context.Canceled):
+		// 客户端已断开，响应不会被读取，只记录日志
+		middleware.Logger(c).Infof("%s: 客户端已取消请求", message)
+		c.AbortWithStatus(StatusClientClosedRequest)
+	default:
+		middleware.Logger(c).Errorf("%s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": message,
+		})
+	}
+}
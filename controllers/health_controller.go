@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"gohbase/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController 健康检查控制器，供编排系统探测
+type HealthController struct{}
+
+// Healthz 存活检查，进程能够处理请求即返回200
+func (hc *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// Readyz 就绪检查，检查每个必需的HBase表、缓存和写入服务
+// 任一必需依赖不可用时返回503
+func (hc *HealthController) Readyz(c *gin.Context) {
+	start := time.Now()
+	ready, checks := utils.CheckReadiness(c.Request.Context())
+
+	status := "ready"
+	code := http.StatusOK
+	if !ready {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":    status,
+		"degraded":  !utils.HBaseAvailable(),
+		"latencyMs": float64(time.Since(start).Microseconds()) / 1000,
+		"checks":    checks,
+	})
+}
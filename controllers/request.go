@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"context"
+	"gohbase/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestContext 获取请求的context，best_effort=true 时允许返回部分结果
+// 部分结果会在响应中以 partial: true 标识，且不会被缓存
+func requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	switch c.Query("best_effort") {
+	case "true", "1":
+		ctx = utils.WithBestEffort(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gohbase/config"
+	"gohbase/middleware"
+	"gohbase/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SystemController 系统控制器
+type SystemController struct{}
+
+// GetSystemLogs 获取系统日志
+// 支持的查询参数：level（最低级别）、since/until（RFC3339时间）、q（子串）、lines（条数）
+func (sc *SystemController) GetSystemLogs(c *gin.Context) {
+	query, err := parseLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 获取行数参数
+	linesStr := c.DefaultQuery("lines", "20")
+	lines, err := strconv.Atoi(linesStr)
+	if err != nil || lines < 1 {
+		lines = 20
+	}
+
+	// 限制最大行数
+	if maxLines := config.Current().Limits.MaxLogLines; lines > maxLines {
+		lines = maxLines
+	}
+	query.Limit = lines
+
+	logs := utils.SystemLogs.Query(query)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"logs":   logs,
+		"buffer": utils.SystemLogs.Stats(),
+	})
+}
+
+// StreamSystemLogs 通过Server-Sent Events实时推送系统日志
+// 支持与GetSystemLogs相同的过滤参数，backlog参数指定连接时先推送的历史条数
+func (sc *SystemController) StreamSystemLogs(c *gin.Context) {
+	query, err := parseLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	backlog, err := strconv.Atoi(c.DefaultQuery("backlog", "0"))
+	if err != nil || backlog < 0 {
+		backlog = 0
+	}
+	if maxLines := config.Current().Limits.MaxLogLines; backlog > maxLines {
+		backlog = maxLines
+	}
+
+	// 先订阅再读取历史，避免两者之间产生的日志丢失
+	entries, cancel := utils.SystemLogs.Subscribe(256)
+	defer cancel()
+
+	var lastSeq uint64
+	var history []utils.LogEntry
+	if backlog > 0 {
+		historyQuery := query
+		historyQuery.Limit = backlog
+		history = utils.SystemLogs.Query(historyQuery)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 心跳，防止代理因连接空闲而断开
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		if len(history) > 0 {
+			for _, entry := range history {
+				writeLogEvent(w, entry)
+				lastSeq = entry.Seq
+			}
+			history = nil
+			return true
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		case entry := <-entries:
+			// 此前因消费过慢丢弃了日志时先通知客户端，客户端可以从gap.since起查询补齐
+			if gap := entry.Gap; gap != nil && gap.LastSeq > lastSeq {
+				writeGapEvent(w, *gap)
+			}
+			// 跳过已经作为历史推送过的日志
+			if entry.Seq <= lastSeq || !query.Match(entry) {
+				return true
+			}
+			writeLogEvent(w, entry)
+			lastSeq = entry.Seq
+			return true
+		}
+	})
+}
+
+// GetCacheStats 获取缓存统计信息
+func (sc *SystemController) GetCacheStats(c *gin.Context) {
+	stats := utils.Cache.Stats()
+	data := gin.H{"stats": stats}
+	if utils.Invalidations != nil {
+		data["invalidation"] = utils.Invalidations.Status()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   data,
+	})
+}
+
+// 缓存管理接口列出缓存键的默认数量和最大数量
+const (
+	defaultCacheKeyLimit = 100
+	maxCacheKeyLimit     = 1000
+)
+
+// ListCacheKeys 按前缀列出缓存键及其过期时间和估算大小
+// 查询参数：prefix（键前缀，为空时列出全部）、limit（最多返回的条数）
+func (sc *SystemController) ListCacheKeys(c *gin.Context) {
+	prefix := c.Query("prefix")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCacheKeyLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultCacheKeyLimit
+	}
+	if limit > maxCacheKeyLimit {
+		limit = maxCacheKeyLimit
+	}
+
+	entries, total := utils.Cache.Keys(prefix, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"prefix":    prefix,
+			"total":     total,
+			"truncated": total > len(entries),
+			"entries":   entries,
+		},
+	})
+}
+
+// GetCacheEntry 获取单个缓存项的元数据，键通过查询参数key指定
+func (sc *SystemController) GetCacheEntry(c *gin.Context) {
+	key, ok := requireQuery(c, "key")
+	if !ok {
+		return
+	}
+
+	entry, found := utils.Cache.Entry(key)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "缓存项不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   entry,
+	})
+}
+
+// DeleteCacheEntry 删除单个缓存项，键通过查询参数key指定，同时通知其他实例删除
+func (sc *SystemController) DeleteCacheEntry(c *gin.Context) {
+	key, ok := requireQuery(c, "key")
+	if !ok {
+		return
+	}
+
+	deleted := utils.Cache.Delete(key)
+	// 其他实例可能缓存了该项，本实例不存在时也通知其他实例
+	utils.Invalidations.Publish(utils.InvalidationEvent{Keys: []string{key}})
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "缓存项不存在",
+		})
+		return
+	}
+	middleware.Logger(c).Infof("管理员删除了缓存项 %s", key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "缓存项已删除",
+	})
+}
+
+// FlushCachePrefix 删除键以指定前缀开头的所有缓存项，前缀通过查询参数prefix指定
+// 正在进行的加载结果不会写回缓存；其他实例通过缓存失效广播清除，removed只统计本实例
+func (sc *SystemController) FlushCachePrefix(c *gin.Context) {
+	prefix, ok := requireQuery(c, "prefix")
+	if !ok {
+		return
+	}
+
+	removed := utils.Cache.InvalidatePrefix(prefix)
+	utils.Invalidations.Publish(utils.InvalidationEvent{Prefixes: []string{prefix}})
+	middleware.Logger(c).Infof("管理员清除了前缀为 %s 的缓存项，共 %d 个", prefix, removed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"prefix":  prefix,
+			"removed": removed,
+		},
+	})
+}
+
+// SweepCache 立即清理已过期的缓存项，降级模式下过期项仍在提供，不做清理
+func (sc *SystemController) SweepCache(c *gin.Context) {
+	removed, swept := utils.Cache.DeleteExpired()
+	if !swept {
+		middleware.Logger(c).Infof("管理员请求清理过期缓存项，降级模式下跳过")
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "HBase不可用，降级模式下过期的缓存项仍在提供，暂不清理",
+		})
+		return
+	}
+	middleware.Logger(c).Infof("管理员清理了过期缓存项，共 %d 个", removed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"removed": removed,
+		},
+	})
+}
+
+// GetSystemStatus 获取系统运行状态，包括HBase连接状态、各表熔断器状态、重试策略和实际使用的表名
+func (sc *SystemController) GetSystemStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"hbase": gin.H{
+				"connectivity":  utils.ConnectivityStatus(),
+				"breakers":      utils.BreakerStates(),
+				"retryPolicies": utils.RetryPolicies(),
+				"tables":        utils.TableNames(),
+			},
+		},
+	})
+}
+
+// parseLogQuery 解析日志过滤参数
+func parseLogQuery(c *gin.Context) (utils.LogQuery, error) {
+	var query utils.LogQuery
+
+	if levelStr := c.Query("level"); levelStr != "" {
+		level, err := logrus.ParseLevel(levelStr)
+		if err != nil {
+			return query, fmt.Errorf("无效的日志级别: %s", levelStr)
+		}
+		query.Level = &level
+	}
+
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return query, fmt.Errorf("无效的起始时间: %s", sinceStr)
+		}
+		query.Since = since
+	}
+
+	if untilStr := c.Query("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			return query, fmt.Errorf("无效的结束时间: %s", untilStr)
+		}
+		query.Until = until
+	}
+
+	query.Contains = c.Query("q")
+	return query, nil
+}
+
+// requireQuery 读取必填的查询参数，缺少时返回400
+func requireQuery(c *gin.Context, name string) (string, bool) {
+	value := c.Query(name)
+	if value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "缺少参数 " + name,
+		})
+		return "", false
+	}
+	return value, true
+}
+
+// writeLogEvent 以SSE格式写出一条日志
+func writeLogEvent(w io.Writer, entry utils.LogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", entry.Seq, data)
+}
+
+// writeGapEvent 写入一个SSE gap事件，说明哪些日志没有推送
+func writeGapEvent(w io.Writer, gap utils.LogGap) {
+	data, err := json.Marshal(gap)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: gap\ndata: %s\n\n", data)
+}
@@ -12,7 +12,13 @@ type WriteController struct{}
 
 // StartRandomWrites 开始随机写入操作
 func (wc *WriteController) StartRandomWrites(c *gin.Context) {
-	utils.WriteManagerInstance.StartRandomWrites()
+	if err := utils.WriteManagerInstance.StartRandomWrites(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "随机写入服务已启动",
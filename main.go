@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"gohbase/config"
+	"gohbase/models"
 	"gohbase/routes"
 	"gohbase/utils"
 	"net/http"
@@ -22,28 +24,91 @@ func init() {
 	})
 	logrus.SetOutput(os.Stdout)
 	logrus.SetLevel(logrus.InfoLevel)
+
+	// 捕获日志到环形缓冲区，供 /api/system/logs 查询
+	utils.InitLogBuffer(1000)
 }
 
 func main() {
-	// 获取配置
-	cfg := config.GetConfig()
+	configPath := flag.String("config", "", "配置文件路径（YAML），默认读取 CONFIG_FILE 环境变量或 ./config.yaml")
+	flag.Parse()
+	path := config.ResolvePath(*configPath)
+
+	// 执行子命令，如 config check
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(path, args))
+	}
+
+	// 加载并校验配置
+	cfg, err := config.Load(path)
+	if err != nil {
+		logrus.Fatalf("加载配置失败: %v", err)
+	}
+	config.Set(cfg)
+	applyLogLevel(cfg.Server.LogLevel)
+	if path != "" {
+		logrus.Infof("已加载配置文件: %s", path)
+	}
 
 	// 打印配置信息以便调试
-	logrus.Infof("配置信息: HBase主机=%s, ZooKeeper地址=%s, ZooKeeper端口=%s",
-		cfg.HBase.Host, cfg.HBase.ZkQuorum, cfg.HBase.ZkPort)
+	logrus.Infof("配置信息: ZooKeeper地址=%s, 根节点=%s", cfg.HBase.Quorum(), cfg.HBase.ZkRoot)
+	for _, warning := range config.DeprecatedEnv() {
+		logrus.Warn(warning)
+	}
+
+	// 初始化链路追踪
+	shutdownTracing, err := utils.InitTracing(&cfg.Tracing)
+	if err != nil {
+		logrus.Fatalf("初始化链路追踪失败: %v", err)
+	}
 
-	// 初始化缓存系统 - 默认过期时间5分钟，清理间隔10分钟
-	utils.InitCache(5*time.Minute, 10*time.Minute)
+	// 初始化缓存系统
+	utils.InitCache(&cfg.Cache)
 	logrus.Info("缓存系统初始化成功")
 
-	// 初始化HBase连接
-	err := utils.InitHBase(&cfg.HBase)
+	// 从上次关闭时保存的快照恢复缓存
+	utils.RestoreCacheSnapshot(&cfg.Cache)
+
+	// 初始化HBase调用的重试与熔断策略
+	utils.InitResilience(&cfg.Resilience)
+
+	// 初始化HBase连接，连接失败时以降级模式启动
+	err = utils.InitHBase(&cfg.HBase)
 	if err != nil {
 		logrus.Fatalf("初始化HBase失败: %v", err)
 	}
 
+	// 启动跨实例缓存失效广播
+	utils.InitInvalidationBus(&cfg.Cache.Invalidation)
+
+	// 注册后台组件，关闭时按相反顺序停止：先排空写入和统计保存，最后关闭HBase客户端
+	// 缓存快照在写入排空、缓存刷新结束之后保存，保证快照中的数据已经反映所有写入
+	// 失效广播在HBase客户端关闭之前最后一次发布，其他实例能收到关闭前所有写入的失效
+	// HBase客户端和缓存快照不丢弃任务，只报告关闭是否出错
+	utils.Lifecycle.Register("HBase客户端", func(context.Context) (int, error) {
+		utils.CloseHBase()
+		return 0, nil
+	})
+	utils.Lifecycle.Register("缓存失效广播", utils.Invalidations.Stop)
+	utils.Lifecycle.Register("缓存快照", func(ctx context.Context) (int, error) {
+		_, err := utils.SaveCacheSnapshot(ctx)
+		return 0, err
+	})
+	utils.Lifecycle.Register("缓存清理", utils.Cache.Close)
+	utils.Lifecycle.Register("统计信息保存", utils.StatsSaves.Stop)
+	utils.Lifecycle.Register("缓存后台刷新", utils.CacheRefreshes.Stop)
+	utils.Lifecycle.Register("随机写入", utils.WriteManagerInstance.Shutdown)
+	utils.Lifecycle.Register("缓存预热", utils.CacheWarmup.Stop)
+
+	// 在启动HTTP服务之前开始预热，预热完成前就绪检查不通过
+	if warmup := cfg.Cache.Warmup; warmup.Enabled {
+		utils.CacheWarmup.Start(warmup.Timeout, func(ctx context.Context) (int, int) {
+			return models.WarmUpCache(ctx, &warmup)
+		})
+	}
+
 	// 设置路由
-	router := routes.SetupRouter()
+	router := routes.SetupRouter(cfg)
 
 	// 创建HTTP服务器
 	srv := &http.Server{
@@ -59,19 +124,38 @@ func main() {
 		}
 	}()
 
+	// 收到SIGHUP时重新加载配置
+	go watchReload(path)
+
 	// 等待中断信号以优雅地关闭服务器
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logrus.Info("关闭服务器...")
 
-	// 设置关闭超时时间
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// 设置关闭超时时间，请求处理和后台任务排空共用该截止时间
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	// 关闭服务器
+	// 停止接受新请求并等待正在处理的请求完成
 	if err := srv.Shutdown(ctx); err != nil {
-		logrus.Fatalf("服务器强制关闭: %v", err)
+		logrus.Errorf("服务器强制关闭: %v", err)
+	}
+
+	// 排空后台任务，最后关闭HBase客户端
+	dropped := 0
+	for _, report := range utils.Lifecycle.Shutdown(ctx) {
+		dropped += report.Dropped
+	}
+	if dropped > 0 {
+		logrus.Warnf("关闭期间共丢弃 %d 个未完成的后台任务", dropped)
+	}
+
+	// 刷新尚未导出的追踪数据，使用独立的截止时间避免被排空过程耗尽
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logrus.Errorf("关闭链路追踪失败: %v", err)
 	}
 
 	logrus.Info("服务器已退出")
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog 使用logrus记录访问日志，替代gin默认的Logger以便日志被统一捕获
+// skipPaths 中的路径（如日志查询接口本身）不记录，避免轮询刷屏
+func AccessLog(skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		if skip[path] {
+			return
+		}
+
+		status := c.Writer.Status()
+		entry := Logger(c).WithFields(logrus.Fields{
+			"method":   c.Request.Method,
+			"path":     path,
+			"status":   status,
+			"latency":  time.Since(start).String(),
+			"clientIp": c.ClientIP(),
+		})
+
+		switch {
+		case status >= 500:
+			entry.Error("请求处理失败")
+		case status >= 400:
+			entry.Warn("请求参数或资源错误")
+		default:
+			entry.Info("请求处理完成")
+		}
+	}
+}
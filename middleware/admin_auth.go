@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"gohbase/config"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader 传递管理令牌的HTTP头，也可以使用 Authorization: Bearer <令牌>
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth 校验管理令牌，并在日志中记录每次管理请求及其结果
+// 每次请求读取当前配置，令牌可以通过SIGHUP更换；未配置令牌时管理接口不可用
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := Logger(c).WithField("client", c.ClientIP())
+		action := c.Request.Method + " " + c.Request.URL.RequestURI()
+
+		expected := config.Current().Admin.Token
+		if expected == "" {
+			log.Warnf("拒绝管理请求 %s: 未配置管理令牌", action)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "管理接口未启用，请配置 admin.token 或 ADMIN_TOKEN",
+			})
+			return
+		}
+
+		token := adminToken(c)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			log.Warnf("拒绝管理请求 %s: 管理令牌无效", action)
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "管理令牌无效",
+			})
+			return
+		}
+
+		c.Next()
+		log.Infof("管理请求 %s 已处理，状态码 %d", action, c.Writer.Status())
+	}
+}
+
+// adminToken 从请求头中读取管理令牌
+func adminToken(c *gin.Context) string {
+	if token := c.GetHeader(AdminTokenHeader); token != "" {
+		return token
+	}
+	if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"gohbase/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheHitHeader 标识响应是否完全来自缓存的响应头
+const CacheHitHeader = "X-Cache-Hit"
+
+// CacheTrace 记录请求处理过程中的缓存查找，处理器据此设置 X-Cache-Hit
+func CacheTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.WithCacheTrace(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"gohbase/config"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的压缩方式，与 Accept-Encoding 中的名称相同
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// compressibleTypes 会被压缩的响应类型，图片等已压缩的内容和事件流不压缩
+var compressibleTypes = []string{
+	"application/json",
+	"application/msgpack",
+	"application/x-msgpack",
+	"application/protobuf",
+	"application/x-protobuf",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/javascript",
+	"text/xml",
+}
+
+// compressor gzip.Writer 和 brotli.Writer 的公共方法
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// 按压缩级别复用的压缩器，创建压缩器需要分配较大的内存
+var (
+	gzipPools   [gzip.BestCompression + 1]sync.Pool
+	brotliPools [brotli.BestCompression + 1]sync.Pool
+)
+
+// Compress 按客户端的 Accept-Encoding 以brotli或gzip压缩响应，两者都接受时优先brotli
+// 响应体达到 server.compression.min_size 才压缩；已设置 Content-Encoding 的响应、事件流和304等没有响应体的响应不压缩
+// 压缩后的响应把强ETag改为弱ETag，条件请求按弱比较仍然匹配
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := config.Current().Server.Compression
+		if !conf.Enabled || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, conf: conf}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选择权重最高的压缩方式，都不接受时返回空字符串
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		q, ok := weights[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter 先缓存响应体，达到阈值后开始压缩，响应结束时仍未达到阈值的原样写出
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	conf     config.CompressionConfig
+
+	decided     bool       // 是否已根据状态码和响应头判断过能否压缩
+	passthrough bool       // 不压缩，直接写出
+	buf         []byte     // 尚未达到阈值的响应体
+	compressor  compressor // 开始压缩后使用的压缩器
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+	if !w.decided {
+		w.decided = true
+		w.passthrough = !w.compressible()
+		if !w.passthrough {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.conf.MinSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 发送已写入的数据，尚未开始压缩时放弃压缩，把缓存的数据原样写出
+func (w *compressWriter) Flush() {
+	if w.compressor != nil {
+		w.compressor.Flush()
+	} else if len(w.buf) > 0 {
+		w.passthrough = true
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible 判断响应能否压缩，在第一次写入响应体时调用
+func (w *compressWriter) compressible() bool {
+	switch status := w.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent,
+		status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range compressibleTypes {
+		if contentType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// start 设置压缩相关的响应头，压缩并写出已缓存的数据
+func (w *compressWriter) start() error {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	weakenETag(header)
+
+	w.compressor = newCompressor(w.encoding, w.conf, w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.compressor.Write(buf)
+	return err
+}
+
+// finish 在请求处理完成后结束压缩，或写出未达到阈值的响应体
+// 304响应与对应的压缩后的响应使用相同的ETag和Vary
+func (w *compressWriter) finish() {
+	if !w.decided && w.Status() == http.StatusNotModified && !w.Written() {
+		w.Header().Add("Vary", "Accept-Encoding")
+		weakenETag(w.Header())
+		return
+	}
+	if w.compressor != nil {
+		w.compressor.Close()
+		releaseCompressor(w.encoding, w.conf, w.compressor)
+		w.compressor = nil
+		return
+	}
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// weakenETag 把强ETag改为弱ETag，压缩后的内容与原内容字节不同，但语义相同
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+// newCompressor 从池中取出或新建压缩器，输出到dst
+func newCompressor(encoding string, conf config.CompressionConfig, dst io.Writer) compressor {
+	pool := compressorPool(encoding, conf)
+	if c, ok := pool.Get().(compressor); ok {
+		c.Reset(dst)
+		return c
+	}
+	if encoding == encodingBrotli {
+		return brotli.NewWriterLevel(dst, conf.BrotliLevel)
+	}
+	// 压缩级别已在加载配置时校验
+	c, _ := gzip.NewWriterLevel(dst, conf.GzipLevel)
+	return c
+}
+
+// releaseCompressor 把压缩器放回池中
+func releaseCompressor(encoding string, conf config.CompressionConfig, c compressor) {
+	c.Reset(io.Discard)
+	compressorPool(encoding, conf).Put(c)
+}
+
+// compressorPool 返回压缩方式和级别对应的池
+func compressorPool(encoding string, conf config.CompressionConfig) *sync.Pool {
+	if encoding == encodingBrotli {
+		return &brotliPools[conf.BrotliLevel]
+	}
+	return &gzipPools[conf.GzipLevel]
+}
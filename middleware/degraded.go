@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"gohbase/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DegradedHeader 服务处于降级模式时添加的响应头
+const DegradedHeader = "X-Degraded"
+
+// Degraded 在HBase不可用期间为响应添加 X-Degraded: true，表示数据可能来自已过期的缓存
+func Degraded() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.HBaseAvailable() {
+			c.Header(DegradedHeader, "true")
+		}
+		c.Next()
+	}
+}
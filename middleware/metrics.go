@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"gohbase/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录HTTP请求数量和耗时，按路由模板而非原始路径区分以控制标签基数
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		utils.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		utils.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
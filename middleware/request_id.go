@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gohbase/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader 请求ID使用的HTTP头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID 为每个请求分配请求ID，优先沿用客户端传入的ID
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+
+		c.Set(utils.RequestIDField, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// Logger 返回携带当前请求ID的日志记录器
+func Logger(c *gin.Context) *logrus.Entry {
+	if requestID := c.GetString(utils.RequestIDField); requestID != "" {
+		return logrus.WithField(utils.RequestIDField, requestID)
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// newRequestID 生成随机请求ID
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
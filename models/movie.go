@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"gohbase/config"
 	"gohbase/utils"
 	"math/rand"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/tsuna/gohbase/hrpc"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // 全局随机数生成器
@@ -36,11 +38,18 @@ type Links struct {
 
 // MovieList 电影列表响应
 type MovieList struct {
-	Movies      []Movie `json:"movies"`
-	TotalMovies int     `json:"totalMovies"`
-	Page        int     `json:"page"`
-	PerPage     int     `json:"perPage"`
-	TotalPages  int     `json:"totalPages"`
+	Movies      []Movie    `json:"movies"`
+	TotalMovies int        `json:"totalMovies"`
+	Page        int        `json:"page"`
+	PerPage     int        `json:"perPage"`
+	TotalPages  int        `json:"totalPages"`
+	Partial     bool       `json:"partial,omitempty"`   // 允许部分结果时，是否有数据因读取失败被跳过
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"` // 列表中数据最新的写入时间，用于Last-Modified
+}
+
+// Empty 判断搜索是否没有任何匹配，用于缓存的空结果策略
+func (l *MovieList) Empty() bool {
+	return l.TotalMovies == 0
 }
 
 // MovieDetail 电影详情响应
@@ -49,28 +58,49 @@ type MovieDetail struct {
 	Ratings     []Rating            `json:"ratings,omitempty"`
 	TaggedUsers []map[string]string `json:"taggedUsers,omitempty"`
 	Stats       map[string]float64  `json:"stats,omitempty"`
+	Partial     bool                `json:"partial,omitempty"`   // 允许部分结果时，是否有数据因读取失败被跳过
+	UpdatedAt   *time.Time          `json:"updatedAt,omitempty"` // 电影、链接和平均评分数据最新的写入时间，用于Last-Modified
 }
 
 // Rating 评分
 type Rating struct {
-	UserID string  `json:"userId"`
-	Rating float64 `json:"rating"`
+	UserID    string  `json:"userId"`
+	Rating    float64 `json:"rating"`
+	Timestamp int64   `json:"timestamp,omitempty"` // 评分时间（Unix毫秒，秒级的旧数据已换算），电影详情中不返回
+}
+
+// 注册电影详情、搜索结果和随机电影的序列化方式，用于缓存快照和共享缓存
+func init() {
+	utils.RegisterCacheCodec("movie_detail", utils.JSONCodec[*MovieDetail]())
+	utils.RegisterCacheCodec("search", utils.JSONCodec[*MovieList]())
+	utils.RegisterCacheCodec("random_movies", utils.JSONCodec[[]Movie]())
 }
 
 // GetMovieByID 根据ID获取电影（带缓存）
-func GetMovieByID(movieID string) (*MovieDetail, error) {
-	// 构建缓存键
-	cacheKey := fmt.Sprintf("movie_detail:%s", movieID)
+func GetMovieByID(ctx context.Context, movieID string) (*MovieDetail, error) {
+	ctx, span := utils.StartSpan(ctx, "models.GetMovieByID", attribute.String("movie.id", movieID))
+	defer span.End()
 
-	// 检查缓存
-	if cachedData, found := utils.Cache.Get(cacheKey); found {
-		return cachedData.(*MovieDetail), nil
+	// 构建缓存键，同一部电影的并发请求只从HBase加载一次
+	cacheKey := fmt.Sprintf("movie_detail:%s", movieID)
+	value, err := utils.Cache.GetOrLoad(ctx, cacheKey, 0, func(ctx context.Context) (interface{}, error) {
+		detail, err := loadMovieDetail(ctx, movieID)
+		if detail == nil {
+			return nil, err
+		}
+		return detail, err
+	}, utils.MovieTag(movieID))
+	if err != nil {
+		return nil, err
 	}
+	detail, _ := value.(*MovieDetail)
+	return detail, nil
+}
 
-	ctx := context.Background()
-
+// loadMovieDetail 从HBase加载电影详情，电影不存在时返回nil
+func loadMovieDetail(ctx context.Context, movieID string) (*MovieDetail, error) {
 	// 从HBase获取电影数据
-	data, err := utils.GetMovie(ctx, movieID)
+	data, updated, err := utils.GetMovieWithUpdatedTime(ctx, movieID)
 	if err != nil {
 		return nil, err
 	}
@@ -162,15 +192,20 @@ func GetMovieByID(movieID string) (*MovieDetail, error) {
 		"tagCount":    float64(len(movie.Tags)),
 	}
 
-	// 将结果存入缓存
-	utils.Cache.Set(cacheKey, detail)
+	// 不完整的结果由GetOrLoad返回给调用方但不缓存
+	detail.Partial = utils.IsPartial(ctx)
+	if !updated.IsZero() {
+		detail.UpdatedAt = &updated
+	}
 
 	return detail, nil
 }
 
 // GetMoviesList 获取电影列表
-func GetMoviesList(page, perPage int) (*MovieList, error) {
-	ctx := context.Background()
+func GetMoviesList(ctx context.Context, page, perPage int) (*MovieList, error) {
+	ctx, span := utils.StartSpan(ctx, "models.GetMoviesList",
+		attribute.Int("page", page), attribute.Int("perPage", perPage))
+	defer span.End()
 
 	// 计算分页参数
 	startIdx := (page-1)*perPage + 1 // 从1开始
@@ -267,22 +302,29 @@ func GetMoviesList(page, perPage int) (*MovieList, error) {
 	}
 
 	// 构建响应
-	totalMovies := 9742                                 // 从数据库结构文档中获取的总电影数
+	totalMovies := config.Current().Limits.TotalMovies  // 配置中的总电影数
 	totalPages := (totalMovies + perPage - 1) / perPage // 计算总页数
 
-	return &MovieList{
+	list := &MovieList{
 		Movies:      movies,
 		TotalMovies: totalMovies,
 		Page:        page,
 		PerPage:     perPage,
 		TotalPages:  totalPages,
-	}, nil
+		Partial:     utils.IsPartial(ctx),
+	}
+	if updated := utils.LatestCellTime(results...); !updated.IsZero() {
+		list.UpdatedAt = &updated
+	}
+	return list, nil
 }
 
 // GetRandomMovies 获取随机电影（带缓存）
-func GetRandomMovies(count int) ([]Movie, error) {
-	ctx := context.Background()
-	totalMovies := 9742 // 总电影数
+func GetRandomMovies(ctx context.Context, count int) ([]Movie, error) {
+	ctx, span := utils.StartSpan(ctx, "models.GetRandomMovies", attribute.Int("count", count))
+	defer span.End()
+
+	totalMovies := config.Current().Limits.TotalMovies // 总电影数
 
 	// 构建缓存键 - 这里我们不直接缓存结果，而是缓存seed，确保一段时间内返回相同的"随机"电影
 	// 使用当前时间的小时数作为缓存键，这样每小时刷新一次随机结果
@@ -290,7 +332,7 @@ func GetRandomMovies(count int) ([]Movie, error) {
 	cacheKey := fmt.Sprintf("random_movies:%d:%d", count, currentHour)
 
 	// 检查缓存中是否有随机电影数据
-	if cachedMovies, found := utils.Cache.Get(cacheKey); found {
+	if cachedMovies, found := utils.Cache.GetContext(ctx, cacheKey); found {
 		return cachedMovies.([]Movie), nil
 	}
 
@@ -303,6 +345,10 @@ func GetRandomMovies(count int) ([]Movie, error) {
 		movieID := fmt.Sprintf("%d", id)
 		data, err := utils.GetMovie(ctx, movieID)
 		if err != nil {
+			// 允许部分结果时跳过失败的电影，否则返回错误
+			if err := utils.ToleratePartial(ctx, err); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -343,45 +389,55 @@ func GetRandomMovies(count int) ([]Movie, error) {
 		movies = append(movies, movie)
 	}
 
-	// 将结果存入缓存
-	utils.Cache.Set(cacheKey, movies)
+	// 将结果存入缓存，不完整的结果不缓存；其中任一电影的数据变化时失效
+	if !utils.IsPartial(ctx) {
+		tags := make([]string, len(movies))
+		for i, movie := range movies {
+			tags[i] = utils.MovieTag(movie.MovieID)
+		}
+		utils.Cache.SetWithTags(cacheKey, movies, 0, tags...)
+	}
 
 	return movies, nil
 }
 
 // SearchMovies 搜索电影（带缓存）
-func SearchMovies(query string, page, perPage int) (*MovieList, error) {
-	// 构建缓存键
-	cacheKey := fmt.Sprintf("search:%s:%d:%d", query, page, perPage)
+func SearchMovies(ctx context.Context, query string, page, perPage int) (*MovieList, error) {
+	ctx, span := utils.StartSpan(ctx, "models.SearchMovies",
+		attribute.String("query", query), attribute.Int("page", page), attribute.Int("perPage", perPage))
+	defer span.End()
 
-	// 检查缓存
-	if cachedResults, found := utils.Cache.Get(cacheKey); found {
-		return cachedResults.(*MovieList), nil
+	// 构建缓存键，相同查询的并发请求只扫描一次
+	cacheKey := fmt.Sprintf("search:%s:%d:%d", query, page, perPage)
+	value, err := utils.Cache.GetOrLoad(ctx, cacheKey, 0, func(ctx context.Context) (interface{}, error) {
+		return searchMovies(ctx, query, page, perPage)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return value.(*MovieList), nil
+}
 
-	ctx := context.Background()
-
+// searchMovies 扫描movies表，按标题和类型匹配查询并分页
+func searchMovies(ctx context.Context, query string, page, perPage int) (*MovieList, error) {
 	// 创建扫描 - 使用movies表
-	scan, err := hrpc.NewScanStr(ctx, "movies",
+	scan, err := hrpc.NewScanStr(ctx, utils.Tables().Movies,
 		hrpc.Families(map[string][]string{"info": {"title", "genres"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := utils.GetClient().Scan(scan)
 	matchedMovies := []Movie{}
 
 	// 将查询转为小写以进行不区分大小写的匹配
 	queryLower := strings.ToLower(query)
 
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾
-		}
+	// 扫描过程中获取电影详情失败的错误
+	var fetchErr error
 
+	err = utils.ScanEach(scan, func(res *hrpc.Result) bool {
 		if len(res.Cells) == 0 {
-			continue
+			return true
 		}
 
 		// 获取行键（即movieId）
@@ -405,10 +461,11 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 
 		// 检查标题是否匹配
 		if title != "" && strings.Contains(strings.ToLower(title), queryLower) {
-			// 获取完整的电影信息
+			// 获取完整的电影信息，允许部分结果时跳过失败的电影
 			movieData, err := utils.GetMovie(ctx, movieID)
 			if err != nil {
-				continue
+				fetchErr = utils.ToleratePartial(ctx, err)
+				return fetchErr == nil
 			}
 
 			parsedData := utils.ParseMovieData(movieID, movieData)
@@ -447,7 +504,7 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 			}
 
 			matchedMovies = append(matchedMovies, movie)
-			continue
+			return true
 		}
 
 		// 检查类型是否匹配
@@ -455,10 +512,11 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 			genresArr := strings.Split(genres, "|")
 			for _, genre := range genresArr {
 				if strings.Contains(strings.ToLower(genre), queryLower) {
-					// 获取完整的电影信息
+					// 获取完整的电影信息，允许部分结果时跳过失败的电影
 					movieData, err := utils.GetMovie(ctx, movieID)
 					if err != nil {
-						continue
+						fetchErr = utils.ToleratePartial(ctx, err)
+						return fetchErr == nil
 					}
 
 					parsedData := utils.ParseMovieData(movieID, movieData)
@@ -496,6 +554,14 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 				}
 			}
 		}
+
+		return true
+	})
+	if err == nil {
+		err = fetchErr
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// 计算分页
@@ -516,11 +582,9 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 			Page:        page,
 			PerPage:     perPage,
 			TotalPages:  totalPages,
+			Partial:     utils.IsPartial(ctx),
 		}
 
-		// 缓存搜索结果
-		utils.Cache.Set(cacheKey, result)
-
 		return result, nil
 	}
 
@@ -531,11 +595,9 @@ func SearchMovies(query string, page, perPage int) (*MovieList, error) {
 		Page:        page,
 		PerPage:     perPage,
 		TotalPages:  totalPages,
+		Partial:     utils.IsPartial(ctx),
 	}
 
-	// 缓存搜索结果
-	utils.Cache.Set(cacheKey, result)
-
 	return result, nil
 }
 
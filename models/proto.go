@@ -0,0 +1,165 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 响应的Protobuf编码，消息定义见 movie.proto
+// 与proto3一致，值为零的标量字段不输出；map按键排序输出，相同的数据编码结果相同
+
+// MarshalProto 按 movie.proto 中的 MovieList 编码
+func (l *MovieList) MarshalProto() ([]byte, error) {
+	var b []byte
+	for i := range l.Movies {
+		b = appendMessage(b, 1, l.Movies[i].appendProto(nil))
+	}
+	b = appendInt(b, 2, int64(l.TotalMovies))
+	b = appendInt(b, 3, int64(l.Page))
+	b = appendInt(b, 4, int64(l.PerPage))
+	b = appendInt(b, 5, int64(l.TotalPages))
+	b = appendBool(b, 6, l.Partial)
+	b = appendTimestamp(b, 7, l.UpdatedAt)
+	return b, nil
+}
+
+// MarshalProto 按 movie.proto 中的 MovieDetail 编码
+func (d *MovieDetail) MarshalProto() ([]byte, error) {
+	b := appendMessage(nil, 1, d.Movie.appendProto(nil))
+	for _, rating := range d.Ratings {
+		b = appendMessage(b, 2, rating.appendProto(nil))
+	}
+	for _, user := range d.TaggedUsers {
+		var entries []byte
+		for _, key := range sortedKeys(user) {
+			// map的每一项是包含key和value的消息，与官方实现一致，两者始终输出
+			entry := appendRepeatedString(nil, 1, key)
+			entry = appendRepeatedString(entry, 2, user[key])
+			entries = appendMessage(entries, 1, entry)
+		}
+		b = appendMessage(b, 3, entries)
+	}
+	for _, key := range sortedKeys(d.Stats) {
+		entry := appendRepeatedString(nil, 1, key)
+		entry = protowire.AppendTag(entry, 2, protowire.Fixed64Type)
+		entry = protowire.AppendFixed64(entry, math.Float64bits(d.Stats[key]))
+		b = appendMessage(b, 4, entry)
+	}
+	b = appendBool(b, 5, d.Partial)
+	b = appendTimestamp(b, 6, d.UpdatedAt)
+	return b, nil
+}
+
+// MarshalProto 按 movie.proto 中的 MovieRatings 编码
+func (r *MovieRatings) MarshalProto() ([]byte, error) {
+	b := appendString(nil, 1, r.Status)
+	for _, rating := range r.Ratings {
+		b = appendMessage(b, 2, rating.appendProto(nil))
+	}
+	b = appendInt(b, 3, int64(r.Count))
+	b = appendDouble(b, 4, r.AvgRating)
+	b = appendDouble(b, 5, r.MinRating)
+	b = appendDouble(b, 6, r.MaxRating)
+	b = appendBool(b, 7, r.Partial)
+	b = appendTimestamp(b, 8, r.UpdatedAt)
+	return b, nil
+}
+
+// appendProto 按 movie.proto 中的 Movie 编码
+func (m *Movie) appendProto(b []byte) []byte {
+	b = appendString(b, 1, m.MovieID)
+	b = appendString(b, 2, m.Title)
+	for _, genre := range m.Genres {
+		b = appendRepeatedString(b, 3, genre)
+	}
+	b = appendInt(b, 4, int64(m.Year))
+	b = appendDouble(b, 5, m.AvgRating)
+	if m.Links != (Links{}) {
+		links := appendString(nil, 1, m.Links.ImdbID)
+		links = appendString(links, 2, m.Links.ImdbURL)
+		links = appendString(links, 3, m.Links.TmdbID)
+		links = appendString(links, 4, m.Links.TmdbURL)
+		b = appendMessage(b, 6, links)
+	}
+	for _, tag := range m.Tags {
+		b = appendRepeatedString(b, 7, tag)
+	}
+	return b
+}
+
+// appendProto 按 movie.proto 中的 Rating 编码
+func (r Rating) appendProto(b []byte) []byte {
+	b = appendString(b, 1, r.UserID)
+	b = appendDouble(b, 2, r.Rating)
+	return appendInt(b, 3, r.Timestamp)
+}
+
+// appendString 输出string字段，空字符串不输出
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return appendRepeatedString(b, num, v)
+}
+
+// appendRepeatedString 输出repeated string中的一项，空字符串也需要输出
+func appendRepeatedString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendInt 输出int32或int64字段，0不输出；负数按补码编码为10字节的varint
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendDouble 输出double字段，0不输出
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendBool 输出bool字段，false不输出
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+// appendMessage 输出嵌套消息字段
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendTimestamp 输出google.protobuf.Timestamp字段，nil不输出
+func appendTimestamp(b []byte, num protowire.Number, t *time.Time) []byte {
+	if t == nil {
+		return b
+	}
+	ts := appendInt(nil, 1, t.Unix())
+	ts = appendInt(ts, 2, int64(t.Nanosecond()))
+	return appendMessage(b, num, ts)
+}
+
+// sortedKeys 返回map按字典序排列的键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
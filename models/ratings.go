@@ -0,0 +1,56 @@
+package models
+
+import (
+	"gohbase/utils"
+	"time"
+)
+
+// MovieRatings 电影评分响应：所有评分及统计
+type MovieRatings struct {
+	Status    string     `json:"status"`
+	Ratings   []Rating   `json:"ratings"`
+	Count     int        `json:"count"`
+	AvgRating float64    `json:"avgRating"`
+	MinRating float64    `json:"minRating"`
+	MaxRating float64    `json:"maxRating"`
+	Partial   bool       `json:"partial"`             // 允许部分结果时，是否有评分因读取失败被跳过
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"` // 统计的计算时间与最新一条评分时间中较晚的一个，用于Last-Modified
+}
+
+// NewMovieRatings 根据utils.GetMovieRatings返回的数据构建评分响应，data为nil时返回没有评分的响应
+func NewMovieRatings(data map[string]interface{}, partial bool) *MovieRatings {
+	result := &MovieRatings{
+		Status:  "success",
+		Ratings: []Rating{},
+		Partial: partial,
+	}
+	if data == nil {
+		return result
+	}
+
+	result.Count, _ = data["count"].(int)
+	result.AvgRating, _ = data["avgRating"].(float64)
+	result.MinRating, _ = data["minRating"].(float64)
+	result.MaxRating, _ = data["maxRating"].(float64)
+
+	updated, _ := data["updatedTime"].(time.Time)
+	list, _ := data["ratings"].([]map[string]interface{})
+	for _, r := range list {
+		rating := Rating{}
+		rating.UserID, _ = r["userId"].(string)
+		rating.Rating, _ = r["rating"].(float64)
+		// 导入的评分时间是秒，随机写入的是毫秒，响应中统一为毫秒
+		raw, _ := r["timestamp"].(int64)
+		if ts := utils.RatingTime(raw); !ts.IsZero() {
+			rating.Timestamp = ts.UnixMilli()
+			if ts.After(updated) {
+				updated = ts
+			}
+		}
+		result.Ratings = append(result.Ratings, rating)
+	}
+	if !updated.IsZero() {
+		result.UpdatedAt = &updated
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewMovieRatingsTimestamps(t *testing.T) {
+	statsTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"count":       2,
+		"avgRating":   4.0,
+		"updatedTime": statsTime,
+		"ratings": []map[string]interface{}{
+			// import.py 导入的 MovieLens 评分，Unix秒
+			{"userId": "1", "rating": 4.5, "timestamp": int64(964982703)},
+			// 随机写入的评分，Unix毫秒
+			{"userId": "2", "rating": 3.5, "timestamp": int64(1700000000123)},
+			{"userId": "3", "rating": 4.0},
+		},
+	}
+
+	result := NewMovieRatings(data, false)
+	want := []int64{964982703000, 1700000000123, 0}
+	for i, rating := range result.Ratings {
+		if rating.Timestamp != want[i] {
+			t.Errorf("用户 %s 的评分时间为 %d，期望 %d", rating.UserID, rating.Timestamp, want[i])
+		}
+	}
+
+	// 毫秒的评分晚于统计时间，秒的评分早于统计时间
+	wantUpdated := time.UnixMilli(1700000000123)
+	if result.UpdatedAt == nil || !result.UpdatedAt.Equal(wantUpdated) {
+		t.Errorf("UpdatedAt为 %v，期望 %v", result.UpdatedAt, wantUpdated)
+	}
+
+	// 只有秒级的旧数据时取统计时间与评分时间中较晚的一个
+	data["ratings"] = []map[string]interface{}{{"userId": "1", "rating": 4.5, "timestamp": int64(1600000000)}}
+	result = NewMovieRatings(data, false)
+	if want := time.Unix(1600000000, 0); result.UpdatedAt == nil || !result.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt为 %v，期望 %v", result.UpdatedAt, want)
+	}
+	data["updatedTime"] = time.Unix(1700000000, 0)
+	result = NewMovieRatings(data, false)
+	if want := time.Unix(1700000000, 0); result.UpdatedAt == nil || !result.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt为 %v，期望 %v", result.UpdatedAt, want)
+	}
+}
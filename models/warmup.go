@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"gohbase/config"
+	"gohbase/utils"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WarmUpCache 预热缓存：评分人数最多的电影的详情和评分统计，以及电影列表（默认每页数量）的前几页
+// 已在缓存中（如从快照恢复）的项直接命中，不会重复读取HBase；返回加载成功和失败的数量
+func WarmUpCache(ctx context.Context, conf *config.CacheWarmupConfig) (int, int) {
+	var tasks []func(ctx context.Context) error
+
+	perPage := config.Current().Limits.DefaultPerPage
+	for page := 1; page <= conf.ListPages; page++ {
+		page := page
+		tasks = append(tasks, func(ctx context.Context) error {
+			_, err := GetMoviesList(ctx, page, perPage)
+			return err
+		})
+	}
+
+	if conf.TopMovies > 0 {
+		movieIDs, err := utils.TopMoviesByRatingCount(ctx, conf.TopMovies)
+		if err != nil {
+			logrus.Warnf("缓存预热: 获取评分人数最多的电影失败: %v", err)
+		}
+		for _, movieID := range movieIDs {
+			movieID := movieID
+			tasks = append(tasks, func(ctx context.Context) error {
+				if _, err := GetMovieByID(ctx, movieID); err != nil {
+					return err
+				}
+				_, err := utils.GetMovieRatingStats(ctx, movieID)
+				return err
+			})
+		}
+	}
+
+	var loaded, failed atomic.Int64
+	sem := make(chan struct{}, conf.Concurrency)
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// 超时或服务关闭，剩余的项不再加载
+			failed.Add(1)
+			continue
+		}
+		wg.Add(1)
+		go func(task func(ctx context.Context) error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := task(ctx); err != nil {
+				failed.Add(1)
+				logrus.Debugf("缓存预热: 加载失败: %v", err)
+				return
+			}
+			loaded.Add(1)
+		}(task)
+	}
+	wg.Wait()
+
+	return int(loaded.Load()), int(failed.Load())
+}
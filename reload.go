@@ -0,0 +1,59 @@
+package main
+
+import (
+	"gohbase/config"
+	"gohbase/utils"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchReload 收到SIGHUP时重新读取配置文件和环境变量
+func watchReload(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		reloadConfig(path)
+	}
+}
+
+// reloadConfig 重新加载配置，校验失败时保留当前配置
+func reloadConfig(path string) {
+	logrus.Info("收到SIGHUP，重新加载配置...")
+
+	next, err := config.Load(path)
+	if err != nil {
+		logrus.Errorf("重新加载配置失败，继续使用当前配置: %v", err)
+		return
+	}
+
+	restartRequired := config.Reload(next)
+	applyRuntimeConfig(config.Current())
+
+	if len(restartRequired) > 0 {
+		logrus.Warnf("以下配置需要重启才能生效，已忽略: %s", strings.Join(restartRequired, ", "))
+	}
+	logrus.Info("配置已重新加载")
+}
+
+// applyRuntimeConfig 应用可以在运行时修改的设置
+// 接口限制、评分统计有效期和随机写入速率在每次使用时读取当前配置，无需在此处理
+func applyRuntimeConfig(cfg *config.Config) {
+	applyLogLevel(cfg.Server.LogLevel)
+	utils.Cache.SetDefaultExpiration(cfg.Cache.DefaultTTL)
+	utils.Cache.SetLimits(cfg.Cache.MaxEntries, cfg.Cache.MaxBytes())
+	utils.Cache.SetStaleTTL(cfg.Cache.StaleTTL)
+	utils.Cache.SetPolicies(cfg.Cache.Policies)
+	utils.InitResilience(&cfg.Resilience)
+}
+
+// applyLogLevel 设置日志级别，配置校验已保证级别有效
+func applyLogLevel(level string) {
+	if parsed, err := logrus.ParseLevel(level); err == nil {
+		logrus.SetLevel(parsed)
+	}
+}
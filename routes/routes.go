@@ -1,68 +1,121 @@
 package routes
 
 import (
+	"gohbase/config"
 	"gohbase/controllers"
+	"gohbase/middleware"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // SetupRouter 设置路由
-func SetupRouter() *gin.Engine {
-	// 创建默认路由
-	router := gin.Default()
+func SetupRouter(cfg *config.Config) *gin.Engine {
+	timeouts := cfg.Timeouts
+
+	// 创建路由，使用logrus记录访问日志以便统一捕获
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AccessLog("/api/system/logs", "/api/system/logs/stream", "/metrics", "/healthz", "/readyz"))
+	router.Use(middleware.Metrics())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.Degraded())
+	router.Use(middleware.CacheTrace())
+	router.Use(middleware.Compress())
 
 	// 添加CORS中间件，允许所有来源、方法和头部
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Cache-Check", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "X-Cache-Hit"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Cache-Check", "X-Requested-With", "X-Request-ID", "X-Admin-Token", "If-None-Match", "If-Modified-Since", "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"Content-Length", "X-Cache-Hit", "X-Request-ID", "X-Degraded", "ETag", "Last-Modified"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// GET /metrics - Prometheus指标
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// 健康检查
+	healthController := &controllers.HealthController{}
+
+	// GET /healthz - 存活检查
+	router.GET("/healthz", healthController.Healthz)
+
+	// GET /readyz - 就绪检查，检查HBase各表、缓存和写入服务
+	router.GET("/readyz", middleware.Timeout(timeouts.Health), healthController.Readyz)
+
 	// 创建API路由组
 	api := router.Group("/api")
 
 	// 创建控制器实例
 	movieController := &controllers.MovieController{}
 	writeController := &controllers.WriteController{}
+	systemController := &controllers.SystemController{}
 
 	// 电影相关路由
 	movies := api.Group("/movies")
 	{
 		// GET /api/movies - 获取电影列表
-		movies.GET("", movieController.GetMovies)
+		movies.GET("", middleware.Timeout(timeouts.MovieList), movieController.GetMovies)
 
 		// GET /api/movies/:id - 获取电影详情
-		movies.GET("/:id", movieController.GetMovie)
+		movies.GET("/:id", middleware.Timeout(timeouts.MovieDetail), movieController.GetMovie)
 
 		// GET /api/movies/random - 获取随机电影
-		movies.GET("/random", movieController.GetRandomMovies)
+		movies.GET("/random", middleware.Timeout(timeouts.RandomMovies), movieController.GetRandomMovies)
 
 		// POST /api/movies/random - 获取随机电影（POST方法）
-		movies.POST("/random", movieController.RandomMoviesPost)
+		movies.POST("/random", middleware.Timeout(timeouts.RandomMovies), movieController.RandomMoviesPost)
 
 		// GET /api/movies/search - 搜索电影
-		movies.GET("/search", movieController.SearchMovies)
+		movies.GET("/search", middleware.Timeout(timeouts.Search), movieController.SearchMovies)
 	}
 
 	// 评分相关路由
 	ratings := api.Group("/ratings")
 	{
 		// GET /api/ratings/movie/:id - 获取电影的所有评分
-		ratings.GET("/movie/:id", movieController.GetMovieRatings)
+		ratings.GET("/movie/:id", middleware.Timeout(timeouts.Ratings), movieController.GetMovieRatings)
 	}
 
-	// 系统日志路由
-	// GET /api/system/logs - 获取系统日志
-	api.GET("/system/logs", movieController.GetSystemLogs)
+	// 系统相关路由
+	system := api.Group("/system")
+	{
+		// GET /api/system/logs - 查询系统日志
+		system.GET("/logs", middleware.Timeout(timeouts.Default), systemController.GetSystemLogs)
+
+		// GET /api/system/logs/stream - 通过SSE实时推送系统日志
+		system.GET("/logs/stream", systemController.StreamSystemLogs)
+
+		// GET /api/system/cache - 获取缓存统计信息
+		system.GET("/cache", middleware.Timeout(timeouts.Default), systemController.GetCacheStats)
 
-	// 添加缓存统计路由
-	// GET /api/system/cache - 获取缓存统计信息
-	api.GET("/system/cache", movieController.GetCacheStats)
+		// 缓存管理，需要管理令牌
+		cacheAdmin := system.Group("/cache", middleware.AdminAuth(), middleware.Timeout(timeouts.Default))
+		{
+			// GET /api/system/cache/keys - 按前缀列出缓存键、过期时间和估算大小
+			cacheAdmin.GET("/keys", systemController.ListCacheKeys)
+
+			// GET /api/system/cache/entry - 获取单个缓存项的元数据
+			cacheAdmin.GET("/entry", systemController.GetCacheEntry)
+
+			// DELETE /api/system/cache/entry - 删除单个缓存项
+			cacheAdmin.DELETE("/entry", systemController.DeleteCacheEntry)
+
+			// POST /api/system/cache/flush - 按前缀清除缓存项
+			cacheAdmin.POST("/flush", systemController.FlushCachePrefix)
+
+			// POST /api/system/cache/sweep - 立即清理过期缓存项
+			cacheAdmin.POST("/sweep", systemController.SweepCache)
+		}
+
+		// GET /api/system/status - 获取HBase连接状态、熔断器状态和重试策略
+		system.GET("/status", middleware.Timeout(timeouts.Default), systemController.GetSystemStatus)
+	}
 
 	// 添加随机写入相关路由
 	write := api.Group("/write")
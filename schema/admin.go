@@ -0,0 +1,267 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"time"
+
+	"github.com/tsuna/gohbase"
+	"github.com/tsuna/gohbase/hrpc"
+	"github.com/tsuna/gohbase/pb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// rpcSender gohbase的AdminClient实现了SendRPC，用于发送AdminClient没有封装的管理请求
+// AdminClient的CreateTable固定使用default命名空间，也没有查询和修改表结构的接口
+type rpcSender interface {
+	SendRPC(rpc hrpc.Call) (proto.Message, error)
+}
+
+// Admin 通过HMaster管理表结构
+type Admin struct {
+	client gohbase.AdminClient
+	sender rpcSender
+}
+
+// NewAdmin 创建管理客户端，HMaster地址由ZooKeeper发现
+func NewAdmin(conf *config.HBaseConfig) (*Admin, error) {
+	options := []gohbase.Option{
+		gohbase.ZookeeperRoot(conf.ZkRoot),
+		gohbase.ZookeeperTimeout(conf.ZkTimeout),
+		gohbase.RegionLookupTimeout(conf.RegionLookupTimeout),
+		gohbase.RegionReadTimeout(conf.RegionReadTimeout),
+	}
+	if conf.EffectiveUser != "" {
+		options = append(options, gohbase.EffectiveUser(conf.EffectiveUser))
+	}
+
+	client := gohbase.NewAdminClient(conf.Quorum(), options...)
+	sender, ok := client.(rpcSender)
+	if !ok {
+		return nil, errors.New("当前gohbase版本的AdminClient不支持发送自定义管理请求")
+	}
+	return &Admin{client: client, sender: sender}, nil
+}
+
+// masterCall 发往HMaster的管理请求
+type masterCall struct {
+	ctx      context.Context
+	method   string
+	request  proto.Message
+	response proto.Message
+	region   hrpc.RegionInfo
+	resultch chan hrpc.RPCResult
+}
+
+func newMasterCall(ctx context.Context, method string, request, response proto.Message) *masterCall {
+	return &masterCall{
+		ctx:      ctx,
+		method:   method,
+		request:  request,
+		response: response,
+		resultch: make(chan hrpc.RPCResult, 1),
+	}
+}
+
+func (c *masterCall) Table() []byte                    { return nil }
+func (c *masterCall) Name() string                     { return c.method }
+func (c *masterCall) Key() []byte                      { return nil }
+func (c *masterCall) Region() hrpc.RegionInfo          { return c.region }
+func (c *masterCall) SetRegion(region hrpc.RegionInfo) { c.region = region }
+func (c *masterCall) ToProto() proto.Message           { return c.request }
+func (c *masterCall) NewResponse() proto.Message       { return proto.Clone(c.response) }
+func (c *masterCall) ResultChan() chan hrpc.RPCResult  { return c.resultch }
+func (c *masterCall) Description() string              { return c.method }
+func (c *masterCall) Context() context.Context         { return c.ctx }
+
+// call 发送管理请求并检查响应类型
+func call[T proto.Message](ctx context.Context, a *Admin, method string, request proto.Message, response T) (T, error) {
+	msg, err := a.sender.SendRPC(newMasterCall(ctx, method, request, response))
+	if err != nil {
+		return response, fmt.Errorf("%s 失败: %w", method, err)
+	}
+	res, ok := msg.(T)
+	if !ok {
+		return response, fmt.Errorf("%s 返回了意外的响应类型 %T", method, msg)
+	}
+	return res, nil
+}
+
+// Namespaces 列出已存在的命名空间
+func (a *Admin) Namespaces(ctx context.Context) (map[string]bool, error) {
+	res, err := call(ctx, a, "ListNamespaceDescriptors",
+		&pb.ListNamespaceDescriptorsRequest{}, &pb.ListNamespaceDescriptorsResponse{})
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]bool, len(res.NamespaceDescriptor))
+	for _, ns := range res.NamespaceDescriptor {
+		namespaces[string(ns.Name)] = true
+	}
+	return namespaces, nil
+}
+
+// Describe 获取表结构，表不存在时返回nil
+func (a *Admin) Describe(ctx context.Context, name string) (*pb.TableSchema, error) {
+	ns, table := splitName(name)
+	res, err := call(ctx, a, "GetTableDescriptors", &pb.GetTableDescriptorsRequest{
+		TableNames: []*pb.TableName{{Namespace: []byte(ns), Qualifier: []byte(table)}},
+	}, &pb.GetTableDescriptorsResponse{})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.TableSchema) == 0 {
+		return nil, nil
+	}
+	return res.TableSchema[0], nil
+}
+
+// CreateNamespace 创建命名空间
+func (a *Admin) CreateNamespace(ctx context.Context, namespace string) error {
+	res, err := call(ctx, a, "CreateNamespace", &pb.CreateNamespaceRequest{
+		NamespaceDescriptor: &pb.NamespaceDescriptor{Name: []byte(namespace)},
+	}, &pb.CreateNamespaceResponse{})
+	if err != nil {
+		return err
+	}
+	return a.waitProcedure(ctx, res)
+}
+
+// CreateTable 按定义创建表，包括预分区
+func (a *Admin) CreateTable(ctx context.Context, spec TableSpec) error {
+	ns, table := splitName(spec.Name)
+	families := make([]*pb.ColumnFamilySchema, 0, len(spec.Families))
+	for _, f := range spec.Families {
+		families = append(families, familySchema(f.Name, f.attributes()))
+	}
+	splitKeys := make([][]byte, len(spec.SplitKeys))
+	for i, key := range spec.SplitKeys {
+		splitKeys[i] = []byte(key)
+	}
+
+	res, err := call(ctx, a, "CreateTable", &pb.CreateTableRequest{
+		TableSchema: &pb.TableSchema{
+			TableName:      &pb.TableName{Namespace: []byte(ns), Qualifier: []byte(table)},
+			ColumnFamilies: families,
+		},
+		SplitKeys: splitKeys,
+	}, &pb.CreateTableResponse{})
+	if err != nil {
+		return err
+	}
+	return a.waitProcedure(ctx, res)
+}
+
+// AddFamily 为已存在的表添加列族
+func (a *Admin) AddFamily(ctx context.Context, name string, family FamilySpec) error {
+	ns, table := splitName(name)
+	res, err := call(ctx, a, "AddColumn", &pb.AddColumnRequest{
+		TableName:      &pb.TableName{Namespace: []byte(ns), Qualifier: []byte(table)},
+		ColumnFamilies: familySchema(family.Name, family.attributes()),
+	}, &pb.AddColumnResponse{})
+	if err != nil {
+		return err
+	}
+	return a.waitProcedure(ctx, res)
+}
+
+// ModifyFamily 修改列族属性，attrs需包含列族的全部属性，未包含的属性会恢复为默认值
+func (a *Admin) ModifyFamily(ctx context.Context, name, family string, attrs map[string]string) error {
+	ns, table := splitName(name)
+	res, err := call(ctx, a, "ModifyColumn", &pb.ModifyColumnRequest{
+		TableName:      &pb.TableName{Namespace: []byte(ns), Qualifier: []byte(table)},
+		ColumnFamilies: familySchema(family, attrs),
+	}, &pb.ModifyColumnResponse{})
+	if err != nil {
+		return err
+	}
+	return a.waitProcedure(ctx, res)
+}
+
+// Close 关闭管理客户端
+func (a *Admin) Close() {
+	if closer, ok := a.client.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+// procIDField 管理请求响应中proc_id的字段号
+const procIDField = 1
+
+// procID 读取响应中的异步操作ID
+// gohbase使用的proto定义中部分响应没有proc_id字段，HBase 2.x返回的该字段会保存在未知字段中
+func procID(res proto.Message) (uint64, bool) {
+	if r, ok := res.(interface{ GetProcId() uint64 }); ok && r.GetProcId() != 0 {
+		return r.GetProcId(), true
+	}
+	unknown := res.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0, false
+		}
+		unknown = unknown[n:]
+		if num == procIDField && typ == protowire.VarintType {
+			id, m := protowire.ConsumeVarint(unknown)
+			return id, m > 0
+		}
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return 0, false
+		}
+		unknown = unknown[m:]
+	}
+	return 0, false
+}
+
+// waitProcedure 等待HMaster上的异步操作完成，响应中没有操作ID时说明操作已同步完成
+func (a *Admin) waitProcedure(ctx context.Context, res proto.Message) error {
+	id, ok := procID(res)
+	if !ok {
+		return nil
+	}
+
+	backoff := 50 * time.Millisecond
+	for {
+		msg, err := a.sender.SendRPC(hrpc.NewGetProcedureState(ctx, id))
+		if err != nil {
+			return fmt.Errorf("查询操作 %d 的状态失败: %w", id, err)
+		}
+		res, ok := msg.(*pb.GetProcedureResultResponse)
+		if !ok {
+			return fmt.Errorf("查询操作状态返回了意外的响应类型 %T", msg)
+		}
+
+		switch res.GetState() {
+		case pb.GetProcedureResultResponse_NOT_FOUND:
+			return fmt.Errorf("操作 %d 不存在", id)
+		case pb.GetProcedureResultResponse_FINISHED:
+			if fe := res.Exception; fe != nil && fe.GenericException != nil {
+				ge := fe.GenericException
+				return fmt.Errorf("操作 %d 失败: %s: %s", id, ge.GetClassName(), ge.GetMessage())
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 2*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// familySchema 构建列族描述
+func familySchema(name string, attrs map[string]string) *pb.ColumnFamilySchema {
+	f := &pb.ColumnFamilySchema{Name: []byte(name)}
+	for _, key := range sortedKeys(attrs) {
+		f.Attributes = append(f.Attributes, &pb.BytesBytesPair{First: []byte(key), Second: []byte(attrs[key])})
+	}
+	return f
+}
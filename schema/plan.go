@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tsuna/gohbase/pb"
+)
+
+// ChangeKind 变更类型
+type ChangeKind string
+
+const (
+	CreateNamespace ChangeKind = "create_namespace"
+	CreateTable     ChangeKind = "create_table"
+	AddFamily       ChangeKind = "add_family"
+	ModifyFamily    ChangeKind = "modify_family"
+)
+
+// AttrDiff 列族属性的差异
+type AttrDiff struct {
+	Name string
+	From string
+	To   string
+}
+
+// Change 使线上集群符合定义所需的一项变更
+type Change struct {
+	Kind      ChangeKind
+	Namespace string            // CreateNamespace
+	Table     TableSpec         // CreateTable，其余类型只使用Table.Name
+	Family    FamilySpec        // AddFamily、ModifyFamily
+	Attrs     map[string]string // ModifyFamily：合并线上属性后的完整属性
+	Diffs     []AttrDiff        // ModifyFamily：发生变化的属性
+}
+
+// String 返回变更的描述
+func (c Change) String() string {
+	switch c.Kind {
+	case CreateNamespace:
+		return fmt.Sprintf("+ 创建命名空间 %s", c.Namespace)
+	case CreateTable:
+		families := make([]string, len(c.Table.Families))
+		for i, f := range c.Table.Families {
+			families[i] = describeFamily(f)
+		}
+		desc := fmt.Sprintf("+ 创建表 %s，列族: %s", c.Table.Name, strings.Join(families, "; "))
+		if len(c.Table.SplitKeys) > 0 {
+			desc += fmt.Sprintf("，预分区为 %d 个region", len(c.Table.SplitKeys)+1)
+		}
+		return desc
+	case AddFamily:
+		return fmt.Sprintf("+ 表 %s 添加列族 %s", c.Table.Name, describeFamily(c.Family))
+	case ModifyFamily:
+		diffs := make([]string, len(c.Diffs))
+		for i, d := range c.Diffs {
+			diffs[i] = fmt.Sprintf("%s %s → %s", d.Name, d.From, d.To)
+		}
+		return fmt.Sprintf("~ 表 %s 修改列族 %s: %s", c.Table.Name, c.Family.Name, strings.Join(diffs, ", "))
+	default:
+		return string(c.Kind)
+	}
+}
+
+// describeFamily 列族定义的简要描述
+func describeFamily(f FamilySpec) string {
+	attrs := f.attributes()
+	parts := make([]string, 0, len(managedAttrs))
+	for _, name := range managedAttrs {
+		parts = append(parts, name+"="+attrs[name])
+	}
+	return f.Name + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// Plan 线上集群与定义之间的差异
+type Plan struct {
+	Changes   []Change
+	Unchanged []string // 已符合定义的表
+	Notes     []string // 不会自动处理的差异，如未定义的列族
+}
+
+// Empty 判断是否不需要任何变更
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Print 输出变更计划
+func (p *Plan) Print(w io.Writer) {
+	for _, c := range p.Changes {
+		fmt.Fprintln(w, c.String())
+	}
+	for _, name := range p.Unchanged {
+		fmt.Fprintf(w, "= 表 %s 已符合定义\n", name)
+	}
+	for _, note := range p.Notes {
+		fmt.Fprintf(w, "! %s\n", note)
+	}
+	if p.Empty() {
+		fmt.Fprintln(w, "无需变更")
+	} else {
+		fmt.Fprintf(w, "共 %d 项变更\n", len(p.Changes))
+	}
+}
+
+// BuildPlan 对比线上集群与定义，生成变更计划，不修改集群
+func BuildPlan(ctx context.Context, admin *Admin, specs []TableSpec) (*Plan, error) {
+	namespaces, err := admin.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	created := map[string]bool{} // 计划中新建的命名空间，其中的表都需要创建
+	for _, spec := range specs {
+		ns, _ := splitName(spec.Name)
+		if !namespaces[ns] {
+			namespaces[ns] = true
+			created[ns] = true
+			plan.Changes = append(plan.Changes, Change{Kind: CreateNamespace, Namespace: ns})
+		}
+		if created[ns] {
+			plan.Changes = append(plan.Changes, Change{Kind: CreateTable, Table: spec})
+			continue
+		}
+
+		live, err := admin.Describe(ctx, spec.Name)
+		if err != nil {
+			return nil, err
+		}
+		if live == nil {
+			plan.Changes = append(plan.Changes, Change{Kind: CreateTable, Table: spec})
+			continue
+		}
+
+		changes, notes := diffTable(spec, live)
+		if len(changes) == 0 {
+			plan.Unchanged = append(plan.Unchanged, spec.Name)
+		}
+		plan.Changes = append(plan.Changes, changes...)
+		plan.Notes = append(plan.Notes, notes...)
+	}
+	return plan, nil
+}
+
+// diffTable 对比已存在的表与定义
+func diffTable(spec TableSpec, live *pb.TableSchema) ([]Change, []string) {
+	liveFamilies := make(map[string]map[string]string, len(live.ColumnFamilies))
+	for _, f := range live.ColumnFamilies {
+		attrs := make(map[string]string, len(f.Attributes))
+		for _, a := range f.Attributes {
+			attrs[string(a.First)] = string(a.Second)
+		}
+		liveFamilies[string(f.Name)] = attrs
+	}
+
+	var changes []Change
+	var notes []string
+	for _, family := range spec.Families {
+		liveAttrs, ok := liveFamilies[family.Name]
+		delete(liveFamilies, family.Name)
+		if !ok {
+			changes = append(changes, Change{Kind: AddFamily, Table: spec, Family: family})
+			continue
+		}
+
+		desired := family.attributes()
+		merged := make(map[string]string, len(liveAttrs)+len(desired))
+		for k, v := range liveAttrs {
+			merged[k] = v
+		}
+		var diffs []AttrDiff
+		for _, name := range managedAttrs {
+			current, ok := liveAttrs[name]
+			if !ok {
+				current = liveDefaults[name]
+			}
+			if !strings.EqualFold(current, desired[name]) {
+				diffs = append(diffs, AttrDiff{Name: name, From: current, To: desired[name]})
+				if name == attrTTL && shorterTTL(desired[name], current) {
+					notes = append(notes, fmt.Sprintf("表 %s 的列族 %s 的TTL将缩短为 %s 秒，已有的早于该时长的数据会在下次major compaction时被删除",
+						spec.Name, family.Name, desired[name]))
+				}
+			}
+			merged[name] = desired[name]
+		}
+		if len(diffs) > 0 {
+			changes = append(changes, Change{Kind: ModifyFamily, Table: spec, Family: family, Attrs: merged, Diffs: diffs})
+		}
+	}
+
+	for _, name := range sortedKeys(liveFamilies) {
+		notes = append(notes, fmt.Sprintf("表 %s 中的列族 %s 未在定义中，不会被删除", spec.Name, name))
+	}
+	return changes, notes
+}
+
+// shorterTTL 判断TTL（秒）是否比原来的短，无法解析的值视为永不过期
+func shorterTTL(to, from string) bool {
+	parse := func(v string) int64 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			n, _ = strconv.ParseInt(ttlForever, 10, 64)
+		}
+		return n
+	}
+	return parse(to) < parse(from)
+}
+
+// Apply 按顺序执行变更计划，遇到错误时停止，返回已完成的变更数
+func Apply(ctx context.Context, admin *Admin, plan *Plan, progress io.Writer) (int, error) {
+	for i, c := range plan.Changes {
+		fmt.Fprintln(progress, c.String())
+
+		var err error
+		switch c.Kind {
+		case CreateNamespace:
+			err = admin.CreateNamespace(ctx, c.Namespace)
+		case CreateTable:
+			err = admin.CreateTable(ctx, c.Table)
+		case AddFamily:
+			err = admin.AddFamily(ctx, c.Table.Name, c.Family)
+		case ModifyFamily:
+			err = admin.ModifyFamily(ctx, c.Table.Name, c.Family.Name, c.Attrs)
+		}
+		if err != nil {
+			return i, fmt.Errorf("执行变更失败 [%s]: %w", c.String(), err)
+		}
+	}
+	return len(plan.Changes), nil
+}
+
+// sortedKeys 按字母顺序返回键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
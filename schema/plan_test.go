@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"gohbase/config"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsuna/gohbase/pb"
+)
+
+// liveTable 构造线上表结构，attrs为列族名到属性的映射
+func liveTable(families map[string]map[string]string) *pb.TableSchema {
+	live := &pb.TableSchema{}
+	for name, attrs := range families {
+		family := &pb.ColumnFamilySchema{Name: []byte(name)}
+		for k, v := range attrs {
+			family.Attributes = append(family.Attributes, &pb.BytesBytesPair{First: []byte(k), Second: []byte(v)})
+		}
+		live.ColumnFamilies = append(live.ColumnFamilies, family)
+	}
+	return live
+}
+
+func TestDiffTable(t *testing.T) {
+	info := FamilySpec{Name: "info", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}
+	spec := TableSpec{Name: "movies", Families: []FamilySpec{info}}
+
+	cases := []struct {
+		name      string
+		spec      TableSpec
+		live      map[string]map[string]string
+		wantKinds []ChangeKind
+		wantDiffs []AttrDiff
+		wantAttrs map[string]string
+		wantNotes []string // 提示中应包含的内容
+	}{
+		{
+			name: "一致",
+			spec: spec,
+			live: map[string]map[string]string{"info": {"COMPRESSION": "GZ", "TTL": ttlForever, "VERSIONS": "1", "BLOOMFILTER": "ROW"}},
+		},
+		{
+			// 缺少的属性按HBase的默认值比较：NONE、永不过期、1个版本、ROW
+			name:      "缺少属性时使用默认值",
+			spec:      spec,
+			live:      map[string]map[string]string{"info": {}},
+			wantKinds: []ChangeKind{ModifyFamily},
+			wantDiffs: []AttrDiff{{Name: "COMPRESSION", From: "NONE", To: "GZ"}},
+			wantAttrs: map[string]string{"COMPRESSION": "GZ", "TTL": ttlForever, "VERSIONS": "1", "BLOOMFILTER": "ROW"},
+		},
+		{
+			name: "大小写不同视为一致",
+			spec: spec,
+			live: map[string]map[string]string{"info": {"COMPRESSION": "gz", "BLOOMFILTER": "row"}},
+		},
+		{
+			// 合并后的属性保留线上未管理的属性
+			name:      "合并线上属性",
+			spec:      spec,
+			live:      map[string]map[string]string{"info": {"COMPRESSION": "SNAPPY", "VERSIONS": "3", "BLOCKSIZE": "65536"}},
+			wantKinds: []ChangeKind{ModifyFamily},
+			wantDiffs: []AttrDiff{{Name: "COMPRESSION", From: "SNAPPY", To: "GZ"}, {Name: "VERSIONS", From: "3", To: "1"}},
+			wantAttrs: map[string]string{"COMPRESSION": "GZ", "TTL": ttlForever, "VERSIONS": "1", "BLOOMFILTER": "ROW", "BLOCKSIZE": "65536"},
+		},
+		{
+			name:      "缺少列族，多余的列族只提示",
+			spec:      spec,
+			live:      map[string]map[string]string{"old": {}},
+			wantKinds: []ChangeKind{AddFamily},
+			wantNotes: []string{"列族 old 未在定义中"},
+		},
+		{
+			name:      "缩短TTL时提示",
+			spec:      TableSpec{Name: "events", Families: []FamilySpec{{Name: "e", Compression: "GZ", TTL: time.Hour, MaxVersions: 1, BloomFilter: "ROW"}}},
+			live:      map[string]map[string]string{"e": {"COMPRESSION": "GZ"}},
+			wantKinds: []ChangeKind{ModifyFamily},
+			wantDiffs: []AttrDiff{{Name: "TTL", From: ttlForever, To: "3600"}},
+			wantAttrs: map[string]string{"COMPRESSION": "GZ", "TTL": "3600", "VERSIONS": "1", "BLOOMFILTER": "ROW"},
+			wantNotes: []string{"TTL将缩短为 3600 秒"},
+		},
+		{
+			name:      "延长TTL时不提示",
+			spec:      spec,
+			live:      map[string]map[string]string{"info": {"COMPRESSION": "GZ", "TTL": "3600"}},
+			wantKinds: []ChangeKind{ModifyFamily},
+			wantDiffs: []AttrDiff{{Name: "TTL", From: "3600", To: ttlForever}},
+			wantAttrs: map[string]string{"COMPRESSION": "GZ", "TTL": ttlForever, "VERSIONS": "1", "BLOOMFILTER": "ROW"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, notes := diffTable(tc.spec, liveTable(tc.live))
+
+			var kinds []ChangeKind
+			for _, c := range changes {
+				kinds = append(kinds, c.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tc.wantKinds) {
+				t.Fatalf("变更为 %v，期望 %v", kinds, tc.wantKinds)
+			}
+			if len(changes) > 0 && changes[0].Kind == ModifyFamily {
+				if !reflect.DeepEqual(changes[0].Diffs, tc.wantDiffs) {
+					t.Errorf("差异为 %v，期望 %v", changes[0].Diffs, tc.wantDiffs)
+				}
+				if !reflect.DeepEqual(changes[0].Attrs, tc.wantAttrs) {
+					t.Errorf("合并后的属性为 %v，期望 %v", changes[0].Attrs, tc.wantAttrs)
+				}
+			}
+			if len(notes) != len(tc.wantNotes) {
+				t.Fatalf("提示为 %v，期望 %d 条", notes, len(tc.wantNotes))
+			}
+			for i, want := range tc.wantNotes {
+				if !strings.Contains(notes[i], want) {
+					t.Errorf("提示 %q 中没有 %q", notes[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDesiredAvgRatingsNeverExpire(t *testing.T) {
+	for _, spec := range Desired(config.Default().Tables, "", "") {
+		if spec.Logical != "avg_ratings" {
+			continue
+		}
+		for _, family := range spec.Families {
+			if family.TTL != 0 {
+				t.Errorf("avg_ratings的列族 %s 不应设置TTL", family.Name)
+			}
+		}
+		return
+	}
+	t.Fatal("定义中没有avg_ratings")
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"fmt"
+	"gohbase/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HBase列族属性名
+const (
+	attrCompression = "COMPRESSION"
+	attrTTL         = "TTL"
+	attrVersions    = "VERSIONS"
+	attrBloomFilter = "BLOOMFILTER"
+)
+
+// ttlForever HBase表示永不过期的TTL值（秒）
+const ttlForever = "2147483647"
+
+// liveDefaults 线上列族缺少某个属性时HBase使用的默认值
+var liveDefaults = map[string]string{
+	attrCompression: "NONE",
+	attrTTL:         ttlForever,
+	attrVersions:    "1",
+	attrBloomFilter: "ROW",
+}
+
+// managedAttrs 由schema管理的列族属性，按输出顺序排列
+var managedAttrs = []string{attrCompression, attrTTL, attrVersions, attrBloomFilter}
+
+// FamilySpec 列族定义
+type FamilySpec struct {
+	Name        string
+	Compression string        // NONE、GZ、SNAPPY、LZ4、ZSTD等，需集群已安装对应的编解码库
+	TTL         time.Duration // 数据保留时间，0表示永不过期
+	MaxVersions int           // 保留的版本数
+	BloomFilter string        // NONE、ROW、ROWCOL
+}
+
+// TableSpec 表定义
+type TableSpec struct {
+	Logical   string // 逻辑表名，与配置文件tables下的键一致
+	Name      string // 带命名空间的实际表名
+	Families  []FamilySpec
+	SplitKeys []string // 创建表时的预分区键，已存在的表不会重新分区
+}
+
+// digitSplits 行键以数字ID开头的表按首位数字预分区为10个region
+var digitSplits = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
+// writeLogical 随机写入使用的逻辑表，配置了write_generator.namespace时在该命名空间中也需要创建
+var writeLogical = map[string]bool{"ratings": true, "movie_ratings": true}
+
+// Desired 返回服务需要的表结构，表名按配置解析命名空间
+// writeNamespace与tables.namespace不同时，随机写入的表在writeNamespace中也需要存在，同名的表只保留一个
+// compression不为空时覆盖所有列族的压缩算法
+func Desired(tables config.TableConfig, writeNamespace, compression string) []TableSpec {
+	specs := tableSpecs(tables.Qualified(""))
+	if writeNamespace != "" {
+		seen := make(map[string]bool, len(specs))
+		for _, spec := range specs {
+			seen[spec.Name] = true
+		}
+		for _, spec := range tableSpecs(tables.Qualified(writeNamespace)) {
+			if writeLogical[spec.Logical] && !seen[spec.Name] {
+				seen[spec.Name] = true
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	if compression != "" {
+		for i := range specs {
+			for j := range specs[i].Families {
+				specs[i].Families[j].Compression = strings.ToUpper(compression)
+			}
+		}
+	}
+	return specs
+}
+
+// tableSpecs 返回一组表名对应的表结构
+func tableSpecs(t config.TableConfig) []TableSpec {
+	return []TableSpec{
+		{
+			Logical:  "movies",
+			Name:     t.Movies,
+			Families: []FamilySpec{{Name: "info", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+		},
+		{
+			Logical:  "links",
+			Name:     t.Links,
+			Families: []FamilySpec{{Name: "external", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+		},
+		{
+			// 按评分范围查询和缓存预热通过扫描此表查找电影，统计只在查看电影时重新计算，不能设置TTL
+			Logical:  "avg_ratings",
+			Name:     t.AvgRatings,
+			Families: []FamilySpec{{Name: "stats", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+		},
+		{
+			Logical:   "ratings",
+			Name:      t.Ratings,
+			Families:  []FamilySpec{{Name: "data", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+			SplitKeys: digitSplits,
+		},
+		{
+			Logical:   "movie_ratings",
+			Name:      t.MovieRatings,
+			Families:  []FamilySpec{{Name: "data", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+			SplitKeys: digitSplits,
+		},
+		{
+			Logical:  "tags",
+			Name:     t.Tags,
+			Families: []FamilySpec{{Name: "data", Compression: "GZ", MaxVersions: 1, BloomFilter: "ROW"}},
+		},
+		{
+			// 缓存失效事件只需要保留到所有实例读取之后
+			Logical:  "cache_invalidations",
+			Name:     t.CacheInvalidations,
+			Families: []FamilySpec{{Name: "e", Compression: "GZ", TTL: time.Hour, MaxVersions: 1, BloomFilter: "NONE"}},
+		},
+	}
+}
+
+// ValidateCompression 检查压缩算法名称
+func ValidateCompression(compression string) error {
+	switch strings.ToUpper(compression) {
+	case "", "NONE", "GZ", "SNAPPY", "LZO", "LZ4", "ZSTD", "BZIP2":
+		return nil
+	default:
+		return fmt.Errorf("无效的压缩算法: %s，有效的选项包括: NONE, GZ, SNAPPY, LZO, LZ4, ZSTD, BZIP2", compression)
+	}
+}
+
+// attributes 转换为HBase列族属性
+func (f FamilySpec) attributes() map[string]string {
+	ttl := ttlForever
+	if f.TTL > 0 {
+		ttl = strconv.FormatInt(int64(f.TTL/time.Second), 10)
+	}
+	return map[string]string{
+		attrCompression: strings.ToUpper(f.Compression),
+		attrTTL:         ttl,
+		attrVersions:    strconv.Itoa(f.MaxVersions),
+		attrBloomFilter: strings.ToUpper(f.BloomFilter),
+	}
+}
+
+// splitName 把 namespace:table 拆分为命名空间和表名，未写命名空间时为default
+func splitName(name string) (namespace, table string) {
+	if ns, t, ok := strings.Cut(name, ":"); ok {
+		return ns, t
+	}
+	return "default", name
+}
@@ -1,15 +1,24 @@
 package utils
 
 import (
+	"container/list"
+	"context"
+	"gohbase/config"
+	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 缓存项结构
 type CacheItem struct {
 	Value      interface{}
 	Expiration int64
+	StaleUntil int64 // 过期后仍可提供旧值并在后台刷新的截止时间，0表示不提供
 }
 
 // 是否已过期
@@ -20,22 +29,72 @@ func (item CacheItem) Expired() bool {
 	return time.Now().UnixNano() > item.Expiration
 }
 
+// cacheEntry LRU链表中的缓存项
+type cacheEntry struct {
+	key      string
+	prefix   string
+	item     CacheItem
+	size     int64         // 估算占用的字节数，包括键和固定开销
+	tags     []string      // 依赖标签，标签失效时缓存项被删除
+	prefixEl *list.Element // 在同前缀LRU链表中的位置
+}
+
+// prefixCounters 一类缓存项的访问计数
+type prefixCounters struct {
+	hits   int64
+	misses int64
+	stale  int64
+}
+
 // 内存缓存实现
+// 设置了容量上限时按LRU淘汰：每次读写把缓存项移到链表头部，超出上限时从尾部淘汰
 type MemoryCache struct {
-	items             map[string]CacheItem
+	items             map[string]*list.Element
+	lru               *list.List                     // 元素为*cacheEntry，最近使用的在前
+	bytes             int64                          // 所有缓存项的估算大小
+	maxEntries        int                            // 最大缓存项数，0表示不限制
+	maxBytes          int64                          // 估算内存上限，0表示不限制
+	evictions         int64                          // 因超出容量被淘汰的缓存项数
+	expirations       int64                          // 被清理的过期缓存项数
+	invalidations     int64                          // 因数据写入失效被删除的缓存项数
+	tagIndex          map[string]map[string]struct{} // 标签到缓存键的索引
+	invalidationSeq   uint64                         // 失效序号，每次按标签或前缀失效时递增
+	tagVersions       map[string]uint64              // 标签最近一次失效时的序号，只在有加载进行时记录，用于丢弃加载期间已失效的结果
+	prefixVersion     uint64                         // 最近一次按前缀失效时的序号
+	loadVersions      map[uint64]int                 // 进行中的加载开始时的失效序号及加载数，早于最早加载的标签序号不再需要
+	policies          map[string]config.CachePolicy  // 按键前缀的缓存策略
+	prefixLRU         map[string]*list.List          // 每个前缀的LRU链表，用于按前缀限制数量
 	mu                sync.RWMutex
 	defaultExpiration time.Duration
+	staleTTL          time.Duration // 过期后仍可提供旧值的时长，只用于GetOrLoad写入的缓存项
 	cleanupInterval   time.Duration
 	stopCleanup       chan bool
-	hitCount          int64        // 缓存命中计数
-	missCount         int64        // 缓存未命中计数
-	hitCountMu        sync.RWMutex // 命中计数锁，避免与主缓存锁冲突
+	stopOnce          sync.Once
+	hitCount          int64                      // 缓存命中计数
+	missCount         int64                      // 缓存未命中计数
+	staleCount        int64                      // 提供旧值的次数，同时计入命中
+	prefixCounts      map[string]*prefixCounters // 按前缀的访问计数
+	hitCountMu        sync.RWMutex               // 命中计数锁，避免与主缓存锁冲突
+	serveStale        bool                       // 降级模式下继续提供并保留已过期的缓存项
+	loads             map[string]*loadCall       // 正在进行的加载
+	loadMu            sync.Mutex
+	loadCount         atomic.Int64  // 实际执行的加载次数
+	coalesced         atomic.Int64  // 等待其他请求加载结果的次数
+	shared            CacheBackend  // 共享缓存（L2），为nil时只使用进程内缓存
+	l1TTL             time.Duration // 使用共享缓存时进程内缓存项的最长有效期，0表示不限制
 }
 
 // 创建新的内存缓存
 func NewMemoryCache(defaultExpiration, cleanupInterval time.Duration) *MemoryCache {
 	cache := &MemoryCache{
-		items:             make(map[string]CacheItem),
+		items:             make(map[string]*list.Element),
+		lru:               list.New(),
+		loads:             make(map[string]*loadCall),
+		tagIndex:          make(map[string]map[string]struct{}),
+		tagVersions:       make(map[string]uint64),
+		loadVersions:      make(map[uint64]int),
+		prefixLRU:         make(map[string]*list.List),
+		prefixCounts:      make(map[string]*prefixCounters),
 		defaultExpiration: defaultExpiration,
 		cleanupInterval:   cleanupInterval,
 		stopCleanup:       make(chan bool),
@@ -53,71 +112,333 @@ func NewMemoryCache(defaultExpiration, cleanupInterval time.Duration) *MemoryCac
 
 // 设置缓存项，使用默认过期时间
 func (c *MemoryCache) Set(key string, value interface{}) {
-	c.SetWithExpiration(key, value, c.defaultExpiration)
+	c.SetWithExpiration(key, value, 0)
 }
 
 // 设置缓存项，指定过期时间
+// 单个缓存项的估算大小超过内存上限时不写入
 func (c *MemoryCache) SetWithExpiration(key string, value interface{}, duration time.Duration) {
+	c.set(key, value, duration, setOptions{})
+}
+
+// SetWithTags 设置缓存项并指定依赖标签，任一标签失效时缓存项被删除
+func (c *MemoryCache) SetWithTags(key string, value interface{}, duration time.Duration, tags ...string) {
+	c.set(key, value, duration, setOptions{tags: tags})
+}
+
+// setOptions 写入缓存项的附加选项
+type setOptions struct {
+	tags         []string
+	stale        bool   // 设置过期后可提供旧值的截止时间
+	checkVersion bool   // 只有依赖的标签自version记录后没有失效过才写入
+	version      uint64 // beginLoad的返回值
+	expiresAt    int64  // 不为0时直接使用该过期时间，不再按策略计算，用于从快照恢复
+	staleUntil   int64  // 与expiresAt一起使用，可提供旧值的截止时间
+	local        bool   // 只写入进程内缓存，不写入共享缓存
+}
+
+// set 写入缓存项，返回是否写入；使用共享缓存时同时按完整的过期时间写入共享缓存
+func (c *MemoryCache) set(key string, value interface{}, duration time.Duration, opts setOptions) bool {
+	stored, duration := c.store(key, value, duration, opts)
+	if stored && c.shared != nil && !opts.local && duration > 0 {
+		if _, empty := value.(emptyResult); empty {
+			value = nil
+		}
+		c.shared.SetWithTags(key, value, duration, opts.tags...)
+	}
+	return stored
+}
+
+// store 写入进程内缓存，返回是否写入以及按策略计算的过期时长
+func (c *MemoryCache) store(key string, value interface{}, duration time.Duration, opts setOptions) (bool, time.Duration) {
 	var expiration int64
+	size := estimateSize(value) + int64(len(key)) + entryOverhead
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if opts.checkVersion && c.invalidatedSince(opts.tags, opts.version) {
+		// 加载期间数据已被修改，加载的结果可能是旧数据
+		return false, 0
+	}
+
+	prefix := keyPrefix(key)
+	policy, hasPolicy := c.policies[prefix]
 
-	if duration == 0 {
-		// 0 表示使用默认过期时间
+	if hasPolicy && !policy.CacheEmpty && isEmptyValue(value) {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+			c.updateGauges()
+		}
+		return false, 0
+	}
+
+	if duration == 0 && opts.expiresAt == 0 {
+		// 0 表示使用该类缓存项的过期时间，未配置时使用默认过期时间
 		duration = c.defaultExpiration
+		if policy.TTL > 0 {
+			duration = policy.TTL
+		}
+	}
+	if duration > 0 && policy.Jitter > 0 {
+		duration += time.Duration(rand.Float64() * policy.Jitter * float64(duration))
 	}
 
-	if duration > 0 {
+	if opts.expiresAt != 0 {
+		expiration = opts.expiresAt
+	} else if duration > 0 {
 		expiration = time.Now().Add(duration).UnixNano()
 	}
+	if c.shared != nil && c.l1TTL > 0 && expiration > 0 {
+		// 其他实例修改数据后，本实例最多在l1TTL内读到旧数据
+		expiration = min(expiration, time.Now().Add(c.l1TTL).UnixNano())
+	}
 
-	c.mu.Lock()
-	c.items[key] = CacheItem{
+	if c.maxBytes > 0 && size > c.maxBytes {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+		c.evictions++
+		cacheEvictions.WithLabelValues(keyPrefix(key), "oversize").Inc()
+		c.updateGauges()
+		return false, 0
+	}
+
+	item := CacheItem{
 		Value:      value,
 		Expiration: expiration,
 	}
-	c.mu.Unlock()
+	if opts.stale && c.staleTTL > 0 && expiration > 0 {
+		item.StaleUntil = expiration + int64(c.staleTTL)
+	}
+	if opts.expiresAt != 0 {
+		item.StaleUntil = opts.staleUntil
+	}
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		c.unindexTags(entry)
+		c.bytes += size - entry.size
+		entry.item, entry.size, entry.tags = item, size, opts.tags
+		c.indexTags(entry)
+		c.touch(el)
+	} else {
+		entry := &cacheEntry{key: key, prefix: prefix, item: item, size: size, tags: opts.tags}
+		c.items[key] = c.lru.PushFront(entry)
+		prefixList, ok := c.prefixLRU[prefix]
+		if !ok {
+			prefixList = list.New()
+			c.prefixLRU[prefix] = prefixList
+		}
+		entry.prefixEl = prefixList.PushFront(entry)
+		c.indexTags(entry)
+		c.bytes += size
+	}
+
+	c.evictPrefixOverflow(prefix, policy.MaxEntries)
+	c.evictOverflow()
+	c.updateGauges()
+	return true, duration
 }
 
-// 获取缓存项
+// 获取缓存项，进程内未命中时查找共享缓存
 func (c *MemoryCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	el, found := c.items[key]
+	var item CacheItem
+	if found {
+		item = el.Value.(*cacheEntry).item
+	}
+
+	// 如果未找到或已过期，返回未找到；降级模式下已过期的项仍然返回
+	if !found || (item.Expired() && !c.serveStale) {
+		c.mu.Unlock()
+		// 命中统计只反映进程内缓存，共享缓存有单独的统计
+		c.recordMiss(key)
+		return c.getShared(key, setOptions{})
+	}
+	c.touch(el)
+	c.mu.Unlock()
+
+	c.recordHit(key)
+	if _, ok := item.Value.(emptyResult); ok {
+		return nil, true
+	}
+	return item.Value, true
+}
+
+// peek 读取缓存项但不计入命中统计，也不改变淘汰顺序，用于内部检查
+func (c *MemoryCache) peek(key string) (interface{}, bool) {
 	c.mu.RLock()
-	item, found := c.items[key]
-	c.mu.RUnlock()
+	defer c.mu.RUnlock()
 
-	// 如果未找到或已过期，返回未找到
-	if !found || item.Expired() {
-		c.recordMiss()
+	el, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	item := el.Value.(*cacheEntry).item
+	if item.Expired() {
 		return nil, false
 	}
-
-	c.recordHit()
 	return item.Value, true
 }
 
+// Len 获取缓存项数量（包括尚未清理的过期项）
+func (c *MemoryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// 获取缓存项，并在当前Span上记录命中或未命中事件
+func (c *MemoryCache) GetContext(ctx context.Context, key string) (interface{}, bool) {
+	value, found := c.Get(key)
+	traceCacheLookup(ctx, found)
+
+	event := "cache.miss"
+	if found {
+		event = "cache.hit"
+	}
+	trace.SpanFromContext(ctx).AddEvent(event, trace.WithAttributes(attribute.String("cache.key", key)))
+
+	return value, found
+}
+
 // 记录缓存命中
-func (c *MemoryCache) recordHit() {
+func (c *MemoryCache) recordHit(key string) {
 	c.hitCountMu.Lock()
 	c.hitCount++
+	c.counters(key).hits++
 	c.hitCountMu.Unlock()
+	cacheRequests.WithLabelValues(keyPrefix(key), "hit").Inc()
 }
 
 // 记录缓存未命中
-func (c *MemoryCache) recordMiss() {
+func (c *MemoryCache) recordMiss(key string) {
 	c.hitCountMu.Lock()
 	c.missCount++
+	c.counters(key).misses++
 	c.hitCountMu.Unlock()
+	cacheRequests.WithLabelValues(keyPrefix(key), "miss").Inc()
+}
+
+// counters 获取键所属前缀的访问计数，调用方需持有hitCountMu
+func (c *MemoryCache) counters(key string) *prefixCounters {
+	prefix := keyPrefix(key)
+	counters, ok := c.prefixCounts[prefix]
+	if !ok {
+		counters = &prefixCounters{}
+		c.prefixCounts[prefix] = counters
+	}
+	return counters
+}
+
+// 删除缓存项，返回缓存项是否存在；使用共享缓存时同时从共享缓存删除
+func (c *MemoryCache) Delete(key string) bool {
+	ok := c.deleteLocal(key)
+	if c.shared != nil && c.shared.Delete(key) {
+		ok = true
+	}
+	return ok
 }
 
-// 删除缓存项
-func (c *MemoryCache) Delete(key string) {
+// deleteLocal 删除进程内的缓存项
+func (c *MemoryCache) deleteLocal(key string) bool {
 	c.mu.Lock()
-	delete(c.items, key)
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok {
+		c.removeElement(el)
+		c.updateGauges()
+	}
+	return ok
 }
 
 // 清空所有缓存项
 func (c *MemoryCache) Flush() {
 	c.mu.Lock()
-	c.items = make(map[string]CacheItem)
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.prefixLRU = make(map[string]*list.List)
+	c.tagIndex = make(map[string]map[string]struct{})
+	c.bytes = 0
+	c.updateGauges()
+	c.mu.Unlock()
+}
+
+// SetLimits 设置最大缓存项数和估算内存上限（字节），0表示不限制，超出的缓存项立即淘汰
+func (c *MemoryCache) SetLimits(maxEntries int, maxBytes int64) {
+	c.mu.Lock()
+	c.maxEntries = maxEntries
+	c.maxBytes = maxBytes
+	c.evictOverflow()
+	c.updateGauges()
+	c.mu.Unlock()
+}
+
+// removeElement 从map和LRU链表中移除缓存项，调用方需持有写锁
+func (c *MemoryCache) removeElement(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.items, entry.key)
+	if prefixList, ok := c.prefixLRU[entry.prefix]; ok {
+		prefixList.Remove(entry.prefixEl)
+		if prefixList.Len() == 0 {
+			delete(c.prefixLRU, entry.prefix)
+		}
+	}
+	c.unindexTags(entry)
+	c.bytes -= entry.size
+}
+
+// touch 把缓存项移到LRU链表头部，调用方需持有写锁
+func (c *MemoryCache) touch(el *list.Element) {
+	c.lru.MoveToFront(el)
+	entry := el.Value.(*cacheEntry)
+	if prefixList, ok := c.prefixLRU[entry.prefix]; ok {
+		prefixList.MoveToFront(entry.prefixEl)
+	}
+}
+
+// evictPrefixOverflow 淘汰该前缀最久未使用的缓存项，直到不超过该前缀的数量上限，调用方需持有写锁
+func (c *MemoryCache) evictPrefixOverflow(prefix string, maxEntries int) {
+	prefixList, ok := c.prefixLRU[prefix]
+	for ok && maxEntries > 0 && prefixList.Len() > maxEntries {
+		entry := prefixList.Back().Value.(*cacheEntry)
+		c.removeElement(c.items[entry.key])
+		c.evictions++
+		cacheEvictions.WithLabelValues(prefix, "capacity").Inc()
+	}
+}
+
+// evictOverflow 从最久未使用的一端淘汰缓存项，直到不超过容量上限，调用方需持有写锁
+// 降级模式下同样淘汰，内存上限优先于保留过期数据
+func (c *MemoryCache) evictOverflow() {
+	for c.lru.Len() > 0 &&
+		((c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		entry := c.lru.Back().Value.(*cacheEntry)
+		c.removeElement(c.lru.Back())
+		c.evictions++
+		cacheEvictions.WithLabelValues(keyPrefix(entry.key), "capacity").Inc()
+	}
+}
+
+// updateGauges 更新缓存大小指标，调用方需持有写锁
+func (c *MemoryCache) updateGauges() {
+	cacheEntries.Set(float64(c.lru.Len()))
+	cacheBytes.Set(float64(c.bytes))
+}
+
+// SetDefaultExpiration 修改默认过期时间，只影响之后写入的缓存项
+func (c *MemoryCache) SetDefaultExpiration(d time.Duration) {
+	c.mu.Lock()
+	c.defaultExpiration = d
+	c.mu.Unlock()
+}
+
+// SetServeStale 设置是否提供已过期的缓存项，HBase不可用时开启，恢复后关闭
+func (c *MemoryCache) SetServeStale(serveStale bool) {
+	c.mu.Lock()
+	c.serveStale = serveStale
 	c.mu.Unlock()
 }
 
@@ -129,42 +450,77 @@ func (c *MemoryCache) startCleanupTimer() {
 	for {
 		select {
 		case <-ticker.C:
-			c.deleteExpired()
+			c.DeleteExpired()
 		case <-c.stopCleanup:
 			return
 		}
 	}
 }
 
-// 停止定时清理
+// 停止定时清理，可重复调用
 func (c *MemoryCache) StopCleanup() {
-	c.stopCleanup <- true
+	c.stopOnce.Do(func() {
+		close(c.stopCleanup)
+	})
 }
 
-// 删除过期项
-func (c *MemoryCache) deleteExpired() {
+// Close 停止后台清理协程并关闭共享缓存的连接，用于服务关闭
+func (c *MemoryCache) Close(ctx context.Context) (int, error) {
+	c.StopCleanup()
+	if c.shared != nil {
+		return c.shared.Close(ctx)
+	}
+	return 0, nil
+}
+
+// DeleteExpired 删除过期项，返回删除的数量；降级期间不清理，swept为false
+func (c *MemoryCache) DeleteExpired() (removed int, swept bool) {
 	now := time.Now().UnixNano()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for k, v := range c.items {
-		if v.Expiration > 0 && now > v.Expiration {
-			delete(c.items, k)
+	// 降级期间过期项是唯一可用的数据，暂不清理
+	if c.serveStale {
+		return 0, false
+	}
+
+	for _, el := range c.items {
+		entry := el.Value.(*cacheEntry)
+		if entry.item.Expiration > 0 && now > entry.item.Expiration && now > entry.item.StaleUntil {
+			c.removeElement(el)
+			removed++
+			cacheEvictions.WithLabelValues(keyPrefix(entry.key), "expired").Inc()
 		}
 	}
+	c.expirations += int64(removed)
+	c.updateGauges()
+	return removed, true
 }
 
 // 全局缓存实例
 var Cache *MemoryCache
 
 // 初始化缓存
-func InitCache(defaultExpiration, cleanupInterval time.Duration) {
-	Cache = NewMemoryCache(defaultExpiration, cleanupInterval)
+func InitCache(conf *config.CacheConfig) {
+	Cache = NewMemoryCache(conf.DefaultTTL, conf.CleanupInterval)
+	Cache.SetLimits(conf.MaxEntries, conf.MaxBytes())
+	Cache.SetStaleTTL(conf.StaleTTL)
+	Cache.SetPolicies(conf.Policies)
+	if conf.Backend == config.CacheBackendTiered {
+		Cache.SetShared(NewRedisCache(&conf.Redis, conf.DefaultTTL), conf.L1TTL)
+	}
 }
 
 // 获取缓存统计信息
 func (c *MemoryCache) Stats() map[string]interface{} {
+	// 按前缀的统计需要单独加锁，在持有读锁之前获取
+	prefixes := c.prefixStats()
+	var shared map[string]interface{}
+	if c.shared != nil {
+		shared = c.shared.Stats()
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -172,6 +528,7 @@ func (c *MemoryCache) Stats() map[string]interface{} {
 	c.hitCountMu.RLock()
 	hits := c.hitCount
 	misses := c.missCount
+	staleHits := c.staleCount
 	c.hitCountMu.RUnlock()
 
 	// 计算命中率
@@ -198,8 +555,8 @@ func (c *MemoryCache) Stats() map[string]interface{} {
 	// 统计过期项
 	now := time.Now().UnixNano()
 	expired := 0
-	for _, item := range c.items {
-		if item.Expiration > 0 && now > item.Expiration {
+	for _, el := range c.items {
+		if exp := el.Value.(*cacheEntry).item.Expiration; exp > 0 && now > exp {
 			expired++
 		}
 	}
@@ -212,5 +569,16 @@ func (c *MemoryCache) Stats() map[string]interface{} {
 		"misses":        misses,
 		"hitRate":       hitRate,
 		"totalRequests": totalRequests,
+		"bytes":         c.bytes,
+		"maxEntries":    c.maxEntries,
+		"maxBytes":      c.maxBytes,
+		"evictions":     c.evictions,
+		"expirations":   c.expirations,
+		"invalidations": c.invalidations,
+		"staleHits":     staleHits,
+		"loads":         c.loadCount.Load(),
+		"coalesced":     c.coalesced.Load(),
+		"prefixes":      prefixes,
+		"shared":        shared,
 	}
 }
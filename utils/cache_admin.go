@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheEntryInfo 缓存项的元数据，用于管理接口，不包含缓存的值
+type CacheEntryInfo struct {
+	Key        string     `json:"key"`
+	Prefix     string     `json:"prefix"`
+	Type       string     `json:"type"`                 // 缓存值的Go类型，空结果为 empty
+	Size       int64      `json:"size"`                 // 估算占用的字节数
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`  // 为空表示不过期
+	StaleUntil *time.Time `json:"staleUntil,omitempty"` // 过期后仍可提供旧值的截止时间
+	Expired    bool       `json:"expired"`
+	Tags       []string   `json:"tags,omitempty"`
+}
+
+// Keys 按键排序列出以prefix开头的缓存项（包括尚未清理的过期项），最多返回limit个，limit不大于0时不限制
+// total为匹配的缓存项总数；不计入命中统计，也不改变淘汰顺序
+func (c *MemoryCache) Keys(prefix string, limit int) (entries []CacheEntryInfo, total int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	total = len(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	entries = make([]CacheEntryInfo, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, c.items[key].Value.(*cacheEntry).info())
+	}
+	return entries, total
+}
+
+// Entry 获取单个缓存项的元数据，不计入命中统计，也不改变淘汰顺序
+func (c *MemoryCache) Entry(key string) (CacheEntryInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return CacheEntryInfo{}, false
+	}
+	return el.Value.(*cacheEntry).info(), true
+}
+
+// info 缓存项的元数据，调用方需持有锁
+func (e *cacheEntry) info() CacheEntryInfo {
+	info := CacheEntryInfo{
+		Key:     e.key,
+		Prefix:  e.prefix,
+		Type:    fmt.Sprintf("%T", e.item.Value),
+		Size:    e.size,
+		Expired: e.item.Expired(),
+		Tags:    append([]string(nil), e.tags...),
+	}
+	if _, ok := e.item.Value.(emptyResult); ok {
+		info.Type = "empty"
+	}
+	if e.item.Expiration > 0 {
+		expiresAt := time.Unix(0, e.item.Expiration)
+		info.ExpiresAt = &expiresAt
+	}
+	if e.item.StaleUntil > 0 {
+		staleUntil := time.Unix(0, e.item.StaleUntil)
+		info.StaleUntil = &staleUntil
+	}
+	return info
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// CacheBackend 缓存的存取接口，进程内缓存（MemoryCache）和共享缓存（RedisCache）都实现该接口
+// 缓存的空结果（没有数据）读取时返回nil和true，写入时value传nil
+type CacheBackend interface {
+	Get(key string) (interface{}, bool)
+	SetWithTags(key string, value interface{}, duration time.Duration, tags ...string)
+	Delete(key string) bool
+	InvalidateTags(tags ...string) int
+	InvalidatePrefix(prefix string) int
+	Stats() map[string]interface{}
+	Close(ctx context.Context) (int, error)
+}
+
+var (
+	_ CacheBackend = (*MemoryCache)(nil)
+	_ CacheBackend = (*RedisCache)(nil)
+)
+
+// SetShared 设置共享缓存（L2），之后进程内缓存作为L1：
+// 未命中时先查找共享缓存，写入和失效同时作用于共享缓存，进程内缓存项最长保留l1TTL（0表示不限制）
+// 需要在开始提供服务前调用
+func (c *MemoryCache) SetShared(shared CacheBackend, l1TTL time.Duration) {
+	c.shared = shared
+	c.l1TTL = l1TTL
+}
+
+// getShared 从共享缓存读取并按opts写入进程内缓存，opts.tags为缓存项的依赖标签（调用方已知时）
+// 不知道依赖标签的缓存项不受本实例的标签失效影响，最长在l1TTL后过期
+func (c *MemoryCache) getShared(key string, opts setOptions) (interface{}, bool) {
+	if c.shared == nil {
+		return nil, false
+	}
+	value, ok := c.shared.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var local interface{} = value
+	if value == nil {
+		local = emptyResult{}
+	}
+	opts.local = true
+	c.set(key, local, 0, opts)
+	return value, true
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/tsuna/gohbase/hrpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// CacheCodec 一类缓存值的序列化方式，用于把缓存项保存到缓存之外
+type CacheCodec struct {
+	Encode func(value interface{}) ([]byte, error)
+	Decode func(data []byte) (interface{}, error)
+}
+
+var (
+	cacheCodecsMu sync.RWMutex
+	cacheCodecs   = map[string]CacheCodec{} // 按键前缀注册的序列化方式
+)
+
+// RegisterCacheCodec 为键前缀注册缓存值的序列化方式，没有注册的前缀的缓存项只保存在内存中
+func RegisterCacheCodec(prefix string, codec CacheCodec) {
+	cacheCodecsMu.Lock()
+	defer cacheCodecsMu.Unlock()
+	cacheCodecs[prefix] = codec
+}
+
+// codecFor 获取键所属前缀的序列化方式
+func codecFor(key string) (CacheCodec, bool) {
+	cacheCodecsMu.RLock()
+	defer cacheCodecsMu.RUnlock()
+	codec, ok := cacheCodecs[keyPrefix(key)]
+	return codec, ok
+}
+
+// JSONCodec 以JSON序列化类型为T的缓存值，解码得到的值类型与写入缓存时相同
+func JSONCodec[T any]() CacheCodec {
+	return CacheCodec{
+		Encode: func(value interface{}) ([]byte, error) {
+			v, ok := value.(T)
+			if !ok {
+				var zero T
+				return nil, fmt.Errorf("缓存值类型为 %T，应为 %T", value, zero)
+			}
+			return json.Marshal(v)
+		},
+		Decode: func(data []byte) (interface{}, error) {
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	}
+}
+
+// cachedCell HBase单元格的可序列化形式
+type cachedCell struct {
+	Row       []byte  `json:"row"`
+	Family    []byte  `json:"family"`
+	Qualifier []byte  `json:"qualifier"`
+	Timestamp *uint64 `json:"timestamp,omitempty"`
+	Value     []byte  `json:"value"`
+}
+
+// ResultsCodec 序列化扫描结果（[]*hrpc.Result），只保留行键、列和值，与Thrift方式返回的结构一致
+var ResultsCodec = CacheCodec{
+	Encode: func(value interface{}) ([]byte, error) {
+		results, ok := value.([]*hrpc.Result)
+		if !ok {
+			return nil, fmt.Errorf("缓存值类型为 %T，应为 []*hrpc.Result", value)
+		}
+		rows := make([][]cachedCell, len(results))
+		for i, result := range results {
+			if result == nil {
+				continue
+			}
+			rows[i] = make([]cachedCell, 0, len(result.Cells))
+			for _, cell := range result.Cells {
+				rows[i] = append(rows[i], cachedCell{
+					Row:       cell.Row,
+					Family:    cell.Family,
+					Qualifier: cell.Qualifier,
+					Timestamp: cell.Timestamp,
+					Value:     cell.Value,
+				})
+			}
+		}
+		return json.Marshal(rows)
+	},
+	Decode: func(data []byte) (interface{}, error) {
+		var rows [][]cachedCell
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+		results := make([]*hrpc.Result, len(rows))
+		for i, row := range rows {
+			result := &hrpc.Result{Cells: make([]*hrpc.Cell, 0, len(row))}
+			for _, c := range row {
+				cell := &hrpc.Cell{
+					Row:       c.Row,
+					Family:    c.Family,
+					Qualifier: c.Qualifier,
+					Value:     c.Value,
+				}
+				if c.Timestamp != nil {
+					cell.Timestamp = proto.Uint64(*c.Timestamp)
+				}
+				result.Cells = append(result.Cells, cell)
+			}
+			results[i] = result
+		}
+		return results, nil
+	},
+}
+
+// 注册utils中缓存的值的序列化方式，models中的类型由models注册
+func init() {
+	RegisterCacheCodec("scan_movies", ResultsCodec)
+	RegisterCacheCodec("movie_rating_stats", JSONCodec[map[string]float64]())
+	RegisterCacheCodec("movie_tags", JSONCodec[[]map[string]interface{}]())
+	RegisterCacheCodec("movies_by_rating", JSONCodec[[]string]())
+}
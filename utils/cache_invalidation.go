@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// MovieTag 依赖某部电影数据的缓存项的标签
+func MovieTag(movieID string) string {
+	return "movie:" + movieID
+}
+
+// InvalidateTags 删除依赖任一标签的缓存项，返回进程内删除的数量；使用共享缓存时同时使共享缓存中的缓存项失效
+func (c *MemoryCache) InvalidateTags(tags ...string) int {
+	removed := c.invalidateTags(tags)
+	if c.shared != nil {
+		c.shared.InvalidateTags(tags...)
+	}
+	return removed
+}
+
+// invalidateTags 删除进程内依赖任一标签的缓存项
+func (c *MemoryCache) invalidateTags(tags []string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidationSeq++
+	removed := 0
+	for _, tag := range tags {
+		if len(c.loadVersions) > 0 {
+			// 没有加载在进行时，之后开始的加载都晚于本次失效，不需要记录
+			c.tagVersions[tag] = c.invalidationSeq
+		}
+		for key := range c.tagIndex[tag] {
+			if el, ok := c.items[key]; ok {
+				c.removeElement(el)
+				removed++
+				cacheEvictions.WithLabelValues(keyPrefix(key), "invalidated").Inc()
+			}
+		}
+	}
+	c.invalidations += int64(removed)
+	c.updateGauges()
+	return removed
+}
+
+// InvalidatePrefix 删除键以prefix开头的缓存项，返回进程内删除的数量；使用共享缓存时同时从共享缓存删除
+func (c *MemoryCache) InvalidatePrefix(prefix string) int {
+	removed := c.invalidatePrefix(prefix)
+	if c.shared != nil {
+		c.shared.InvalidatePrefix(prefix)
+	}
+	return removed
+}
+
+// invalidatePrefix 删除进程内键以prefix开头的缓存项
+func (c *MemoryCache) invalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidationSeq++
+	c.prefixVersion = c.invalidationSeq
+	removed := 0
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+			removed++
+			cacheEvictions.WithLabelValues(keyPrefix(key), "invalidated").Inc()
+		}
+	}
+	c.invalidations += int64(removed)
+	c.updateGauges()
+	return removed
+}
+
+// applyInvalidation 应用其他实例发布的失效事件，返回删除的数量
+// 只删除进程内的缓存项，共享缓存已由发布事件的实例处理
+func (c *MemoryCache) applyInvalidation(event InvalidationEvent) int {
+	removed := 0
+	if len(event.Tags) > 0 {
+		removed += c.invalidateTags(event.Tags)
+	}
+	for _, prefix := range event.Prefixes {
+		removed += c.invalidatePrefix(prefix)
+	}
+	for _, key := range event.Keys {
+		if c.deleteLocal(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// beginLoad 记录一次加载开始时的失效序号，加载结束后需调用endLoad
+func (c *MemoryCache) beginLoad() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version := c.invalidationSeq
+	c.loadVersions[version]++
+	return version
+}
+
+// endLoad 结束一次加载，并删除早于所有进行中加载的标签序号，使tagVersions不随标签数量无限增长
+func (c *MemoryCache) endLoad(version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadVersions[version]--
+	if c.loadVersions[version] > 0 {
+		return
+	}
+	delete(c.loadVersions, version)
+
+	if len(c.loadVersions) == 0 {
+		clear(c.tagVersions)
+		return
+	}
+	oldest := uint64(math.MaxUint64)
+	for v := range c.loadVersions {
+		oldest = min(oldest, v)
+	}
+	for tag, v := range c.tagVersions {
+		if v <= oldest {
+			delete(c.tagVersions, tag)
+		}
+	}
+}
+
+// invalidatedSince 判断依赖的标签在version之后是否失效过，按前缀失效视为所有标签失效，调用方需持有锁
+func (c *MemoryCache) invalidatedSince(tags []string, version uint64) bool {
+	if c.prefixVersion > version {
+		return true
+	}
+	for _, tag := range tags {
+		if c.tagVersions[tag] > version {
+			return true
+		}
+	}
+	return false
+}
+
+// indexTags 把缓存项加入标签索引，调用方需持有写锁
+func (c *MemoryCache) indexTags(entry *cacheEntry) {
+	for _, tag := range entry.tags {
+		keys, ok := c.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tagIndex[tag] = keys
+		}
+		keys[entry.key] = struct{}{}
+	}
+}
+
+// unindexTags 从标签索引中移除缓存项，调用方需持有写锁
+func (c *MemoryCache) unindexTags(entry *cacheEntry) {
+	for _, tag := range entry.tags {
+		if keys, ok := c.tagIndex[tag]; ok {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(c.tagIndex, tag)
+			}
+		}
+	}
+}
+
+// invalidateForWrite 写入成功后使依赖该行数据的缓存项失效，并通知其他实例
+// 所有写入都经过HBasePut，因此评分写入、统计保存和迁移都会自动触发
+func invalidateForWrite(table, row string) {
+	if Cache == nil {
+		return
+	}
+	if tags := writeTags(table, row); len(tags) > 0 {
+		Cache.InvalidateTags(tags...)
+		Invalidations.Publish(InvalidationEvent{Tags: tags})
+	}
+}
+
+// writeTags 根据写入的表和行键计算受影响的标签
+// 随机写入配置了单独的命名空间时，写入的不是业务表，不影响缓存
+func writeTags(table, row string) []string {
+	t := Tables()
+	var movieID string
+	switch table {
+	case t.Movies, t.Links, t.AvgRatings, t.MovieData:
+		movieID = row
+	case t.Ratings, t.Tags:
+		// 行键格式为 userId_movieId 或 userId_movieId_timestamp
+		if parts := strings.Split(row, "_"); len(parts) >= 2 {
+			movieID = parts[1]
+		}
+	case t.MovieRatings:
+		// 行键格式为 movieId_userId
+		movieID, _, _ = strings.Cut(row, "_")
+	default:
+		return nil
+	}
+
+	if movieID == "" {
+		return nil
+	}
+	return []string{MovieTag(movieID)}
+}
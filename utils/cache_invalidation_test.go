@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWriteTags(t *testing.T) {
+	tables := Tables()
+	cases := []struct {
+		table, row string
+		want       []string
+	}{
+		{tables.Movies, "1", []string{"movie:1"}},
+		{tables.AvgRatings, "1", []string{"movie:1"}},
+		{tables.Ratings, "7_1", []string{"movie:1"}},
+		{tables.Tags, "7_1_964982703", []string{"movie:1"}},
+		{tables.MovieRatings, "1_7", []string{"movie:1"}},
+		// 只按电影失效，不使整张表的扫描结果失效
+		{tables.Ratings, "7", nil},
+		{"sim:ratings", "7_1", nil},
+	}
+	for _, tc := range cases {
+		if got := writeTags(tc.table, tc.row); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("writeTags(%q, %q) = %v，期望 %v", tc.table, tc.row, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoadFunc 缓存未命中时加载数据，返回nil表示没有数据，只有策略允许缓存空结果时才写入缓存
+type LoadFunc func(ctx context.Context) (interface{}, error)
+
+// loadCall 一次正在进行的加载，同一个键的并发请求共享结果
+type loadCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	partial bool // 加载结果是否不完整
+}
+
+// GetOrLoad 获取缓存项，未命中时调用loader加载并写入缓存，ttl为0时使用默认过期时间
+// tags为缓存项的依赖标签，加载期间任一标签失效时结果不写入缓存
+// 同一个键的并发加载合并为一次，其余请求等待并共享结果；
+// 开启了过期后继续提供旧值（SetStaleTTL）时，刚过期的缓存项会直接返回，同时由一个后台任务刷新
+// 不完整的结果返回给调用方但不写入缓存
+func (c *MemoryCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoadFunc, tags ...string) (interface{}, error) {
+	span := trace.SpanFromContext(ctx)
+	for {
+		value, state := c.lookup(key)
+		switch state {
+		case lookupFresh:
+			c.recordHit(key)
+			traceCacheLookup(ctx, true)
+			span.AddEvent("cache.hit", trace.WithAttributes(attribute.String("cache.key", key)))
+			return value, nil
+		case lookupStale:
+			c.recordStale(key)
+			traceCacheLookup(ctx, true)
+			span.AddEvent("cache.stale", trace.WithAttributes(attribute.String("cache.key", key)))
+			c.refresh(ctx, key, ttl, loader, tags)
+			return value, nil
+		}
+
+		c.recordMiss(key)
+		span.AddEvent("cache.miss", trace.WithAttributes(attribute.String("cache.key", key)))
+		value, shared, err := c.load(ctx, key, ttl, loader, tags)
+
+		// 发起加载的请求被取消时，仍在等待的请求重新加载，而不是一起失败
+		if shared && isContextError(err) && ctx.Err() == nil {
+			continue
+		}
+		return value, err
+	}
+}
+
+// lookupState 查找缓存项的结果
+type lookupState int
+
+const (
+	lookupMiss  lookupState = iota
+	lookupFresh             // 未过期，或降级模式下的过期项
+	lookupStale             // 已过期但仍在可提供旧值的时间内，需要刷新
+)
+
+// lookup 查找缓存项并更新淘汰顺序，不计入命中统计
+func (c *MemoryCache) lookup(key string) (interface{}, lookupState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[key]
+	if !found {
+		return nil, lookupMiss
+	}
+	item := el.Value.(*cacheEntry).item
+
+	state := lookupFresh
+	if item.Expired() && !c.serveStale {
+		if item.StaleUntil == 0 || time.Now().UnixNano() > item.StaleUntil {
+			return nil, lookupMiss
+		}
+		state = lookupStale
+	}
+	c.touch(el)
+	if _, ok := item.Value.(emptyResult); ok {
+		return nil, state
+	}
+	return item.Value, state
+}
+
+// load 执行加载，同一个键（区分是否允许部分结果）同时只有一个加载在进行
+// shared表示结果来自其他请求发起的加载
+func (c *MemoryCache) load(ctx context.Context, key string, ttl time.Duration, loader LoadFunc, tags []string) (value interface{}, shared bool, err error) {
+	flightKey := loadKey(ctx, key)
+
+	c.loadMu.Lock()
+	if call, ok := c.loads[flightKey]; ok {
+		c.loadMu.Unlock()
+		c.coalesced.Add(1)
+		traceCacheLookup(ctx, false)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+		if call.partial {
+			markPartial(ctx)
+		}
+		return call.value, true, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	c.loads[flightKey] = call
+	c.loadMu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("加载缓存项 %s 失败: %v", key, r)
+			c.finishLoad(flightKey, call)
+			panic(r)
+		}
+		c.finishLoad(flightKey, call)
+	}()
+
+	version := c.beginLoad()
+	defer c.endLoad(version)
+	opts := setOptions{tags: tags, stale: true, checkVersion: true, version: version}
+	if value, ok := c.getShared(key, opts); ok {
+		// 其他实例已加载过，不需要访问HBase
+		traceCacheLookup(ctx, true)
+		call.value = value
+		return call.value, false, nil
+	}
+
+	// 加载使用独立的部分结果状态，只反映本次加载的结果，再传递给所有等待的请求
+	c.loadCount.Add(1)
+	traceCacheLookup(ctx, false)
+	loadCtx := ctx
+	if AllowsPartial(ctx) {
+		loadCtx = context.WithValue(ctx, bestEffortKey{}, &bestEffortState{})
+	}
+	call.value, call.err = loader(loadCtx)
+	call.partial = IsPartial(loadCtx)
+	if call.partial {
+		markPartial(ctx)
+	}
+	if call.err == nil && !call.partial {
+		value := call.value
+		if value == nil && c.cachesEmpty(key) {
+			// 缓存“没有数据”，避免反复查询不存在的数据
+			value = emptyResult{}
+		}
+		if value != nil {
+			c.set(key, value, ttl, opts)
+		}
+	}
+	return call.value, false, call.err
+}
+
+// loadKey 合并加载使用的键，允许部分结果的请求与普通请求分开加载，避免普通请求拿到不完整的结果
+func loadKey(ctx context.Context, key string) string {
+	if AllowsPartial(ctx) {
+		return key + "\x00best_effort"
+	}
+	return key
+}
+
+// finishLoad 结束一次加载并唤醒等待的请求
+func (c *MemoryCache) finishLoad(flightKey string, call *loadCall) {
+	c.loadMu.Lock()
+	delete(c.loads, flightKey)
+	c.loadMu.Unlock()
+	close(call.done)
+}
+
+// refresh 在后台重新加载已过期的缓存项，同一个键已有加载在进行时不重复发起
+func (c *MemoryCache) refresh(ctx context.Context, key string, ttl time.Duration, loader LoadFunc, tags []string) {
+	// 刷新不应随当前请求结束而取消，也不计入当前请求的缓存访问记录
+	ctx = context.WithValue(context.WithoutCancel(ctx), cacheTraceKey{}, nil)
+
+	c.loadMu.Lock()
+	_, loading := c.loads[loadKey(ctx, key)]
+	c.loadMu.Unlock()
+	if loading {
+		return
+	}
+
+	err := CacheRefreshes.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, config.Current().Timeouts.Default)
+		defer cancel()
+		if _, _, err := c.load(ctx, key, ttl, loader, tags); err != nil {
+			logrus.Warnf("后台刷新缓存项 %s 失败，继续提供旧值: %v", key, err)
+		}
+	})
+	if err != nil {
+		logrus.Debugf("服务正在关闭，跳过缓存项 %s 的后台刷新", key)
+	}
+}
+
+// SetStaleTTL 设置缓存项过期后仍可提供旧值并在后台刷新的时长，0表示不提供旧值
+// 只影响之后通过GetOrLoad写入的缓存项
+func (c *MemoryCache) SetStaleTTL(d time.Duration) {
+	c.mu.Lock()
+	c.staleTTL = d
+	c.mu.Unlock()
+}
+
+// 记录提供了旧值的访问
+func (c *MemoryCache) recordStale(key string) {
+	c.hitCountMu.Lock()
+	c.hitCount++
+	c.staleCount++
+	c.counters(key).hits++
+	c.counters(key).stale++
+	c.hitCountMu.Unlock()
+	cacheRequests.WithLabelValues(keyPrefix(key), "stale").Inc()
+}
+
+// isContextError 判断错误是否由context取消或超时引起
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
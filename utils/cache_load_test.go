@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 轮询直到cond成立，超时则测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingLoader 阻塞到release关闭才返回的加载函数，记录调用次数
+type blockingLoader struct {
+	calls   atomic.Int32
+	started chan struct{} // 每次调用开始时写入
+	release chan struct{}
+	value   interface{}
+}
+
+func newBlockingLoader(value interface{}) *blockingLoader {
+	return &blockingLoader{started: make(chan struct{}, 16), release: make(chan struct{}), value: value}
+}
+
+func (l *blockingLoader) load(ctx context.Context) (interface{}, error) {
+	l.calls.Add(1)
+	l.started <- struct{}{}
+	select {
+	case <-l.release:
+		return l.value, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestGetOrLoadCoalesces(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	loader := newBlockingLoader("v1")
+
+	const n = 20
+	results := make([]interface{}, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.GetOrLoad(context.Background(), "movie:1", 0, loader.load)
+		}(i)
+	}
+
+	<-loader.started
+	waitFor(t, "其余请求等待同一次加载", func() bool { return c.coalesced.Load() == n-1 })
+	close(loader.release)
+	wg.Wait()
+
+	if calls := loader.calls.Load(); calls != 1 {
+		t.Fatalf("加载执行了 %d 次，期望 1 次", calls)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || results[i] != "v1" {
+			t.Errorf("第 %d 个请求返回 %v, %v，期望 v1", i, results[i], errs[i])
+		}
+	}
+	if v, ok := c.peek("movie:1"); !ok || v != "v1" {
+		t.Errorf("缓存中为 %v, %v，期望 v1", v, ok)
+	}
+	if _, err := c.GetOrLoad(context.Background(), "movie:1", 0, loader.load); err != nil || loader.calls.Load() != 1 {
+		t.Errorf("命中缓存后不应再次加载，加载次数 %d", loader.calls.Load())
+	}
+}
+
+func TestGetOrLoadWaiterReloadsWhenLeaderCancelled(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	loader := newBlockingLoader("v1")
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(leaderCtx, "movie:1", 0, loader.load)
+		leaderErr <- err
+	}()
+	<-loader.started
+
+	waiter := make(chan interface{}, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), "movie:1", 0, loader.load)
+		waiter <- v
+	}()
+	waitFor(t, "请求等待加载", func() bool { return c.coalesced.Load() == 1 })
+
+	// 发起加载的请求被取消，等待的请求应自己重新加载，而不是一起失败
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("被取消的请求返回 %v，期望 context.Canceled", err)
+	}
+	<-loader.started
+	close(loader.release)
+	if v := <-waiter; v != "v1" {
+		t.Errorf("等待的请求返回 %v，期望 v1", v)
+	}
+	if calls := loader.calls.Load(); calls != 2 {
+		t.Errorf("加载执行了 %d 次，期望 2 次", calls)
+	}
+}
+
+func TestGetOrLoadServesStaleWhileRefreshing(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	c.SetStaleTTL(time.Hour)
+
+	v, err := c.GetOrLoad(context.Background(), "movie:1", 20*time.Millisecond, func(context.Context) (interface{}, error) {
+		return "old", nil
+	})
+	if err != nil || v != "old" {
+		t.Fatalf("首次加载返回 %v, %v", v, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	loader := newBlockingLoader("new")
+	// 刷新期间的请求都立即得到旧值，只发起一次后台刷新
+	for i := 0; i < 5; i++ {
+		v, err := c.GetOrLoad(context.Background(), "movie:1", time.Minute, loader.load)
+		if err != nil || v != "old" {
+			t.Fatalf("过期后返回 %v, %v，期望旧值", v, err)
+		}
+		if i == 0 {
+			<-loader.started
+		}
+	}
+	if calls := loader.calls.Load(); calls != 1 {
+		t.Fatalf("后台刷新执行了 %d 次，期望 1 次", calls)
+	}
+	if stats := c.Stats(); stats["staleHits"] != int64(5) {
+		t.Errorf("staleHits为 %v，期望 5", stats["staleHits"])
+	}
+
+	close(loader.release)
+	waitFor(t, "后台刷新写入新值", func() bool {
+		v, ok := c.peek("movie:1")
+		return ok && v == "new"
+	})
+	if v, _ := c.GetOrLoad(context.Background(), "movie:1", time.Minute, loader.load); v != "new" {
+		t.Errorf("刷新后返回 %v，期望 new", v)
+	}
+}
+
+func TestGetOrLoadDiscardsInvalidatedLoad(t *testing.T) {
+	cases := []struct {
+		name       string
+		invalidate func(c *MemoryCache)
+		cached     bool
+	}{
+		{name: "依赖的标签失效", invalidate: func(c *MemoryCache) { c.InvalidateTags(MovieTag("1")) }},
+		{name: "按前缀失效", invalidate: func(c *MemoryCache) { c.InvalidatePrefix("other:") }},
+		{name: "其他标签失效", invalidate: func(c *MemoryCache) { c.InvalidateTags(MovieTag("2")) }, cached: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewMemoryCache(time.Minute, 0)
+			loader := newBlockingLoader("v1")
+
+			result := make(chan interface{}, 1)
+			go func() {
+				v, _ := c.GetOrLoad(context.Background(), "movie:1", 0, loader.load, MovieTag("1"))
+				result <- v
+			}()
+			<-loader.started
+			tc.invalidate(c)
+			close(loader.release)
+
+			// 结果仍返回给调用方，但可能是旧数据，不写入缓存
+			if v := <-result; v != "v1" {
+				t.Fatalf("返回 %v，期望 v1", v)
+			}
+			if _, ok := c.peek("movie:1"); ok != tc.cached {
+				t.Errorf("缓存中是否存在为 %v，期望 %v", ok, tc.cached)
+			}
+		})
+	}
+}
+
+func TestGetOrLoadDoesNotCacheFailures(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+
+	loadErr := errors.New("HBase不可用")
+	if _, err := c.GetOrLoad(context.Background(), "movie:1", 0, func(context.Context) (interface{}, error) {
+		return nil, loadErr
+	}); !errors.Is(err, loadErr) {
+		t.Fatalf("返回 %v，期望 %v", err, loadErr)
+	}
+	if _, ok := c.peek("movie:1"); ok {
+		t.Error("加载失败的结果不应写入缓存")
+	}
+
+	// 不完整的结果返回给调用方并标记请求，但不写入缓存
+	ctx := WithBestEffort(context.Background())
+	v, err := c.GetOrLoad(ctx, "movie:1", 0, func(ctx context.Context) (interface{}, error) {
+		markPartial(ctx)
+		return "partial", nil
+	})
+	if err != nil || v != "partial" || !IsPartial(ctx) {
+		t.Fatalf("返回 %v, %v，是否不完整 %v", v, err, IsPartial(ctx))
+	}
+	if _, ok := c.peek("movie:1"); ok {
+		t.Error("不完整的结果不应写入缓存")
+	}
+}
+
+func TestGetOrLoadSeparatesBestEffortLoads(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	partial := newBlockingLoader("partial")
+
+	ctx := WithBestEffort(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.GetOrLoad(ctx, "movie:1", 0, func(ctx context.Context) (interface{}, error) {
+			markPartial(ctx)
+			return partial.load(ctx)
+		})
+	}()
+	<-partial.started
+
+	// 普通请求不等待允许部分结果的加载，以免拿到不完整的结果
+	v, err := c.GetOrLoad(context.Background(), "movie:1", 0, func(context.Context) (interface{}, error) {
+		return "full", nil
+	})
+	if err != nil || v != "full" {
+		t.Fatalf("普通请求返回 %v, %v，期望 full", v, err)
+	}
+	if c.coalesced.Load() != 0 {
+		t.Error("普通请求不应与允许部分结果的加载合并")
+	}
+	close(partial.release)
+	<-done
+	if v, _ := c.peek("movie:1"); v != "full" {
+		t.Errorf("缓存中为 %v，期望 full", v)
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"gohbase/config"
+	"reflect"
+	"sort"
+)
+
+// emptyResult 缓存中表示“没有数据”的值，如不存在的电影，读取时返回nil
+type emptyResult struct{}
+
+// isEmptyValue 判断是否为空结果：nil、长度为0的切片/map/字符串，或Empty()返回true的值
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, emptyResult:
+		return true
+	case interface{ Empty() bool }:
+		return v.Empty()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// SetPolicies 设置按键前缀的缓存策略，超出新数量上限的缓存项立即淘汰
+// 过期时间和空结果策略只影响之后写入的缓存项
+func (c *MemoryCache) SetPolicies(policies map[string]config.CachePolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.policies = make(map[string]config.CachePolicy, len(policies))
+	for prefix, policy := range policies {
+		c.policies[prefix] = policy
+		c.evictPrefixOverflow(prefix, policy.MaxEntries)
+	}
+	c.updateGauges()
+}
+
+// cachesEmpty 判断键所属的前缀是否缓存空结果，没有配置策略的前缀不缓存加载得到的nil
+func (c *MemoryCache) cachesEmpty(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	policy, ok := c.policies[keyPrefix(key)]
+	return ok && policy.CacheEmpty
+}
+
+// prefixStats 按前缀统计缓存项数量、估算内存、命中率和策略
+func (c *MemoryCache) prefixStats() map[string]interface{} {
+	type usage struct {
+		entries int
+		bytes   int64
+	}
+	usages := make(map[string]*usage)
+	c.mu.RLock()
+	for _, el := range c.items {
+		entry := el.Value.(*cacheEntry)
+		u, ok := usages[entry.prefix]
+		if !ok {
+			u = &usage{}
+			usages[entry.prefix] = u
+		}
+		u.entries++
+		u.bytes += entry.size
+	}
+	policies := c.policies
+	c.mu.RUnlock()
+
+	c.hitCountMu.RLock()
+	counts := make(map[string]prefixCounters, len(c.prefixCounts))
+	for prefix, counters := range c.prefixCounts {
+		counts[prefix] = *counters
+	}
+	c.hitCountMu.RUnlock()
+
+	prefixes := make(map[string]bool)
+	for prefix := range usages {
+		prefixes[prefix] = true
+	}
+	for prefix := range counts {
+		prefixes[prefix] = true
+	}
+	for prefix := range policies {
+		prefixes[prefix] = true
+	}
+	names := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		names = append(names, prefix)
+	}
+	sort.Strings(names)
+
+	stats := make(map[string]interface{}, len(names))
+	for _, prefix := range names {
+		u := usages[prefix]
+		if u == nil {
+			u = &usage{}
+		}
+		counters := counts[prefix]
+
+		var hitRate float64
+		if total := counters.hits + counters.misses; total > 0 {
+			hitRate = float64(counters.hits) / float64(total) * 100
+		}
+
+		entry := map[string]interface{}{
+			"entries":   u.entries,
+			"bytes":     u.bytes,
+			"hits":      counters.hits,
+			"misses":    counters.misses,
+			"staleHits": counters.stale,
+			"hitRate":   hitRate,
+		}
+		if policy, ok := policies[prefix]; ok {
+			entry["policy"] = map[string]interface{}{
+				"ttl":        policy.TTL.String(),
+				"maxEntries": policy.MaxEntries,
+				"cacheEmpty": policy.CacheEmpty,
+				"jitter":     policy.Jitter,
+			}
+		}
+		stats[prefix] = entry
+	}
+	return stats
+}
@@ -0,0 +1,100 @@
+package utils
+
+import "reflect"
+
+// entryOverhead 每个缓存项在值之外的固定开销估算：map桶、LRU链表节点和元数据
+const entryOverhead = 128
+
+// maxSizeDepth 估算大小时的最大递归深度
+const maxSizeDepth = 32
+
+// estimateSize 估算缓存值占用的内存字节数
+// 只统计导出字段引用的数据，protobuf等类型的内部状态不计入；同一指针只计算一次
+func estimateSize(value interface{}) int64 {
+	if value == nil {
+		return 0
+	}
+	return sizeOf(reflect.ValueOf(value), make(map[uintptr]bool), 0)
+}
+
+// sizeOf 值本身的大小加上它引用的数据
+func sizeOf(v reflect.Value, seen map[uintptr]bool, depth int) int64 {
+	return int64(v.Type().Size()) + referencedSize(v, seen, depth)
+}
+
+// referencedSize 值通过指针、切片、字符串、map等引用的数据大小
+func referencedSize(v reflect.Value, seen map[uintptr]bool, depth int) int64 {
+	if depth > maxSizeDepth {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		return sizeOf(v.Elem(), seen, depth+1)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return sizeOf(v.Elem(), seen, depth+1)
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Type().Elem()
+		size := int64(v.Cap()) * int64(elem.Size())
+		if hasReferences(elem) {
+			for i := 0; i < v.Len(); i++ {
+				size += referencedSize(v.Index(i), seen, depth+1)
+			}
+		}
+		return size
+	case reflect.Array:
+		var size int64
+		if hasReferences(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				size += referencedSize(v.Index(i), seen, depth+1)
+			}
+		}
+		return size
+	case reflect.Map:
+		if v.IsNil() {
+			return 0
+		}
+		// map头部和桶的开销按每项额外8字节粗略估算
+		size := int64(48 + 8*v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOf(iter.Key(), seen, depth+1) + sizeOf(iter.Value(), seen, depth+1)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				size += referencedSize(v.Field(i), seen, depth+1)
+			}
+		}
+		return size
+	default:
+		return 0
+	}
+}
+
+// hasReferences 判断类型的值是否可能引用其他数据
+func hasReferences(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotVersion 快照文件格式的版本，格式变化时旧快照被忽略
+const snapshotVersion = 1
+
+// cacheSnapshot 缓存快照文件的内容
+type cacheSnapshot struct {
+	Version int             `json:"version"`
+	SavedAt time.Time       `json:"savedAt"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry 快照中的一个缓存项
+type snapshotEntry struct {
+	Key        string   `json:"key"`
+	Expiration int64    `json:"expiration"`           // 过期时间（UnixNano）
+	StaleUntil int64    `json:"staleUntil,omitempty"` // 可提供旧值的截止时间（UnixNano）
+	Tags       []string `json:"tags,omitempty"`
+	Empty      bool     `json:"empty,omitempty"` // 缓存的空结果，没有值
+	Value      []byte   `json:"value,omitempty"` // 由该前缀注册的CacheCodec编码
+}
+
+// SaveSnapshot 把指定前缀的缓存项写入快照文件，返回写入的缓存项数
+// 只保存注册了序列化方式（RegisterCacheCodec）且尚未过期的缓存项；先写临时文件再替换，写入中断不会破坏已有快照
+// ctx截止时放弃保存并返回错误，已有的快照保持不变
+func (c *MemoryCache) SaveSnapshot(ctx context.Context, path string, prefixes []string) (int, error) {
+	wanted := make(map[string]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		wanted[prefix] = true
+	}
+
+	// 持有读锁时只复制缓存项，编码在锁外进行
+	type pending struct {
+		key  string
+		item CacheItem
+		tags []string
+	}
+	now := time.Now().UnixNano()
+	var items []pending
+	c.mu.RLock()
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		entry := el.Value.(*cacheEntry)
+		// 不过期的缓存项无法判断恢复时是否仍然有效，不保存
+		if !wanted[entry.prefix] || entry.item.Expiration == 0 || now > entry.item.Expiration {
+			continue
+		}
+		items = append(items, pending{key: entry.key, item: entry.item, tags: entry.tags})
+	}
+	c.mu.RUnlock()
+
+	snapshot := cacheSnapshot{Version: snapshotVersion, SavedAt: time.Now()}
+	skipped := 0
+	for _, p := range items {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("保存缓存快照未完成: %w", err)
+		}
+		entry := snapshotEntry{
+			Key:        p.key,
+			Expiration: p.item.Expiration,
+			StaleUntil: p.item.StaleUntil,
+			Tags:       p.tags,
+		}
+		if _, ok := p.item.Value.(emptyResult); ok {
+			entry.Empty = true
+		} else {
+			codec, ok := codecFor(p.key)
+			if !ok {
+				skipped++
+				continue
+			}
+			value, err := codec.Encode(p.item.Value)
+			if err != nil {
+				skipped++
+				continue
+			}
+			entry.Value = value
+		}
+		snapshot.Entries = append(snapshot.Entries, entry)
+	}
+	if skipped > 0 {
+		logrus.Warnf("缓存快照跳过了 %d 个无法序列化的缓存项", skipped)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return 0, fmt.Errorf("编码缓存快照失败: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, fmt.Errorf("创建缓存快照目录失败: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("保存缓存快照未完成: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return 0, fmt.Errorf("写入缓存快照失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("写入缓存快照失败: %w", err)
+	}
+	return len(snapshot.Entries), nil
+}
+
+// LoadSnapshot 从快照文件恢复缓存项，返回恢复的缓存项数，文件不存在时返回0
+// 缓存项保留原来的过期时间，已经过期的不恢复；恢复时仍遵守容量上限和各前缀的策略
+func (c *MemoryCache) LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取缓存快照失败: %w", err)
+	}
+
+	var snapshot cacheSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("解析缓存快照失败: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return 0, fmt.Errorf("缓存快照版本为 %d，当前版本为 %d", snapshot.Version, snapshotVersion)
+	}
+
+	restored := 0
+	for _, entry := range snapshot.Entries {
+		if time.Now().UnixNano() > max(entry.Expiration, entry.StaleUntil) {
+			continue
+		}
+
+		var value interface{} = emptyResult{}
+		if !entry.Empty {
+			codec, ok := codecFor(entry.Key)
+			if !ok {
+				continue
+			}
+			if value, err = codec.Decode(entry.Value); err != nil {
+				continue
+			}
+		}
+
+		opts := setOptions{tags: entry.Tags, expiresAt: entry.Expiration, staleUntil: entry.StaleUntil, local: true}
+		if c.set(entry.Key, value, 0, opts) {
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// RestoreCacheSnapshot 启动时从配置的快照文件恢复缓存，恢复失败时以空缓存启动
+func RestoreCacheSnapshot(conf *config.CacheConfig) {
+	if conf.SnapshotFile == "" {
+		return
+	}
+	start := time.Now()
+	restored, err := Cache.LoadSnapshot(conf.SnapshotFile)
+	if err != nil {
+		logrus.Warnf("恢复缓存快照失败，以空缓存启动: %v", err)
+		return
+	}
+	logrus.Infof("已从快照 %s 恢复 %d 个缓存项 [耗时: %v]", conf.SnapshotFile, restored, time.Since(start))
+}
+
+// SaveCacheSnapshot 把缓存写入配置的快照文件，返回保存的缓存项数，在关闭时调用
+func SaveCacheSnapshot(ctx context.Context) (int, error) {
+	conf := config.Current().Cache
+	if conf.SnapshotFile == "" {
+		return 0, nil
+	}
+	saved, err := Cache.SaveSnapshot(ctx, conf.SnapshotFile, conf.SnapshotPrefixes)
+	if err != nil {
+		return 0, err
+	}
+	logrus.Infof("已保存 %d 个缓存项到快照 %s", saved, conf.SnapshotFile)
+	return saved, nil
+}
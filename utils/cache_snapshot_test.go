@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveSnapshotHonoursContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	prefixes := []string{"movies_by_rating"}
+
+	c := NewMemoryCache(time.Minute, 0)
+	c.Set("movies_by_rating:4.0:5.0", []string{"1", "2"})
+	c.Set("movies_by_rating:3.0:4.0", []string{"3"})
+	c.Set("unsaved:1", "x")
+
+	saved, err := c.SaveSnapshot(context.Background(), path, prefixes)
+	if err != nil || saved != 2 {
+		t.Fatalf("保存快照返回 %d, %v，期望 2", saved, err)
+	}
+	before, _ := os.ReadFile(path)
+
+	// ctx已截止时放弃保存，已有快照不变
+	c.Set("movies_by_rating:2.0:3.0", []string{"4"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if saved, err := c.SaveSnapshot(ctx, path, prefixes); !errors.Is(err, context.Canceled) || saved != 0 {
+		t.Fatalf("ctx已取消时返回 %d, %v，期望 context.Canceled", saved, err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("放弃保存时不应修改已有快照")
+	}
+
+	restored := NewMemoryCache(time.Minute, 0)
+	if n, err := restored.LoadSnapshot(path); err != nil || n != 2 {
+		t.Fatalf("恢复快照返回 %d, %v，期望 2", n, err)
+	}
+	if v, ok := restored.peek("movies_by_rating:4.0:5.0"); !ok || !reflect.DeepEqual(v, []string{"1", "2"}) {
+		t.Errorf("恢复的缓存项为 %v, %v", v, ok)
+	}
+}
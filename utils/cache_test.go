@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"gohbase/config"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entrySize 字符串值的缓存项的估算大小
+func entrySize(key, value string) int64 {
+	return estimateSize(value) + int64(len(key)) + entryOverhead
+}
+
+// cacheKeys 按LRU顺序（最近使用的在前）返回缓存键
+func cacheKeys(c *MemoryCache) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*cacheEntry).key)
+	}
+	return keys
+}
+
+// checkAccounting 检查估算大小、map、LRU链表、前缀链表和标签索引是否一致
+func checkAccounting(t *testing.T, c *MemoryCache) {
+	t.Helper()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var bytes int64
+	prefixes := make(map[string]int)
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*cacheEntry)
+		bytes += entry.size
+		prefixes[entry.prefix]++
+		if c.items[entry.key] != el {
+			t.Errorf("缓存项 %s 不在map中", entry.key)
+		}
+	}
+	if bytes != c.bytes {
+		t.Errorf("估算大小为 %d，缓存项之和为 %d", c.bytes, bytes)
+	}
+	if len(c.items) != c.lru.Len() {
+		t.Errorf("map中有 %d 项，LRU链表中有 %d 项", len(c.items), c.lru.Len())
+	}
+	for prefix, list := range c.prefixLRU {
+		if list.Len() != prefixes[prefix] {
+			t.Errorf("前缀 %s 的链表中有 %d 项，实际 %d 项", prefix, list.Len(), prefixes[prefix])
+		}
+	}
+	if len(c.prefixLRU) != len(prefixes) {
+		t.Errorf("有 %d 个前缀链表，实际 %d 个前缀", len(c.prefixLRU), len(prefixes))
+	}
+	for tag, keys := range c.tagIndex {
+		for key := range keys {
+			if _, ok := c.items[key]; !ok {
+				t.Errorf("标签 %s 索引了不存在的缓存项 %s", tag, key)
+			}
+		}
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	c.SetLimits(3, 0)
+
+	c.Set("movie:1", "a")
+	c.Set("movie:2", "b")
+	c.Set("movie:3", "c")
+	c.Get("movie:1")      // 读取使movie:1成为最近使用的
+	c.Set("movie:3", "C") // 覆盖同样更新使用顺序
+	c.Set("movie:4", "d")
+
+	want := []string{"movie:4", "movie:3", "movie:1"}
+	if keys := cacheKeys(c); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("缓存键为 %v，期望 %v", keys, want)
+	}
+	if stats := c.Stats(); stats["evictions"] != int64(1) {
+		t.Errorf("淘汰数为 %v，期望 1", stats["evictions"])
+	}
+
+	// 降低上限时立即从最久未使用的一端淘汰
+	c.SetLimits(1, 0)
+	if keys := cacheKeys(c); !reflect.DeepEqual(keys, []string{"movie:4"}) {
+		t.Errorf("缩小上限后缓存键为 %v，期望 [movie:4]", keys)
+	}
+	checkAccounting(t, c)
+}
+
+func TestMemoryCacheByteLimit(t *testing.T) {
+	value := strings.Repeat("x", 100)
+	size := entrySize("movie:1", value)
+
+	c := NewMemoryCache(time.Minute, 0)
+	c.SetLimits(0, 2*size+size/2)
+
+	c.Set("movie:1", value)
+	c.Set("movie:2", value)
+	if c.Stats()["bytes"] != 2*size {
+		t.Fatalf("估算大小为 %v，期望 %d", c.Stats()["bytes"], 2*size)
+	}
+	c.Set("movie:3", value)
+	if keys := cacheKeys(c); !reflect.DeepEqual(keys, []string{"movie:3", "movie:2"}) {
+		t.Fatalf("超出内存上限后缓存键为 %v，期望 [movie:3 movie:2]", keys)
+	}
+
+	// 单个缓存项超过上限时不写入，同名的旧值也被删除
+	c.Set("movie:2", strings.Repeat("x", int(3*size)))
+	if keys := cacheKeys(c); !reflect.DeepEqual(keys, []string{"movie:3"}) {
+		t.Errorf("写入过大的缓存项后缓存键为 %v，期望 [movie:3]", keys)
+	}
+	if c.Stats()["bytes"] != size {
+		t.Errorf("估算大小为 %v，期望 %d", c.Stats()["bytes"], size)
+	}
+	checkAccounting(t, c)
+}
+
+func TestMemoryCachePrefixLimit(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	c.SetPolicies(map[string]config.CachePolicy{"search": {MaxEntries: 2}})
+
+	c.Set("movie:1", "m")
+	c.Set("search:a", "1")
+	c.Set("search:b", "2")
+	c.Get("search:a")
+	c.Set("search:c", "3")
+
+	keys := cacheKeys(c)
+	sort.Strings(keys)
+	want := []string{"movie:1", "search:a", "search:c"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("缓存键为 %v，期望 %v", keys, want)
+	}
+	checkAccounting(t, c)
+}
+
+func TestMemoryCacheSizeAccounting(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+
+	c.SetWithTags("movie:1", "short", 0, MovieTag("1"))
+	c.SetWithTags("movie:1", strings.Repeat("x", 500), 0, MovieTag("1"))
+	c.SetWithTags("movie_detail:1", "detail", 0, MovieTag("1"))
+	c.SetWithTags("movie:2", "two", 0, MovieTag("2"))
+	c.SetWithExpiration("search:a", "a", time.Millisecond)
+	c.Set("search:b", "b")
+	want := entrySize("movie:1", strings.Repeat("x", 500)) + entrySize("movie_detail:1", "detail") +
+		entrySize("movie:2", "two") + entrySize("search:a", "a") + entrySize("search:b", "b")
+	if c.Stats()["bytes"] != want {
+		t.Fatalf("覆盖后估算大小为 %v，期望 %d", c.Stats()["bytes"], want)
+	}
+	checkAccounting(t, c)
+
+	steps := []struct {
+		name    string
+		apply   func()
+		removed []string
+	}{
+		{name: "删除", apply: func() { c.Delete("movie:2") }, removed: []string{"movie:2"}},
+		{name: "按标签失效", apply: func() { c.InvalidateTags(MovieTag("1")) }, removed: []string{"movie:1", "movie_detail:1"}},
+		{name: "清理过期项", apply: func() { time.Sleep(5 * time.Millisecond); c.DeleteExpired() }, removed: []string{"search:a"}},
+		{name: "按前缀失效", apply: func() { c.InvalidatePrefix("search:") }, removed: []string{"search:b"}},
+	}
+	values := map[string]string{"movie:1": strings.Repeat("x", 500), "movie_detail:1": "detail", "movie:2": "two", "search:a": "a", "search:b": "b"}
+	for _, step := range steps {
+		step.apply()
+		for _, key := range step.removed {
+			want -= entrySize(key, values[key])
+			if _, ok := c.peek(key); ok {
+				t.Errorf("%s后缓存项 %s 仍存在", step.name, key)
+			}
+		}
+		if c.Stats()["bytes"] != want {
+			t.Errorf("%s后估算大小为 %v，期望 %d", step.name, c.Stats()["bytes"], want)
+		}
+		checkAccounting(t, c)
+	}
+	if want != 0 {
+		t.Fatalf("全部删除后期望大小为 %d", want)
+	}
+
+	c.Set("movie:3", "three")
+	c.Flush()
+	if c.Len() != 0 || c.Stats()["bytes"] != int64(0) {
+		t.Errorf("清空后有 %d 项，估算大小 %v", c.Len(), c.Stats()["bytes"])
+	}
+	checkAccounting(t, c)
+}
+
+func TestMemoryCacheConcurrentAccounting(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	c.SetLimits(50, 20*entrySize("movie:00", "value-00"))
+	c.SetPolicies(map[string]config.CachePolicy{"search": {MaxEntries: 5}})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := fmt.Sprintf("%02d", (g*7+i)%40)
+				switch i % 6 {
+				case 0, 1:
+					c.SetWithTags("movie:"+id, "value-"+id, 0, MovieTag(id))
+				case 2:
+					c.Set("search:"+id, id)
+				case 3:
+					c.Get("movie:" + id)
+				case 4:
+					c.Delete("search:" + id)
+				case 5:
+					c.InvalidateTags(MovieTag(id))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	checkAccounting(t, c)
+	stats := c.Stats()
+	if bytes := stats["bytes"].(int64); bytes > 20*entrySize("movie:00", "value-00") {
+		t.Errorf("估算大小 %d 超出上限", bytes)
+	}
+	if c.Len() > 50 {
+		t.Errorf("缓存项数 %d 超出上限", c.Len())
+	}
+	if n := stats["prefixes"].(map[string]interface{})["search"].(map[string]interface{})["entries"].(int); n > 5 {
+		t.Errorf("search前缀有 %d 项，超出上限 5", n)
+	}
+}
+
+func TestMemoryCacheTagVersionsPruned(t *testing.T) {
+	c := NewMemoryCache(time.Minute, 0)
+	tagVersions := func() int {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return len(c.tagVersions)
+	}
+
+	// 没有加载在进行时不记录失效的标签
+	for i := 0; i < 100; i++ {
+		c.InvalidateTags(MovieTag(fmt.Sprint(i)))
+	}
+	if n := tagVersions(); n != 0 {
+		t.Fatalf("没有加载时记录了 %d 个标签", n)
+	}
+
+	loader := newBlockingLoader("v1")
+	result := make(chan interface{}, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), "movie:1", 0, loader.load, MovieTag("1"))
+		result <- v
+	}()
+	<-loader.started
+	for i := 0; i < 100; i++ {
+		c.InvalidateTags(MovieTag(fmt.Sprint(i)))
+	}
+	if n := tagVersions(); n != 100 {
+		t.Fatalf("加载期间记录了 %d 个标签，期望 100", n)
+	}
+	close(loader.release)
+	<-result
+
+	if _, ok := c.peek("movie:1"); ok {
+		t.Error("加载期间已失效的结果不应写入缓存")
+	}
+	if n := tagVersions(); n != 0 {
+		t.Errorf("加载结束后仍有 %d 个标签", n)
+	}
+}
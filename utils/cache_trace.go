@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// cacheTraceKey context中保存缓存访问记录的键
+type cacheTraceKey struct{}
+
+// cacheTraceState 记录一次请求中缓存查找的命中和未命中次数，共享缓存命中也算命中
+type cacheTraceState struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// WithCacheTrace 返回记录缓存访问的context，用于在响应中标识结果是否来自缓存
+func WithCacheTrace(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(cacheTraceKey{}).(*cacheTraceState); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheTraceKey{}, &cacheTraceState{})
+}
+
+// CacheHit 判断当前请求的结果是否完全来自缓存：至少查找过一次缓存且全部命中
+func CacheHit(ctx context.Context) bool {
+	state, ok := ctx.Value(cacheTraceKey{}).(*cacheTraceState)
+	return ok && state.hits.Load() > 0 && state.misses.Load() == 0
+}
+
+// traceCacheLookup 记录当前请求的一次缓存查找
+func traceCacheLookup(ctx context.Context, hit bool) {
+	state, ok := ctx.Value(cacheTraceKey{}).(*cacheTraceState)
+	if !ok {
+		return
+	}
+	if hit {
+		state.hits.Add(1)
+	} else {
+		state.misses.Add(1)
+	}
+}
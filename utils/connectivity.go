@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// ErrHBaseUnavailable 服务处于降级模式、HBase不可用时返回的错误
+var ErrHBaseUnavailable = errors.New("HBase暂不可用，服务处于降级模式")
+
+// probe 连接探测读取的表和行，由InitHBase根据配置设置
+var probe = struct {
+	table string
+	row   string
+}{
+	row: "1",
+}
+
+// connectivity HBase连接状态，由后台探测协程维护
+var connectivity = struct {
+	mu            sync.RWMutex
+	available     bool
+	degradedSince time.Time
+	lastProbe     time.Time
+	lastError     error
+}{
+	available: true,
+}
+
+// HBaseAvailable 判断HBase当前是否可用，不可用时服务处于降级模式
+func HBaseAvailable() bool {
+	connectivity.mu.RLock()
+	defer connectivity.mu.RUnlock()
+	return connectivity.available
+}
+
+// ConnectivityStatus 获取HBase连接状态，用于状态展示
+func ConnectivityStatus() map[string]interface{} {
+	connectivity.mu.RLock()
+	defer connectivity.mu.RUnlock()
+
+	status := map[string]interface{}{
+		"available": connectivity.available,
+	}
+	if !connectivity.lastProbe.IsZero() {
+		status["lastProbe"] = connectivity.lastProbe.Format(time.RFC3339)
+	}
+	if !connectivity.available {
+		status["degradedSince"] = connectivity.degradedSince.Format(time.RFC3339)
+		if connectivity.lastError != nil {
+			status["lastError"] = connectivity.lastError.Error()
+		}
+	}
+	return status
+}
+
+// setAvailable 更新HBase连接状态，状态切换时记录日志并调整缓存的过期策略
+func setAvailable(available bool, err error) {
+	connectivity.mu.Lock()
+	changed := connectivity.available != available
+	connectivity.available = available
+	connectivity.lastProbe = time.Now()
+	connectivity.lastError = err
+	if changed && !available {
+		connectivity.degradedSince = time.Now()
+	}
+	connectivity.mu.Unlock()
+
+	if available {
+		hbaseAvailable.Set(1)
+	} else {
+		hbaseAvailable.Set(0)
+	}
+
+	if !changed {
+		return
+	}
+	if available {
+		logrus.Info("HBase连接已恢复，退出降级模式")
+	} else {
+		logrus.Warnf("HBase不可用，进入降级模式，仅提供缓存数据: %v", err)
+	}
+
+	// 降级期间保留并继续提供已过期的缓存数据
+	if Cache != nil {
+		Cache.SetServeStale(!available)
+	}
+}
+
+// checkAvailable 降级模式下直接拒绝HBase请求，避免请求堆积在不可用的集群上
+func checkAvailable() error {
+	if !HBaseAvailable() {
+		return ErrHBaseUnavailable
+	}
+	return nil
+}
+
+// probeHBase 读取配置的探测行检查HBase是否可用，行不存在也视为可用
+// 探测直接使用客户端，不受降级状态和熔断器影响
+func probeHBase(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	table := probe.table
+	if table == "" {
+		table = Tables().Movies
+	}
+	return probeRow(ctx, table, probe.row)
+}
+
+// probeRow 读取指定表的一行，只要表可以访问就返回nil
+func probeRow(ctx context.Context, table, row string) error {
+	if hbaseClient == nil {
+		return errors.New("HBase客户端未初始化")
+	}
+
+	get, err := hrpc.NewGetStr(ctx, table, row)
+	if err != nil {
+		return fmt.Errorf("创建探测请求失败: %v", err)
+	}
+
+	start := time.Now()
+	_, err = hbaseClient.Get(get)
+	hbaseOpDuration.WithLabelValues(table, "Probe").Observe(time.Since(start).Seconds())
+	if err != nil {
+		hbaseOpErrors.WithLabelValues(table, "Probe").Inc()
+		return err
+	}
+	return nil
+}
+
+// monitorHBase 后台持续探测HBase
+// 可用时按ProbeInterval定期探测；不可用时从ProbeBackoff开始指数退避，直到ProbeMaxBackoff
+func monitorHBase(conf *config.HBaseConfig, stop <-chan struct{}) {
+	wait := conf.ProbeInterval
+	if !HBaseAvailable() {
+		wait = conf.ProbeBackoff
+	}
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		wasAvailable := HBaseAvailable()
+		err := probeHBase(conf.ProbeTimeout)
+		setAvailable(err == nil, err)
+
+		switch {
+		case err == nil:
+			wait = conf.ProbeInterval
+		case wasAvailable:
+			// 刚刚从可用变为不可用，从最短退避时间开始
+			wait = conf.ProbeBackoff
+		default:
+			logrus.Debugf("HBase探测失败，%v 后重试: %v", wait, err)
+			wait *= 2
+			if wait > conf.ProbeMaxBackoff {
+				wait = conf.ProbeMaxBackoff
+			}
+		}
+	}
+}
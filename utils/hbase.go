@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"gohbase/config"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,66 +12,122 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/tsuna/gohbase"
 	"github.com/tsuna/gohbase/hrpc"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var hbaseClient gohbase.Client
+// hbaseBackend HBase数据访问的底层实现
+// 原生RPC客户端(gohbase.Client)和Thrift2网关客户端都实现了该接口，由配置hbase.transport选择
+type hbaseBackend interface {
+	Get(get *hrpc.Get) (*hrpc.Result, error)
+	Put(put *hrpc.Mutate) (*hrpc.Result, error)
+	Scan(scan *hrpc.Scan) hrpc.Scanner
+	Close()
+}
+
+var hbaseClient hbaseBackend
 
-const RatingCacheTTL = 24 * time.Hour
+// hbaseMonitorStop 用于停止后台探测协程
+var hbaseMonitorStop chan struct{}
 
 // InitHBase 初始化HBase客户端
+// 连接探测失败时不会返回错误，而是以降级模式启动，由后台协程持续探测直到恢复
 func InitHBase(conf *config.HBaseConfig) error {
-	// 构建ZooKeeper连接字符串
-	zkQuorum := fmt.Sprintf("%s:%s", conf.ZkQuorum, conf.ZkPort)
+	// 探测使用的表和行
+	probe.table = conf.ProbeTable
+	if probe.table == "" {
+		probe.table = Tables().Movies
+	}
+	probe.row = conf.ProbeRow
 
-	// 创建HBase客户端
-	hbaseClient = gohbase.NewClient(zkQuorum)
+	// 按配置的访问方式创建HBase客户端
+	switch conf.Transport {
+	case config.TransportThrift:
+		hbaseClient = newThriftBackend(conf)
+		logrus.Infof("HBase客户端已创建 [Thrift2网关: %s, framed: %v]", conf.ThriftAddr(), conf.ThriftFramed)
+	default:
+		hbaseClient = gohbase.NewClient(conf.Quorum(), clientOptions(conf)...)
+		logrus.Infof("HBase客户端已创建 [ZooKeeper: %s, 根节点: %s]", conf.Quorum(), conf.ZkRoot)
+	}
 
-	// 测试连接是否成功
-	ctx := context.Background()
 	// 尝试获取一条记录来测试连接
-	get, err := hrpc.NewGetStr(ctx, "movies", "1")
-	if err != nil {
-		logrus.Errorf("创建Get请求失败: %v", err)
-		return err
+	if err := probeHBase(conf.ProbeTimeout); err != nil {
+		setAvailable(false, err)
+		logrus.Warnf("HBase连接失败，以降级模式启动: %v", err)
+	} else {
+		setAvailable(true, nil)
+		logrus.Info("HBase连接成功")
 	}
 
-	_, err = hbaseClient.Get(get)
-	if err != nil {
-		logrus.Errorf("HBase连接失败: %v", err)
-		return err
+	hbaseMonitorStop = make(chan struct{})
+	go monitorHBase(conf, hbaseMonitorStop)
+	return nil
+}
+
+// clientOptions 根据配置构建gohbase客户端选项
+func clientOptions(conf *config.HBaseConfig) []gohbase.Option {
+	options := []gohbase.Option{
+		gohbase.ZookeeperRoot(conf.ZkRoot),
+		gohbase.ZookeeperTimeout(conf.ZkTimeout),
+		gohbase.RegionLookupTimeout(conf.RegionLookupTimeout),
+		gohbase.RegionReadTimeout(conf.RegionReadTimeout),
+		gohbase.RpcQueueSize(conf.RPCQueueSize),
+		gohbase.FlushInterval(conf.FlushInterval),
+	}
+	if conf.EffectiveUser != "" {
+		options = append(options, gohbase.EffectiveUser(conf.EffectiveUser))
 	}
+	return options
+}
 
-	logrus.Info("HBase连接成功")
-	return nil
+// CloseHBase 停止后台探测并关闭HBase客户端，应在所有写入完成后最后调用
+func CloseHBase() {
+	if hbaseMonitorStop != nil {
+		close(hbaseMonitorStop)
+		hbaseMonitorStop = nil
+	}
+	if hbaseClient != nil {
+		hbaseClient.Close()
+	}
 }
 
-// GetClient 获取HBase客户端
+// GetClient 获取原生HBase客户端，通过Thrift2网关访问时返回nil
 func GetClient() gohbase.Client {
-	return hbaseClient
+	client, _ := hbaseClient.(gohbase.Client)
+	return client
 }
 
 // GetMovie 根据ID获取电影信息，从多个表中获取数据
 func GetMovie(ctx context.Context, movieID string) (map[string]map[string][]byte, error) {
+	data, _, err := GetMovieWithUpdatedTime(ctx, movieID)
+	return data, err
+}
+
+// GetMovieWithUpdatedTime 根据ID获取电影信息，同时返回读取到的单元格中最新的写入时间，用于HTTP的Last-Modified
+func GetMovieWithUpdatedTime(ctx context.Context, movieID string) (map[string]map[string][]byte, time.Time, error) {
+	ctx, span := StartSpan(ctx, "utils.GetMovie", attribute.String("movie.id", movieID))
+	defer span.End()
+
 	// 存储结果的映射
 	resultMap := make(map[string]map[string][]byte)
 
 	// 1. 从movies表获取基本信息
-	movieGet, err := hrpc.NewGetStr(ctx, "movies", movieID)
+	movieGet, err := hrpc.NewGetStr(ctx, Tables().Movies, movieID)
 	if err != nil {
 		logrus.Errorf("创建电影信息Get请求失败: %v", err)
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
-	movieResult, err := hbaseClient.Get(movieGet)
+	movieResult, err := HBaseGet(movieGet)
 	if err != nil {
 		logrus.Errorf("获取电影基本信息失败: %v", err)
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	// 如果没有找到电影，直接返回空
 	if movieResult.Cells == nil || len(movieResult.Cells) == 0 {
-		return nil, nil
+		return nil, time.Time{}, nil
 	}
+	updated := LatestCellTime(movieResult)
 
 	// 处理电影基本信息
 	for _, cell := range movieResult.Cells {
@@ -84,83 +141,118 @@ func GetMovie(ctx context.Context, movieID string) (map[string]map[string][]byte
 		resultMap[family][qualifier] = cell.Value
 	}
 
-	// 2. 从links表获取链接信息
-	linksGet, err := hrpc.NewGetStr(ctx, "links", movieID)
-	if err == nil { // 忽略错误，链接可能不存在
-		linksResult, err := hbaseClient.Get(linksGet)
-		if err == nil && linksResult.Cells != nil && len(linksResult.Cells) > 0 {
-			for _, cell := range linksResult.Cells {
-				family := "link" // 使用link作为映射键以保持与旧代码兼容
-				qualifier := string(cell.Qualifier)
+	// 2. 从links表获取链接信息，链接不存在时返回空结果而不是错误
+	linksGet, err := hrpc.NewGetStr(ctx, Tables().Links, movieID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
-				if _, ok := resultMap[family]; !ok {
-					resultMap[family] = make(map[string][]byte)
-				}
+	linksResult, err := HBaseGet(linksGet)
+	if err != nil {
+		// 允许部分结果时跳过链接信息，否则返回错误
+		if err := ToleratePartial(ctx, fmt.Errorf("获取电影链接信息失败: %w", err)); err != nil {
+			return nil, time.Time{}, err
+		}
+	} else if len(linksResult.Cells) > 0 {
+		updated = laterTime(updated, LatestCellTime(linksResult))
+		for _, cell := range linksResult.Cells {
+			family := "external"
+			qualifier := string(cell.Qualifier)
 
-				resultMap[family][qualifier] = cell.Value
+			if _, ok := resultMap[family]; !ok {
+				resultMap[family] = make(map[string][]byte)
 			}
+
+			resultMap[family][qualifier] = cell.Value
 		}
 	}
 
-	// 3. 从avg_ratings表获取平均评分信息
-	ratingGet, err := hrpc.NewGetStr(ctx, "avg_ratings", movieID)
-	if err == nil { // 忽略错误，评分可能不存在
-		ratingResult, err := hbaseClient.Get(ratingGet)
-		if err == nil && ratingResult.Cells != nil && len(ratingResult.Cells) > 0 {
-			for _, cell := range ratingResult.Cells {
-				family := "rating" // 使用rating作为映射键以保持与旧代码兼容
-				qualifier := string(cell.Qualifier)
+	// 3. 从avg_ratings表获取平均评分信息，评分不存在时返回空结果而不是错误
+	ratingGet, err := hrpc.NewGetStr(ctx, Tables().AvgRatings, movieID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
-				if _, ok := resultMap[family]; !ok {
-					resultMap[family] = make(map[string][]byte)
-				}
+	ratingResult, err := HBaseGet(ratingGet)
+	if err != nil {
+		// 允许部分结果时跳过评分信息，否则返回错误
+		if err := ToleratePartial(ctx, fmt.Errorf("获取电影评分信息失败: %w", err)); err != nil {
+			return nil, time.Time{}, err
+		}
+	} else if len(ratingResult.Cells) > 0 {
+		updated = laterTime(updated, LatestCellTime(ratingResult))
+		for _, cell := range ratingResult.Cells {
+			family := "stats"
+			qualifier := string(cell.Qualifier)
 
-				resultMap[family][qualifier] = cell.Value
+			if _, ok := resultMap[family]; !ok {
+				resultMap[family] = make(map[string][]byte)
 			}
+
+			resultMap[family][qualifier] = cell.Value
 		}
 	}
 
-	return resultMap, nil
+	return resultMap, updated, nil
 }
 
-// GetMovieWithFamilies 根据ID和指定的列族获取电影信息
-func GetMovieWithFamilies(ctx context.Context, movieID string, families []string) (map[string]map[string][]byte, error) {
-	// 构建列族映射
-	familiesMap := make(map[string][]string)
-	for _, family := range families {
-		familiesMap[family] = nil
+// LatestCellTime 返回结果中所有单元格最新的写入时间，没有时间戳时返回零值
+func LatestCellTime(results ...*hrpc.Result) time.Time {
+	var latest uint64
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, cell := range result.Cells {
+			if cell.Timestamp != nil && *cell.Timestamp > latest {
+				latest = *cell.Timestamp
+			}
+		}
 	}
-
-	// 创建Get请求并指定列族
-	get, err := hrpc.NewGetStr(ctx, "moviedata", movieID, hrpc.Families(familiesMap))
-	if err != nil {
-		return nil, err
+	if latest == 0 {
+		return time.Time{}
 	}
+	return time.UnixMilli(int64(latest))
+}
 
-	result, err := hbaseClient.Get(get)
-	if err != nil {
-		return nil, err
-	}
+// ratingMillisThreshold 评分时间戳按大小区分单位：小于该值的是秒（早于5138年），否则是毫秒（晚于1973年）
+const ratingMillisThreshold = 100_000_000_000
 
-	// 如果没有找到电影
-	if result.Cells == nil || len(result.Cells) == 0 {
-		return nil, nil
+// RatingTime 把评分的timestamp列转换为时间，没有时间戳时返回零值
+// import.py 导入的 MovieLens 评分是Unix秒，随机写入的评分是Unix毫秒，按数值大小判断单位
+func RatingTime(timestamp int64) time.Time {
+	switch {
+	case timestamp <= 0:
+		return time.Time{}
+	case timestamp < ratingMillisThreshold:
+		return time.Unix(timestamp, 0)
+	default:
+		return time.UnixMilli(timestamp)
 	}
+}
 
-	// 手动构建结果映射
-	resultMap := make(map[string]map[string][]byte)
+// laterTime 返回两个时间中较晚的一个
+func laterTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
 
-	for _, cell := range result.Cells {
-		family := string(cell.Family)
-		qualifier := string(cell.Qualifier)
+// GetMovieWithFamilies 根据ID获取电影信息，只保留指定的列族
+// 列族为GetMovie返回的映射键：info（movies表）、external（links表）、stats（avg_ratings表）
+func GetMovieWithFamilies(ctx context.Context, movieID string, families []string) (map[string]map[string][]byte, error) {
+	data, err := GetMovie(ctx, movieID)
+	if err != nil || data == nil {
+		return nil, err
+	}
 
-		if _, ok := resultMap[family]; !ok {
-			resultMap[family] = make(map[string][]byte)
+	resultMap := make(map[string]map[string][]byte, len(families))
+	for _, family := range families {
+		if columns, ok := data[family]; ok {
+			resultMap[family] = columns
 		}
-
-		resultMap[family][qualifier] = cell.Value
 	}
-
 	return resultMap, nil
 }
 
@@ -184,13 +276,23 @@ func GetMoviesMultiple(ctx context.Context, movieIDs []string) (map[string]map[s
 		}(id)
 	}
 
-	// 收集结果
+	// 收集结果，等待所有协程结束后再返回第一个无法容忍的错误
+	var firstErr error
 	for range movieIDs {
 		res := <-resultChan
-		if res.err == nil && res.data != nil {
+		if res.err != nil {
+			if err := ToleratePartial(ctx, res.err); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if res.data != nil {
 			results[res.id] = res.data
 		}
 	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
 
 	return results, nil
 }
@@ -227,23 +329,6 @@ func ParseMovieData(movieID string, data map[string]map[string][]byte) map[strin
 			links["tmdbUrl"] = fmt.Sprintf("https://www.themoviedb.org/movie/%s", tmdbIdStr)
 		}
 
-		result["links"] = links
-	} else if linkData, ok := data["link"]; ok {
-		// 兼容旧代码的link列族
-		links := map[string]interface{}{}
-
-		if imdbId, ok := linkData["imdbId"]; ok {
-			imdbIdStr := string(imdbId)
-			links["imdbId"] = imdbIdStr
-			links["imdbUrl"] = fmt.Sprintf("https://www.imdb.com/title/tt%s/", imdbIdStr)
-		}
-
-		if tmdbId, ok := linkData["tmdbId"]; ok {
-			tmdbIdStr := string(tmdbId)
-			links["tmdbId"] = tmdbIdStr
-			links["tmdbUrl"] = fmt.Sprintf("https://www.themoviedb.org/movie/%s", tmdbIdStr)
-		}
-
 		result["links"] = links
 	} else {
 		// 添加一个空的链接对象以避免前端错误
@@ -263,13 +348,6 @@ func ParseMovieData(movieID string, data map[string]map[string][]byte) map[strin
 				result["ratingCount"] = count
 			}
 		}
-	} else if ratingData, ok := data["rating"]; ok {
-		// 兼容旧代码的rating列族
-		if avgRating, ok := ratingData["rating"]; ok {
-			if rating, err := strconv.ParseFloat(string(avgRating), 64); err == nil {
-				result["avgRating"] = rating
-			}
-		}
 	}
 
 	return result
@@ -277,18 +355,23 @@ func ParseMovieData(movieID string, data map[string]map[string][]byte) map[strin
 
 // ScanMovies 扫描电影列表（带缓存）
 func ScanMovies(ctx context.Context, startRow, endRow string, limit int64) ([]*hrpc.Result, error) {
-	// 构建缓存键
+	// 构建缓存键，相同范围的并发扫描只执行一次
 	cacheKey := fmt.Sprintf("scan_movies:%s:%s:%d", startRow, endRow, limit)
-
-	// 检查缓存
-	if cachedResults, found := Cache.Get(cacheKey); found {
-		return cachedResults.([]*hrpc.Result), nil
+	value, err := Cache.GetOrLoad(ctx, cacheKey, 0, func(ctx context.Context) (interface{}, error) {
+		return scanMovies(ctx, startRow, endRow, limit)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return value.([]*hrpc.Result), nil
+}
 
+// scanMovies 扫描movies表中指定范围的电影
+func scanMovies(ctx context.Context, startRow, endRow string, limit int64) ([]*hrpc.Result, error) {
 	// 创建扫描
 	scan, err := hrpc.NewScanRangeStr(
 		ctx,
-		"movies",
+		Tables().Movies,
 		startRow,
 		endRow,
 		hrpc.NumberOfRows(uint32(limit)), // 设置最大行数
@@ -297,24 +380,8 @@ func ScanMovies(ctx context.Context, startRow, endRow string, limit int64) ([]*h
 		return nil, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
-	var results []*hrpc.Result
-
-	// 扫描并获取结果
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
-		}
-
-		results = append(results, res)
-	}
-
-	// 将结果存入缓存
-	Cache.Set(cacheKey, results)
-
-	return results, nil
+	// 扫描并获取结果，扫描出错时返回错误而不是当作扫描结束
+	return ScanAll(scan)
 }
 
 // ScanMoviesWithFamilies 带特定列族的电影列表扫描
@@ -328,7 +395,7 @@ func ScanMoviesWithFamilies(ctx context.Context, startRow, endRow string, famili
 	// 创建扫描
 	scan, err := hrpc.NewScanRangeStr(
 		ctx,
-		"movies",
+		Tables().Movies,
 		startRow,
 		endRow,
 		hrpc.Families(familiesMap),
@@ -338,46 +405,32 @@ func ScanMoviesWithFamilies(ctx context.Context, startRow, endRow string, famili
 		return nil, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
-	var results []*hrpc.Result
-
-	// 扫描并获取结果
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
-		}
-
-		results = append(results, res)
+	// 扫描并获取结果，扫描出错时返回错误而不是当作扫描结束
+	results, err := ScanAll(scan)
+	if err != nil {
+		return nil, err
 	}
 
 	return results, nil
 }
 
-// ScanMoviesByGenre 按类型扫描电影
+// ScanMoviesByGenre 按类型扫描movies表中的电影
 func ScanMoviesByGenre(ctx context.Context, genre string, limit int64) ([]*hrpc.Result, error) {
 	// 创建扫描
-	scan, err := hrpc.NewScanStr(ctx, "moviedata")
+	scan, err := hrpc.NewScanStr(ctx, Tables().Movies,
+		hrpc.Families(map[string][]string{"info": nil}))
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
 	var results []*hrpc.Result
 
 	// 扫描并获取结果，在应用层进行过滤
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
-		}
-
+	err = ScanEach(scan, func(res *hrpc.Result) bool {
 		// 过滤结果，检查是否包含指定类型
 		hasGenre := false
 		for _, cell := range res.Cells {
-			if string(cell.Family) == "movie" &&
+			if string(cell.Family) == "info" &&
 				string(cell.Qualifier) == "genres" &&
 				strings.Contains(string(cell.Value), genre) {
 				hasGenre = true
@@ -390,53 +443,73 @@ func ScanMoviesByGenre(ctx context.Context, genre string, limit int64) ([]*hrpc.
 
 			// 如果结果数量已经达到限制，则停止扫描
 			if int64(len(results)) >= limit {
-				break
+				return false
 			}
 		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return results, nil
 }
 
-// ScanMoviesByTag 按标签扫描电影
+// ScanMoviesByTag 按标签扫描电影，在tags表中查找标签后返回movies表中对应的电影
 func ScanMoviesByTag(ctx context.Context, tag string, limit int64) ([]*hrpc.Result, error) {
-	// 设置要获取的列族
-	familiesMap := map[string][]string{"tag": nil}
-
 	// 创建扫描请求
-	scan, err := hrpc.NewScanStr(ctx, "moviedata",
-		hrpc.Families(familiesMap))
+	scan, err := hrpc.NewScanStr(ctx, Tables().Tags,
+		hrpc.Families(map[string][]string{"data": {"tag"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
-	var results []*hrpc.Result
+	// 按标签出现的顺序收集电影ID，同一部电影只保留一次
+	var movieIDs []string
+	seen := make(map[string]bool)
 
-	// 扫描并获取结果并在应用层筛选包含标签的结果
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
+	err = ScanEach(scan, func(res *hrpc.Result) bool {
+		if len(res.Cells) == 0 {
+			return true
+		}
+
+		// 行键格式为 userId_movieId_timestamp
+		parts := strings.Split(string(res.Cells[0].Row), "_")
+		if len(parts) != 3 || seen[parts[1]] {
+			return true
 		}
 
-		// 过滤结果，检查是否包含指定标签
-		hasTag := false
 		for _, cell := range res.Cells {
-			if string(cell.Family) == "tag" && strings.Contains(string(cell.Value), tag) {
-				hasTag = true
+			if string(cell.Qualifier) == "tag" && strings.Contains(string(cell.Value), tag) {
+				seen[parts[1]] = true
+				movieIDs = append(movieIDs, parts[1])
 				break
 			}
 		}
 
-		if hasTag {
-			results = append(results, res)
+		// 如果结果数量已经达到限制，则停止扫描
+		return int64(len(movieIDs)) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			// 如果结果数量已经达到限制，则停止扫描
-			if int64(len(results)) >= limit {
-				break
+	results := make([]*hrpc.Result, 0, len(movieIDs))
+	for _, movieID := range movieIDs {
+		get, err := hrpc.NewGetStr(ctx, Tables().Movies, movieID)
+		if err != nil {
+			return nil, err
+		}
+		result, err := HBaseGet(get)
+		if err != nil {
+			if err := ToleratePartial(ctx, err); err != nil {
+				return nil, err
 			}
+			continue
+		}
+		if len(result.Cells) > 0 {
+			results = append(results, result)
 		}
 	}
 
@@ -452,7 +525,7 @@ func ScanMoviesWithPagination(ctx context.Context, page, pageSize int) ([]*hrpc.
 	// 创建扫描
 	scan, err := hrpc.NewScanRangeStr(
 		ctx,
-		"movies",
+		Tables().Movies,
 		startRow,
 		endRow,
 		hrpc.NumberOfRows(uint32(pageSize)),
@@ -461,43 +534,40 @@ func ScanMoviesWithPagination(ctx context.Context, page, pageSize int) ([]*hrpc.
 		return nil, 0, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
-	var results []*hrpc.Result
-
-	// 扫描并获取结果
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
-		}
-
-		results = append(results, res)
+	// 扫描并获取结果，扫描出错时返回错误而不是当作扫描结束
+	results, err := ScanAll(scan)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// 获取总记录数 - 这里我们假设固定数量，实际应用中应该从HBase获取
-	totalRecords := 9742 // 从文档了解到的总电影数量
+	totalRecords := config.Current().Limits.TotalMovies // 配置中的总电影数量
 
 	return results, totalRecords, nil
 }
 
 // GetMovieRatingStats 获取电影评分统计信息
 func GetMovieRatingStats(ctx context.Context, movieID string) (map[string]float64, error) {
-	// 构建缓存键
+	// 构建缓存键，同一部电影的并发请求只读取一次
 	cacheKey := fmt.Sprintf("movie_rating_stats:%s", movieID)
-
-	// 检查缓存
-	if cachedData, found := Cache.Get(cacheKey); found {
-		return cachedData.(map[string]float64), nil
+	value, err := Cache.GetOrLoad(ctx, cacheKey, 0, func(ctx context.Context) (interface{}, error) {
+		return loadMovieRatingStats(ctx, movieID)
+	}, MovieTag(movieID))
+	if err != nil {
+		return nil, err
 	}
+	return value.(map[string]float64), nil
+}
 
+// loadMovieRatingStats 从avg_ratings表读取评分统计，没有统计时从评分重新计算
+func loadMovieRatingStats(ctx context.Context, movieID string) (map[string]float64, error) {
 	// 创建Get请求，从avg_ratings表获取数据
-	get, err := hrpc.NewGetStr(ctx, "avg_ratings", movieID)
+	get, err := hrpc.NewGetStr(ctx, Tables().AvgRatings, movieID)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := hbaseClient.Get(get)
+	result, err := HBaseGet(get)
 	if err != nil {
 		return nil, err
 	}
@@ -509,17 +579,13 @@ func GetMovieRatingStats(ctx context.Context, movieID string) (map[string]float6
 		// 缓存未命中，从头开始计算
 		fullRatingsData, err := calculateMovieRatings(ctx, movieID)
 		if err != nil {
-			return nil, fmt.Errorf("计算电影 %s 的评分失败: %v", movieID, err)
+			return nil, fmt.Errorf("计算电影 %s 的评分失败: %w", movieID, err)
 		}
 
-		// 异步保存新的统计数据到avg_ratings表
-		go func() {
-			// 为后台任务创建一个新的上下文以避免被取消
-			bgCtx := context.Background()
-			if err := SaveMovieStats(bgCtx, movieID, fullRatingsData); err != nil {
-				logrus.Errorf("后台保存电影 %s 的统计信息失败: %v", movieID, err)
-			}
-		}()
+		// 异步保存新的统计数据到avg_ratings表，不完整的统计不写回
+		if !IsPartial(ctx) {
+			saveMovieStatsAsync(ctx, movieID, fullRatingsData)
+		}
 
 		// 转换数据为函数期望的返回类型
 		stats := map[string]float64{
@@ -529,9 +595,6 @@ func GetMovieRatingStats(ctx context.Context, movieID string) (map[string]float6
 			"countRatings": float64(fullRatingsData["count"].(int)),
 		}
 
-		// 将新计算的结果存入缓存
-		Cache.Set(cacheKey, stats)
-
 		return stats, nil
 	}
 
@@ -567,44 +630,78 @@ func GetMovieRatingStats(ctx context.Context, movieID string) (map[string]float6
 		"countRatings": float64(count),
 	}
 
-	// 将结果存入缓存
-	Cache.Set(cacheKey, stats)
-
 	return stats, nil
 }
 
+// TopMoviesByRatingCount 扫描avg_ratings表，返回评分人数最多的n部电影的ID，按评分人数从多到少排列
+// 只统计已保存评分统计的电影
+func TopMoviesByRatingCount(ctx context.Context, n int) ([]string, error) {
+	scan, err := hrpc.NewScanStr(ctx, Tables().AvgRatings,
+		hrpc.Families(map[string][]string{"stats": {"rating_count"}}))
+	if err != nil {
+		return nil, err
+	}
+
+	type movieCount struct {
+		movieID string
+		count   int64
+	}
+	var counts []movieCount
+	err = ScanEach(scan, func(res *hrpc.Result) bool {
+		for _, cell := range res.Cells {
+			if string(cell.Family) == "stats" && string(cell.Qualifier) == "rating_count" {
+				if count, err := strconv.ParseInt(string(cell.Value), 10, 64); err == nil {
+					counts = append(counts, movieCount{movieID: string(cell.Row), count: count})
+				}
+				break
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].movieID < counts[j].movieID
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	movieIDs := make([]string, len(counts))
+	for i, mc := range counts {
+		movieIDs[i] = mc.movieID
+	}
+	return movieIDs, nil
+}
+
 // GetMoviesByRatingRange 获取特定评分范围内的电影
 func GetMoviesByRatingRange(ctx context.Context, minRating, maxRating float64, limit int64) ([]string, error) {
 	// 构建缓存键
 	cacheKey := fmt.Sprintf("movies_by_rating:%f:%f:%d", minRating, maxRating, limit)
 
 	// 检查缓存
-	if cachedData, found := Cache.Get(cacheKey); found {
+	if cachedData, found := Cache.GetContext(ctx, cacheKey); found {
 		return cachedData.([]string), nil
 	}
 
 	// 创建扫描请求，从avg_ratings表获取数据
-	scan, err := hrpc.NewScanStr(ctx, "avg_ratings",
+	scan, err := hrpc.NewScanStr(ctx, Tables().AvgRatings,
 		hrpc.Families(map[string][]string{"stats": {"avg_rating"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取扫描器
-	scanner := hbaseClient.Scan(scan)
-
 	// 存储满足条件的电影ID
 	var matchedMovieIDs []string
 
 	// 扫描所有电影
-	for {
-		res, err := scanner.Next()
-		if err != nil {
-			break // 到达结尾或发生错误，终止循环
-		}
-
+	err = ScanEach(scan, func(res *hrpc.Result) bool {
 		if len(res.Cells) == 0 {
-			continue
+			return true
 		}
 
 		// 获取电影ID
@@ -625,80 +722,59 @@ func GetMoviesByRatingRange(ctx context.Context, minRating, maxRating float64, l
 
 			// 如果结果数量已经达到限制，则停止扫描
 			if int64(len(matchedMovieIDs)) >= limit {
-				break
+				return false
 			}
 		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 将结果存入缓存
-	Cache.Set(cacheKey, matchedMovieIDs)
+	// 将结果存入缓存，不完整的结果不缓存
+	// 任一电影的评分变化都可能改变结果，按整张表失效会让每次写入都清空缓存，因此只依赖较短的过期时间
+	if !IsPartial(ctx) {
+		Cache.Set(cacheKey, matchedMovieIDs)
+	}
 
 	return matchedMovieIDs, nil
 }
 
 // GetMovieWithAllData 获取电影的所有数据，包括基本信息、链接、评分和标签
 func GetMovieWithAllData(ctx context.Context, movieID string) (map[string]interface{}, error) {
-	// 获取电影的所有数据
-	get, err := hrpc.NewGetStr(ctx, "moviedata", movieID)
-	if err != nil {
+	// 从movies、links和avg_ratings表获取基本信息、链接和评分统计
+	data, err := GetMovie(ctx, movieID)
+	if err != nil || data == nil {
 		return nil, err
 	}
+	result := ParseMovieData(movieID, data)
 
-	result, err := hbaseClient.Get(get)
+	// 从tags表获取标签
+	tags, err := GetMovieTags(ctx, movieID)
 	if err != nil {
-		return nil, err
-	}
-
-	if result.Cells == nil || len(result.Cells) == 0 {
-		return nil, nil
-	}
-
-	// 手动构建结果映射
-	resultMap := make(map[string]map[string][]byte)
-
-	for _, cell := range result.Cells {
-		family := string(cell.Family)
-		qualifier := string(cell.Qualifier)
-
-		if _, ok := resultMap[family]; !ok {
-			resultMap[family] = make(map[string][]byte)
+		if err := ToleratePartial(ctx, fmt.Errorf("获取电影标签失败: %w", err)); err != nil {
+			return nil, err
 		}
-
-		resultMap[family][qualifier] = cell.Value
+		tags = []map[string]interface{}{}
 	}
+	result["tags"] = tags
 
-	return ParseMovieData(movieID, resultMap), nil
+	return result, nil
 }
 
-// EnableCompression 为表启用压缩功能
-// 注意：此功能需要HBase管理员权限，通常在初始设置时使用
-func EnableCompression(compression string) error {
-	// 检查压缩算法是否有效
-	validCompressions := map[string]bool{
-		"SNAPPY": true,
-		"GZ":     true,
-		"LZO":    true,
-		"NONE":   true,
-	}
-
-	if !validCompressions[strings.ToUpper(compression)] {
-		return fmt.Errorf("无效的压缩算法: %s. 有效的选项包括: SNAPPY, GZ, LZO, NONE", compression)
-	}
-
-	// 压缩设置通常通过HBase Shell执行
-	familyList := []string{"movie", "link", "rating", "tag"}
-	var commands []string
-
-	for _, family := range familyList {
-		cmd := fmt.Sprintf("alter 'moviedata', {NAME => '%s', COMPRESSION => '%s'}",
-			family, strings.ToUpper(compression))
-		commands = append(commands, cmd)
+// saveMovieStatsAsync 在后台保存电影统计信息，服务关闭时会等待保存完成
+func saveMovieStatsAsync(ctx context.Context, movieID string, stats map[string]interface{}) {
+	// 为后台任务创建一个新的上下文以避免被取消，但保留追踪关系
+	bgCtx := DetachedContext(ctx)
+	err := StatsSaves.Go(func() {
+		if err := SaveMovieStats(bgCtx, movieID, stats); err != nil {
+			logrus.Errorf("后台保存电影 %s 的统计信息失败: %v", movieID, err)
+		}
+	})
+	if err != nil {
+		logrus.Warnf("未保存电影 %s 的统计信息: %v", movieID, err)
 	}
-
-	// 只记录一条总体提示日志
-	logrus.Info("请在HBase Shell中执行压缩命令设置列族压缩")
-
-	return nil
 }
 
 // SaveMovieStats 将计算出的电影统计信息保存到avg_ratings表。
@@ -720,12 +796,12 @@ func SaveMovieStats(ctx context.Context, movieID string, stats map[string]interf
 		},
 	}
 
-	putRequest, err := hrpc.NewPutStr(ctx, "avg_ratings", movieID, values)
+	putRequest, err := hrpc.NewPutStr(ctx, Tables().AvgRatings, movieID, values)
 	if err != nil {
 		return fmt.Errorf("为avg_ratings创建Put请求失败: %v", err)
 	}
 
-	_, err = hbaseClient.Put(putRequest)
+	_, err = HBasePut(putRequest)
 	if err != nil {
 		return fmt.Errorf("写入avg_ratings失败: %v", err)
 	}
@@ -745,19 +821,24 @@ func SaveMovieStats(ctx context.Context, movieID string, stats map[string]interf
 // 它取代了旧的GetMovieRatings并包含了缓存逻辑。
 func GetMovieRatings(ctx context.Context, movieID string) (map[string]interface{}, error) {
 	// 1. 尝试从avg_ratings表（缓存）获取
-	get, err := hrpc.NewGetStr(ctx, "avg_ratings", movieID)
+	get, err := hrpc.NewGetStr(ctx, Tables().AvgRatings, movieID)
 	if err == nil {
-		result, err := hbaseClient.Get(get)
+		result, err := HBaseGet(get)
 		if err == nil && result != nil && len(result.Cells) > 0 {
 			cachedStats, updatedTime := parseAvgRatings(result)
-			if time.Since(updatedTime) < RatingCacheTTL {
+			if time.Since(updatedTime) < config.Current().Cache.RatingStatsTTL {
 				logrus.Infof("电影统计信息缓存命中: %s", movieID)
 				rawRatingsList, err := fetchRawRatingsList(ctx, movieID)
 				if err != nil {
-					logrus.Warnf("获取电影 %s 的原始评分列表失败: %v", movieID, err)
+					// 允许部分结果时返回空评分列表，否则返回错误
+					if err := ToleratePartial(ctx, fmt.Errorf("获取电影 %s 的原始评分列表失败: %w", movieID, err)); err != nil {
+						return nil, err
+					}
 					rawRatingsList = []map[string]interface{}{}
 				}
 				cachedStats["ratings"] = rawRatingsList
+				// 统计的计算时间，与评分的时间一起作为响应的Last-Modified
+				cachedStats["updatedTime"] = updatedTime
 				return cachedStats, nil
 			}
 			logrus.Infof("电影统计信息缓存已过期: %s", movieID)
@@ -768,18 +849,17 @@ func GetMovieRatings(ctx context.Context, movieID string) (map[string]interface{
 	logrus.Infof("电影统计信息缓存未命中，开始计算: %s", movieID)
 	fullRatingsData, err := calculateMovieRatings(ctx, movieID)
 	if err != nil {
-		return nil, fmt.Errorf("计算电影 %s 的评分失败: %v", movieID, err)
+		return nil, fmt.Errorf("计算电影 %s 的评分失败: %w", movieID, err)
+	}
+
+	// 不完整的统计不写回avg_ratings表，避免持久化错误数据
+	if IsPartial(ctx) {
+		return fullRatingsData, nil
 	}
 
 	// 3. 异步保存新的统计数据到avg_ratings表
 	logrus.Infof("触发异步存储电影ID %s 的平均评分", movieID)
-	go func() {
-		// 为后台任务创建一个新的上下文以避免被取消。
-		bgCtx := context.Background()
-		if err := SaveMovieStats(bgCtx, movieID, fullRatingsData); err != nil {
-			logrus.Errorf("后台保存电影 %s 的统计信息失败: %v", movieID, err)
-		}
-	}()
+	saveMovieStatsAsync(ctx, movieID, fullRatingsData)
 
 	return fullRatingsData, nil
 }
@@ -791,31 +871,24 @@ func calculateMovieRatings(ctx context.Context, movieID string) (map[string]inte
 	startRow := fmt.Sprintf("%s_", movieID)
 	endRow := fmt.Sprintf("%s_z", movieID)
 
-	scanRequest, err := hrpc.NewScanRangeStr(ctx, "movie_ratings", startRow, endRow,
+	scanRequest, err := hrpc.NewScanRangeStr(ctx, Tables().MovieRatings, startRow, endRow,
 		hrpc.Families(map[string][]string{"data": {"rating", "timestamp"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := hbaseClient.Scan(scanRequest)
-
 	ratingsList := make([]map[string]interface{}, 0)
 	var ratings []float64
 
-	for {
-		result, err := scanner.Next()
-		if err != nil {
-			break
-		}
-
+	err = ScanEach(scanRequest, func(result *hrpc.Result) bool {
 		if len(result.Cells) == 0 {
-			continue
+			return true
 		}
 
 		rowKey := string(result.Cells[0].Row)
 		parts := strings.Split(rowKey, "_")
 		if len(parts) != 2 {
-			continue
+			return true
 		}
 
 		userId := parts[1]
@@ -839,6 +912,11 @@ func calculateMovieRatings(ctx context.Context, movieID string) (map[string]inte
 				"timestamp": timestamp,
 			})
 		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if len(ratings) == 0 {
@@ -883,28 +961,23 @@ func fetchRawRatingsList(ctx context.Context, movieID string) ([]map[string]inte
 	startRow := fmt.Sprintf("%s_", movieID)
 	endRow := fmt.Sprintf("%s_z", movieID)
 
-	scanRequest, err := hrpc.NewScanRangeStr(ctx, "movie_ratings", startRow, endRow,
+	scanRequest, err := hrpc.NewScanRangeStr(ctx, Tables().MovieRatings, startRow, endRow,
 		hrpc.Families(map[string][]string{"data": {"rating", "timestamp"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := hbaseClient.Scan(scanRequest)
 	ratingsList := make([]map[string]interface{}, 0)
 
-	for {
-		result, err := scanner.Next()
-		if err != nil {
-			break
-		}
+	err = ScanEach(scanRequest, func(result *hrpc.Result) bool {
 		if len(result.Cells) == 0 {
-			continue
+			return true
 		}
 
 		rowKey := string(result.Cells[0].Row)
 		parts := strings.Split(rowKey, "_")
 		if len(parts) != 2 {
-			continue
+			return true
 		}
 
 		userId := parts[1]
@@ -927,6 +1000,11 @@ func fetchRawRatingsList(ctx context.Context, movieID string) ([]map[string]inte
 				"timestamp": timestamp,
 			})
 		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	return ratingsList, nil
 }
@@ -964,36 +1042,34 @@ func parseAvgRatings(result *hrpc.Result) (stats map[string]interface{}, updated
 
 // GetMovieTags 获取电影的所有标签
 func GetMovieTags(ctx context.Context, movieID string) ([]map[string]interface{}, error) {
-	// 构建缓存键
+	// 构建缓存键，同一部电影的并发请求只扫描一次
 	cacheKey := fmt.Sprintf("movie_tags:%s", movieID)
-
-	// 检查缓存
-	if cachedData, found := Cache.Get(cacheKey); found {
-		return cachedData.([]map[string]interface{}), nil
+	value, err := Cache.GetOrLoad(ctx, cacheKey, 0, func(ctx context.Context) (interface{}, error) {
+		return loadMovieTags(ctx, movieID)
+	}, MovieTag(movieID))
+	if err != nil {
+		return nil, err
 	}
+	return value.([]map[string]interface{}), nil
+}
 
+// loadMovieTags 扫描tags表，获取电影的所有标签
+func loadMovieTags(ctx context.Context, movieID string) ([]map[string]interface{}, error) {
 	// 创建扫描，使用tags表
 	// 使用扫描后在应用层过滤
-	scan, err := hrpc.NewScanStr(ctx, "tags",
+	scan, err := hrpc.NewScanStr(ctx, Tables().Tags,
 		hrpc.Families(map[string][]string{"data": {"tag"}}))
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := hbaseClient.Scan(scan)
-
 	// 存储标签的结果
 	tags := make([]map[string]interface{}, 0)
 
 	// 扫描所有结果
-	for {
-		result, err := scanner.Next()
-		if err != nil {
-			break // 扫描结束或发生错误
-		}
-
+	err = ScanEach(scan, func(result *hrpc.Result) bool {
 		if len(result.Cells) == 0 {
-			continue
+			return true
 		}
 
 		// 获取行键，格式为 userId_movieId_timestamp
@@ -1001,13 +1077,13 @@ func GetMovieTags(ctx context.Context, movieID string) ([]map[string]interface{}
 
 		// 检查行键是否包含目标电影ID
 		if !strings.Contains(rowKey, "_"+movieID+"_") {
-			continue // 跳过不相关的行
+			return true // 跳过不相关的行
 		}
 
 		// 解析行键
 		parts := strings.Split(rowKey, "_")
 		if len(parts) != 3 {
-			continue // 跳过格式不正确的行键
+			return true // 跳过格式不正确的行键
 		}
 
 		// 提取userId和timestamp
@@ -1033,73 +1109,39 @@ func GetMovieTags(ctx context.Context, movieID string) ([]map[string]interface{}
 			}
 			tags = append(tags, tagInfo)
 		}
-	}
 
-	// 将结果存入缓存
-	Cache.Set(cacheKey, tags)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return tags, nil
 }
 
-// GetUserRating 获取特定用户对电影的评分
+// GetUserRating 获取特定用户对电影的评分，用户没有评分时返回0
 func GetUserRating(ctx context.Context, movieID string, userID string) (float64, int64, error) {
-	// 构建要获取的列列表 - 适配通用格式
-	families := map[string][]string{
-		"rating": nil,
-	}
-
-	// 创建Get请求
-	get, err := hrpc.NewGetStr(ctx, "moviedata", movieID, hrpc.Families(families))
+	// ratings表的行键格式为 userId_movieId
+	get, err := hrpc.NewGetStr(ctx, Tables().Ratings, fmt.Sprintf("%s_%s", userID, movieID),
+		hrpc.Families(map[string][]string{"data": {"rating", "timestamp"}}))
 	if err != nil {
 		return 0, 0, err
 	}
 
-	result, err := hbaseClient.Get(get)
+	result, err := HBaseGet(get)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	// 如果没有找到电影或该用户没有评分
-	if result.Cells == nil || len(result.Cells) == 0 {
-		return 0, 0, nil
-	}
-
 	var rating float64
 	var timestamp int64
 
-	// 构建结果映射
-	resultMap := make(map[string]map[string][]byte)
 	for _, cell := range result.Cells {
-		family := string(cell.Family)
-		qualifier := string(cell.Qualifier)
-
-		if _, ok := resultMap[family]; !ok {
-			resultMap[family] = make(map[string][]byte)
-		}
-
-		resultMap[family][qualifier] = cell.Value
-	}
-
-	// 首先尝试获取通用格式的评分
-	if ratingData, ok := resultMap["rating"]; ok {
-		// 尝试读取通用格式的评分和时间戳
-		if ratingValue, ok := ratingData["rating"]; ok {
-			rating, _ = strconv.ParseFloat(string(ratingValue), 64)
-
-			if timestampValue, ok := ratingData["timestamp"]; ok {
-				timestamp, _ = strconv.ParseInt(string(timestampValue), 10, 64)
-			}
-
-			return rating, timestamp, nil
-		}
-
-		// 回退到旧的复合列格式
-		if ratingValue, ok := ratingData[fmt.Sprintf("rating:%s", userID)]; ok {
-			rating, _ = strconv.ParseFloat(string(ratingValue), 64)
-		}
-
-		if timestampValue, ok := ratingData[fmt.Sprintf("timestamp:%s", userID)]; ok {
-			timestamp, _ = strconv.ParseInt(string(timestampValue), 10, 64)
+		switch string(cell.Qualifier) {
+		case "rating":
+			rating, _ = strconv.ParseFloat(string(cell.Value), 64)
+		case "timestamp":
+			timestamp, _ = strconv.ParseInt(string(cell.Value), 10, 64)
 		}
 	}
 
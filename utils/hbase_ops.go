@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"gohbase/config"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tsuna/gohbase/hrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HBaseGet 执行Get请求，失败时按策略重试，并记录指标和追踪
+func HBaseGet(get *hrpc.Get) (*hrpc.Result, error) {
+	table := string(get.Table())
+	_, span := Tracer().Start(get.Context(), "hbase.Get",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "hbase"),
+			attribute.String("hbase.table", table),
+			attribute.String("hbase.row_key", string(get.Key())),
+		))
+	defer span.End()
+
+	if err := checkAvailable(); err != nil {
+		RecordSpanError(span, err)
+		return nil, err
+	}
+
+	var result *hrpc.Result
+	attempts := 0
+	err := withRetry(get.Context(), "Get", table, func() error {
+		attempts++
+		start := time.Now()
+
+		var err error
+		result, err = hbaseClient.Get(get)
+
+		hbaseOpDuration.WithLabelValues(table, "Get").Observe(time.Since(start).Seconds())
+		if err != nil {
+			hbaseOpErrors.WithLabelValues(table, "Get").Inc()
+		}
+		return err
+	})
+
+	span.SetAttributes(attribute.Int("hbase.attempts", attempts))
+	if err != nil {
+		RecordSpanError(span, err)
+		return nil, err
+	}
+
+	rows := 0
+	if result != nil && len(result.Cells) > 0 {
+		rows = 1
+	}
+	span.SetAttributes(attribute.Int("hbase.rows_returned", rows))
+	return result, nil
+}
+
+// HBasePut 执行Put请求，按Put策略重试（默认不重试），并记录指标和追踪
+func HBasePut(put *hrpc.Mutate) (*hrpc.Result, error) {
+	table := string(put.Table())
+	_, span := Tracer().Start(put.Context(), "hbase.Put",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "hbase"),
+			attribute.String("hbase.table", table),
+			attribute.String("hbase.row_key", string(put.Key())),
+		))
+	defer span.End()
+
+	if err := checkAvailable(); err != nil {
+		RecordSpanError(span, err)
+		return nil, err
+	}
+
+	var result *hrpc.Result
+	attempts := 0
+	err := withRetry(put.Context(), "Put", table, func() error {
+		attempts++
+		start := time.Now()
+
+		var err error
+		result, err = hbaseClient.Put(put)
+
+		hbaseOpDuration.WithLabelValues(table, "Put").Observe(time.Since(start).Seconds())
+		if err != nil {
+			hbaseOpErrors.WithLabelValues(table, "Put").Inc()
+		}
+		return err
+	})
+
+	span.SetAttributes(attribute.Int("hbase.attempts", attempts))
+	if err != nil {
+		RecordSpanError(span, err)
+		return result, err
+	}
+
+	// 写入成功后使依赖该行的缓存失效
+	invalidateForWrite(table, string(put.Key()))
+	return result, nil
+}
+
+// HBaseScan 打开扫描器，返回的扫描器在结束时记录耗时、行数和错误
+// 降级模式下或熔断器打开时，返回的扫描器在第一次Next时即返回错误
+func HBaseScan(scan *hrpc.Scan) hrpc.Scanner {
+	table := string(scan.Table())
+	_, span := Tracer().Start(scan.Context(), "hbase.Scan",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "hbase"),
+			attribute.String("hbase.table", table),
+			attribute.String("hbase.start_row", string(scan.StartRow())),
+			attribute.String("hbase.stop_row", string(scan.StopRow())),
+		))
+
+	s := &instrumentedScanner{
+		scan:    scan,
+		table:   table,
+		start:   time.Now(),
+		span:    span,
+		breaker: breakerFor(table),
+		policy:  retryPolicy("Scan"),
+	}
+	s.open()
+	return s
+}
+
+// instrumentedScanner 带重试、熔断、指标统计和追踪的扫描器
+type instrumentedScanner struct {
+	scanner  hrpc.Scanner
+	scan     *hrpc.Scan
+	openErr  error // 打开扫描器时被降级模式或熔断器拒绝的错误
+	table    string
+	start    time.Time
+	span     trace.Span
+	breaker  *CircuitBreaker
+	policy   config.RetryConfig
+	attempts int
+	rows     int
+	once     sync.Once
+}
+
+// open 在HBase可用且熔断器允许时打开底层扫描器
+func (s *instrumentedScanner) open() {
+	s.attempts++
+	if err := checkAvailable(); err != nil {
+		s.openErr = err
+		return
+	}
+	if err := s.breaker.Allow(); err != nil {
+		s.openErr = err
+		return
+	}
+	s.scanner = hbaseClient.Scan(s.scan)
+}
+
+// Next 获取下一行；尚未返回任何行时遇到可重试的错误会重新打开扫描器
+func (s *instrumentedScanner) Next() (*hrpc.Result, error) {
+	ctx := s.scan.Context()
+	backoff := s.policy.InitialBackoff
+
+	for {
+		if s.openErr != nil {
+			s.finish(s.openErr)
+			return nil, s.openErr
+		}
+
+		res, err := s.scanner.Next()
+		if err == nil {
+			s.rows++
+			return res, nil
+		}
+		if err == io.EOF {
+			s.finish(err)
+			return res, err
+		}
+
+		// 已经返回过数据的扫描无法安全地从头重试，直接返回错误
+		hbaseOpErrors.WithLabelValues(s.table, "Scan").Inc()
+		s.breaker.Record(err)
+		if s.rows > 0 || s.attempts > s.policy.MaxRetries || !isRetryable(ctx, err) {
+			s.finish(err)
+			return res, err
+		}
+
+		hbaseRetries.WithLabelValues(s.table, "Scan").Inc()
+		s.scanner.Close()
+		if err := sleepWithContext(ctx, jitter(backoff)); err != nil {
+			s.finish(err)
+			return nil, err
+		}
+		backoff = nextBackoff(backoff, s.policy)
+		s.open()
+	}
+}
+
+// Close 关闭扫描器，提前关闭时同样记录指标
+func (s *instrumentedScanner) Close() error {
+	s.finish(nil)
+	if s.scanner == nil {
+		return nil
+	}
+	return s.scanner.Close()
+}
+
+// finish 记录一次扫描的指标并结束Span，只记录一次
+func (s *instrumentedScanner) finish(err error) {
+	s.once.Do(func() {
+		// 扫描到结尾或已成功返回过数据才算成功；未读到数据就被关闭时只释放探测名额
+		// 打开时被熔断器拒绝的扫描没有占用名额，无需释放
+		switch {
+		case s.openErr != nil:
+		case err == io.EOF || (err == nil && s.rows > 0):
+			s.breaker.Record(nil)
+		case err == nil:
+			s.breaker.Release()
+		}
+
+		hbaseOpDuration.WithLabelValues(s.table, "Scan").Observe(time.Since(s.start).Seconds())
+		hbaseRowsScanned.WithLabelValues(s.table).Observe(float64(s.rows))
+		s.span.SetAttributes(
+			attribute.Int("hbase.rows_returned", s.rows),
+			attribute.Int("hbase.attempts", s.attempts),
+		)
+		if err != nil && err != io.EOF {
+			RecordSpanError(s.span, err)
+		}
+		s.span.End()
+	})
+}
+
+// GetScanMetrics 获取底层扫描器的扫描指标
+func (s *instrumentedScanner) GetScanMetrics() map[string]int64 {
+	if s.scanner == nil {
+		return nil
+	}
+	return s.scanner.GetScanMetrics()
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRatingTime(t *testing.T) {
+	cases := []struct {
+		name      string
+		timestamp int64
+		want      time.Time
+	}{
+		{"MovieLens导入的秒", 964982703, time.Date(2000, 7, 30, 18, 45, 3, 0, time.UTC)},
+		{"随机写入的毫秒", 1700000000123, time.Date(2023, 11, 14, 22, 13, 20, 123e6, time.UTC)},
+		{"没有时间戳", 0, time.Time{}},
+		{"无效的负数", -1, time.Time{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RatingTime(tc.timestamp); !got.Equal(tc.want) {
+				t.Errorf("RatingTime(%d) = %v，期望 %v", tc.timestamp, got.UTC(), tc.want)
+			}
+		})
+	}
+}
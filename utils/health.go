@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RequiredTables 服务正常运行所依赖的HBase表
+func RequiredTables() []string {
+	t := Tables()
+	return []string{t.Movies, t.Links, t.AvgRatings, t.Ratings, t.MovieRatings, t.Tags}
+}
+
+// readinessProbeRow 就绪检查读取的行，不要求该行存在
+const readinessProbeRow = "__readyz__"
+
+// CheckResult 单项依赖检查的结果
+type CheckResult struct {
+	Status    string                 `json:"status"` // up 或 down
+	Required  bool                   `json:"required"`
+	LatencyMs float64                `json:"latencyMs"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// readinessCheck 一项依赖检查，返回附加信息和错误
+type readinessCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) (map[string]interface{}, error)
+}
+
+// readinessChecks 构建所有依赖检查：每个必需的HBase表、缓存、缓存预热、共享缓存、缓存失效广播和写入服务
+func readinessChecks() []readinessCheck {
+	tables := RequiredTables()
+	checks := make([]readinessCheck, 0, len(tables)+5)
+	for _, table := range tables {
+		table := table
+		checks = append(checks, readinessCheck{
+			name:     "hbase:" + table,
+			required: true,
+			check: func(ctx context.Context) (map[string]interface{}, error) {
+				return nil, probeRow(ctx, table, readinessProbeRow)
+			},
+		})
+	}
+
+	checks = append(checks, readinessCheck{
+		name:     "cache",
+		required: true,
+		check:    checkCache,
+	}, readinessCheck{
+		name:     "cacheWarmup",
+		required: true,
+		check:    checkWarmup,
+	}, readinessCheck{
+		name:     "writeManager",
+		required: false,
+		check:    checkWriteManager,
+	})
+	// 共享缓存不可用时按未命中处理，不影响就绪
+	if Cache != nil && Cache.shared != nil {
+		checks = append(checks, readinessCheck{
+			name:     "sharedCache",
+			required: false,
+			check:    checkSharedCache,
+		})
+	}
+	if Invalidations != nil {
+		checks = append(checks, readinessCheck{
+			name:     "cacheInvalidation",
+			required: false,
+			check:    checkInvalidationBus,
+		})
+	}
+	return checks
+}
+
+// checkCache 检查缓存是否已初始化，只读取缓存项数量，不写入缓存也不访问共享缓存
+// 共享缓存由checkSharedCache单独检查
+func checkCache(ctx context.Context) (map[string]interface{}, error) {
+	if Cache == nil {
+		return nil, errors.New("缓存未初始化")
+	}
+	return map[string]interface{}{"entries": Cache.Len()}, nil
+}
+
+// checkSharedCache 检查共享缓存是否可以访问
+func checkSharedCache(ctx context.Context) (map[string]interface{}, error) {
+	stats := Cache.shared.Stats()
+	if pinger, ok := Cache.shared.(interface{ Ping(context.Context) error }); ok {
+		return stats, pinger.Ping(ctx)
+	}
+	return stats, nil
+}
+
+// checkInvalidationBus 检查缓存失效广播，最近一次发布或读取失败时视为异常
+func checkInvalidationBus(ctx context.Context) (map[string]interface{}, error) {
+	status := Invalidations.Status()
+	if lastErr, ok := status["lastError"].(string); ok {
+		return status, errors.New(lastErr)
+	}
+	return status, nil
+}
+
+// checkWarmup 就绪检查：预热进行中时视为未就绪，预热失败的项不影响就绪
+func checkWarmup(ctx context.Context) (map[string]interface{}, error) {
+	status := CacheWarmup.Status()
+	details := map[string]interface{}{
+		"state":  status.State,
+		"loaded": status.Loaded,
+		"failed": status.Failed,
+	}
+	if status.State == WarmupRunning {
+		return details, errors.New("缓存预热进行中")
+	}
+	return details, nil
+}
+
+// checkWriteManager 检查写入服务状态，运行中且队列已满时视为异常
+func checkWriteManager(ctx context.Context) (map[string]interface{}, error) {
+	status := WriteManagerInstance.Status()
+	depth, _ := status["queueDepth"].(int)
+	capacity, _ := status["queueCapacity"].(int)
+	if capacity > 0 && depth >= capacity {
+		return status, fmt.Errorf("写入队列已满 (%d/%d)", depth, capacity)
+	}
+	return status, nil
+}
+
+// CheckReadiness 并发执行所有依赖检查，任一必需依赖不可用时返回false
+func CheckReadiness(ctx context.Context) (bool, map[string]CheckResult) {
+	checks := readinessChecks()
+	results := make(map[string]CheckResult, len(checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c readinessCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			details, err := c.check(ctx)
+			result := CheckResult{
+				Status:    "up",
+				Required:  c.required,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Required && result.Status != "up" {
+			ready = false
+		}
+	}
+	return ready, results
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend 记录写入和删除次数的共享缓存
+type countingBackend struct {
+	*MemoryCache
+	writes atomic.Int32
+}
+
+func (b *countingBackend) SetWithTags(key string, value interface{}, duration time.Duration, tags ...string) {
+	b.writes.Add(1)
+	b.MemoryCache.SetWithTags(key, value, duration, tags...)
+}
+
+func (b *countingBackend) Delete(key string) bool {
+	b.writes.Add(1)
+	return b.MemoryCache.Delete(key)
+}
+
+func TestCheckCacheIsReadOnly(t *testing.T) {
+	prev := Cache
+	t.Cleanup(func() { Cache = prev })
+
+	shared := &countingBackend{MemoryCache: NewMemoryCache(time.Minute, 0)}
+	Cache = NewMemoryCache(time.Minute, 0)
+	Cache.SetShared(shared, 0)
+	Cache.Set("movie:1", "m")
+	before := Cache.Stats()
+	shared.writes.Store(0)
+
+	for i := 0; i < 3; i++ {
+		details, err := checkCache(context.Background())
+		if err != nil || details["entries"] != 1 {
+			t.Fatalf("就绪检查返回 %v, %v", details, err)
+		}
+	}
+
+	// 就绪检查不应写入、淘汰缓存项或访问共享缓存
+	after := Cache.Stats()
+	for _, key := range []string{"total", "hits", "misses", "evictions", "bytes"} {
+		if after[key] != before[key] {
+			t.Errorf("就绪检查后 %s 从 %v 变为 %v", key, before[key], after[key])
+		}
+	}
+	if n := shared.writes.Load(); n != 0 {
+		t.Errorf("就绪检查访问了 %d 次共享缓存", n)
+	}
+}
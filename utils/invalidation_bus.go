@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"gohbase/config"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InvalidationEvent 一次缓存失效，由写入数据的实例发布，其他实例收到后删除进程内对应的缓存项
+type InvalidationEvent struct {
+	Origin      string    `json:"origin"`                // 发布事件的实例
+	OccurredAt  time.Time `json:"occurredAt"`            // 合并的失效中最早一次发生的时间，用于计算传播延迟
+	Tags        []string  `json:"tags,omitempty"`        // 失效的依赖标签
+	Prefixes    []string  `json:"prefixes,omitempty"`    // 按前缀清除的键前缀
+	Keys        []string  `json:"keys,omitempty"`        // 删除的缓存键
+	Sequence    uint64    `json:"sequence,omitempty"`    // 同一实例发布的事件序号
+	PublishedAt time.Time `json:"publishedAt,omitempty"` // 发布时间
+}
+
+// invalidationTransport 失效事件的传输方式
+type invalidationTransport interface {
+	// publish 发布一个事件
+	publish(ctx context.Context, event InvalidationEvent) error
+	// poll 读取上次读取之后其他实例发布的事件，可能包含本实例发布的事件
+	poll(ctx context.Context) ([]InvalidationEvent, error)
+}
+
+// InvalidationBus 跨实例缓存失效广播
+// 写入数据的实例先删除自己的缓存项，再把失效合并后定期发布；其他实例定期读取并删除各自进程内的缓存项
+type InvalidationBus struct {
+	origin          string
+	kind            string
+	transport       invalidationTransport
+	publishInterval time.Duration
+	pollInterval    time.Duration
+
+	mu       sync.Mutex
+	pending  *pendingInvalidation // 尚未发布的失效
+	sequence uint64
+	lastPoll time.Time
+	lastErr  error
+	failing  bool // 最近一次读取是否失败，只在状态变化时记录日志
+
+	published atomic.Int64
+	applied   atomic.Int64
+	removed   atomic.Int64
+	lastLag   atomic.Int64 // 最近一次应用的事件的传播延迟（纳秒）
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// pendingInvalidation 合并中的失效，去除重复的标签、前缀和键
+type pendingInvalidation struct {
+	occurredAt time.Time
+	tags       map[string]struct{}
+	prefixes   map[string]struct{}
+	keys       map[string]struct{}
+}
+
+// Invalidations 全局缓存失效广播，未初始化时发布的失效只作用于本实例
+var Invalidations *InvalidationBus
+
+// InitInvalidationBus 根据配置创建并启动缓存失效广播
+func InitInvalidationBus(conf *config.CacheInvalidationConfig) {
+	Invalidations = newInvalidationBus(conf, newInstanceID())
+	Invalidations.start()
+	logrus.Infof("缓存失效广播已启动 [方式: %s, 实例: %s]", conf.Bus, Invalidations.origin)
+}
+
+// newInvalidationBus 创建缓存失效广播，origin为本实例的标识
+func newInvalidationBus(conf *config.CacheInvalidationConfig, origin string) *InvalidationBus {
+	bus := &InvalidationBus{
+		origin:          origin,
+		kind:            conf.Bus,
+		publishInterval: conf.PublishInterval,
+		pollInterval:    conf.PollInterval,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	switch conf.Bus {
+	case config.InvalidationBusHBase:
+		bus.transport = newHBaseInvalidationTransport(conf.MaxClockSkew)
+	default:
+		bus.transport = defaultInvalidationHub.join()
+	}
+	return bus
+}
+
+// newInstanceID 生成实例标识：主机名、进程号和随机数，重启后的实例不会与之前的混淆
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Publish 发布失效，在下一个发布间隔合并发送；bus为nil时不做任何事
+// 调用方需已删除本实例的缓存项
+func (b *InvalidationBus) Publish(event InvalidationEvent) {
+	if b == nil || len(event.Tags)+len(event.Prefixes)+len(event.Keys) == 0 {
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.merge(event)
+}
+
+// merge 把事件合并到尚未发布的失效中，调用方需持有锁
+func (b *InvalidationBus) merge(event InvalidationEvent) {
+	if b.pending == nil {
+		b.pending = &pendingInvalidation{
+			occurredAt: event.OccurredAt,
+			tags:       make(map[string]struct{}),
+			prefixes:   make(map[string]struct{}),
+			keys:       make(map[string]struct{}),
+		}
+	}
+	p := b.pending
+	if event.OccurredAt.Before(p.occurredAt) {
+		p.occurredAt = event.OccurredAt
+	}
+	for _, tag := range event.Tags {
+		p.tags[tag] = struct{}{}
+	}
+	for _, prefix := range event.Prefixes {
+		p.prefixes[prefix] = struct{}{}
+	}
+	for _, key := range event.Keys {
+		p.keys[key] = struct{}{}
+	}
+}
+
+// start 启动发布和读取的后台协程
+func (b *InvalidationBus) start() {
+	go b.run()
+}
+
+func (b *InvalidationBus) run() {
+	defer close(b.done)
+
+	publishTicker := time.NewTicker(b.publishInterval)
+	defer publishTicker.Stop()
+	pollTicker := time.NewTicker(b.pollInterval)
+	defer pollTicker.Stop()
+
+	for {
+		select {
+		case <-publishTicker.C:
+			b.flush(context.Background())
+		case <-pollTicker.C:
+			b.pollOnce(context.Background())
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// flush 发布合并后的失效，发布失败时放回，在下一个间隔与新的失效一起重试
+func (b *InvalidationBus) flush(ctx context.Context) error {
+	b.mu.Lock()
+	p := b.pending
+	b.pending = nil
+	if p == nil {
+		b.mu.Unlock()
+		return nil
+	}
+	b.sequence++
+	event := InvalidationEvent{
+		Origin:      b.origin,
+		OccurredAt:  p.occurredAt,
+		Tags:        sortedSet(p.tags),
+		Prefixes:    sortedSet(p.prefixes),
+		Keys:        sortedSet(p.keys),
+		Sequence:    b.sequence,
+		PublishedAt: time.Now(),
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, config.Current().Timeouts.Default)
+	defer cancel()
+	if err := b.transport.publish(ctx, event); err != nil {
+		cacheInvalidationEvents.WithLabelValues("published", "error").Inc()
+		b.mu.Lock()
+		b.merge(event)
+		b.lastErr = err
+		b.mu.Unlock()
+		logrus.Warnf("发布缓存失效事件失败，稍后重试: %v", err)
+		return err
+	}
+	b.published.Add(1)
+	cacheInvalidationEvents.WithLabelValues("published", "ok").Inc()
+	return nil
+}
+
+// pollOnce 读取其他实例发布的事件并应用到本实例的缓存
+func (b *InvalidationBus) pollOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, config.Current().Timeouts.Default)
+	defer cancel()
+
+	events, err := b.transport.poll(ctx)
+	b.mu.Lock()
+	b.lastPoll = time.Now()
+	b.lastErr = err
+	wasFailing := b.failing
+	b.failing = err != nil
+	b.mu.Unlock()
+	if err != nil {
+		cacheInvalidationEvents.WithLabelValues("applied", "error").Inc()
+		// HBase降级期间每次读取都会失败，只在开始失败时警告一次
+		if wasFailing {
+			logrus.Debugf("读取缓存失效事件失败: %v", err)
+		} else {
+			logrus.Warnf("读取缓存失效事件失败，恢复前持续重试: %v", err)
+		}
+		return
+	}
+	if wasFailing {
+		logrus.Info("读取缓存失效事件已恢复")
+	}
+
+	for _, event := range events {
+		if event.Origin == b.origin {
+			continue
+		}
+		removed := 0
+		if Cache != nil {
+			removed = Cache.applyInvalidation(event)
+		}
+		lag := time.Since(event.OccurredAt)
+		cacheInvalidationLag.Observe(lag.Seconds())
+		cacheInvalidationEvents.WithLabelValues("applied", "ok").Inc()
+		b.applied.Add(1)
+		b.removed.Add(int64(removed))
+		b.lastLag.Store(int64(lag))
+	}
+}
+
+// Stop 发布尚未发布的失效并停止后台协程，作为生命周期组件在HBase客户端关闭之前调用
+// 最后一次发布失败时，尚未发布的失效记为丢弃
+func (b *InvalidationBus) Stop(ctx context.Context) (int, error) {
+	b.stopOnce.Do(func() { close(b.stop) })
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return 1, nil
+	}
+	if err := b.flush(ctx); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// Status 失效广播的状态，用于状态接口和就绪检查
+func (b *InvalidationBus) Status() map[string]interface{} {
+	b.mu.Lock()
+	lastPoll, lastErr := b.lastPoll, b.lastErr
+	pending := b.pending != nil
+	b.mu.Unlock()
+
+	status := map[string]interface{}{
+		"bus":        b.kind,
+		"origin":     b.origin,
+		"published":  b.published.Load(),
+		"applied":    b.applied.Load(),
+		"removed":    b.removed.Load(),
+		"pending":    pending,
+		"lastLagMs":  time.Duration(b.lastLag.Load()).Milliseconds(),
+		"lastPollAt": lastPoll,
+	}
+	if lastErr != nil {
+		status["lastError"] = lastErr.Error()
+	}
+	return status
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// flakyTransport 按设定的错误依次返回读取结果
+type flakyTransport struct {
+	errs []error
+}
+
+func (t *flakyTransport) publish(ctx context.Context, event InvalidationEvent) error {
+	return nil
+}
+
+func (t *flakyTransport) poll(ctx context.Context) ([]InvalidationEvent, error) {
+	err := t.errs[0]
+	t.errs = t.errs[1:]
+	return nil, err
+}
+
+func TestPollOnceLogsStateChanges(t *testing.T) {
+	hook := test.NewGlobal()
+	t.Cleanup(hook.Reset)
+
+	unavailable := errors.New("HBase暂不可用")
+	transport := &flakyTransport{errs: []error{unavailable, unavailable, unavailable, nil, nil, unavailable}}
+	bus := &InvalidationBus{origin: "a", transport: transport}
+
+	levels := func() (warns, infos int) {
+		for _, entry := range hook.AllEntries() {
+			switch entry.Level {
+			case logrus.WarnLevel:
+				warns++
+			case logrus.InfoLevel:
+				infos++
+			}
+		}
+		return warns, infos
+	}
+
+	for i := 0; i < 3; i++ {
+		bus.pollOnce(context.Background())
+	}
+	if warns, infos := levels(); warns != 1 || infos != 0 {
+		t.Fatalf("连续失败3次记录了 %d 条警告、%d 条恢复，期望 1 条警告", warns, infos)
+	}
+	if _, ok := bus.Status()["lastError"]; !ok {
+		t.Error("失败期间状态中应有lastError")
+	}
+
+	bus.pollOnce(context.Background())
+	bus.pollOnce(context.Background())
+	if warns, infos := levels(); warns != 1 || infos != 1 {
+		t.Fatalf("恢复后记录了 %d 条警告、%d 条恢复，期望各 1 条", warns, infos)
+	}
+	if _, ok := bus.Status()["lastError"]; ok {
+		t.Error("恢复后状态中不应有lastError")
+	}
+
+	bus.pollOnce(context.Background())
+	if warns, _ := levels(); warns != 2 {
+		t.Errorf("再次失败后共记录了 %d 条警告，期望 2 条", warns)
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// invalidationHub 进程内的失效事件广播，每个成员收到其他成员发布的事件
+type invalidationHub struct {
+	mu      sync.Mutex
+	members map[*memoryInvalidationTransport]struct{}
+}
+
+// defaultInvalidationHub 单实例部署使用的进程内广播
+var defaultInvalidationHub = &invalidationHub{members: make(map[*memoryInvalidationTransport]struct{})}
+
+// join 加入广播
+func (h *invalidationHub) join() *memoryInvalidationTransport {
+	t := &memoryInvalidationTransport{hub: h}
+	h.mu.Lock()
+	h.members[t] = struct{}{}
+	h.mu.Unlock()
+	return t
+}
+
+// memoryInvalidationTransport 进程内广播的一个成员
+type memoryInvalidationTransport struct {
+	hub   *invalidationHub
+	mu    sync.Mutex
+	queue []InvalidationEvent
+}
+
+func (t *memoryInvalidationTransport) publish(ctx context.Context, event InvalidationEvent) error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	for member := range t.hub.members {
+		if member == t {
+			continue
+		}
+		member.mu.Lock()
+		member.queue = append(member.queue, event)
+		member.mu.Unlock()
+	}
+	return nil
+}
+
+func (t *memoryInvalidationTransport) poll(ctx context.Context) ([]InvalidationEvent, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.queue
+	t.queue = nil
+	return events, nil
+}
+
+// hbaseInvalidationTransport 通过HBase表广播失效事件
+// 行键以发布时间（纳秒，定长十进制）开头，读取时从上次读取的时间往前回看max_clock_skew，
+// 回看范围内已读取的行按行键去重；表的列族TTL负责清理旧事件
+type hbaseInvalidationTransport struct {
+	skew   time.Duration
+	cursor time.Time            // 上次读取开始的时间
+	seen   map[string]time.Time // 回看范围内已读取的行及其发布时间
+}
+
+// invalidationFamily 失效事件表的列族和列
+const (
+	invalidationFamily    = "e"
+	invalidationQualifier = "event"
+)
+
+func newHBaseInvalidationTransport(skew time.Duration) *hbaseInvalidationTransport {
+	return &hbaseInvalidationTransport{
+		skew:   skew,
+		cursor: time.Now(),
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// invalidationRowKey 失效事件的行键：发布时间_实例_序号
+func invalidationRowKey(event InvalidationEvent) string {
+	return fmt.Sprintf("%019d_%s_%d", event.PublishedAt.UnixNano(), event.Origin, event.Sequence)
+}
+
+func (t *hbaseInvalidationTransport) publish(ctx context.Context, event InvalidationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	put, err := hrpc.NewPutStr(ctx, Tables().CacheInvalidations, invalidationRowKey(event),
+		map[string]map[string][]byte{invalidationFamily: {invalidationQualifier: data}})
+	if err != nil {
+		return fmt.Errorf("为%s创建Put请求失败: %v", Tables().CacheInvalidations, err)
+	}
+	_, err = HBasePut(put)
+	return err
+}
+
+func (t *hbaseInvalidationTransport) poll(ctx context.Context) ([]InvalidationEvent, error) {
+	now := time.Now()
+	from := t.cursor.Add(-t.skew)
+	scan, err := hrpc.NewScanRangeStr(ctx, Tables().CacheInvalidations, fmt.Sprintf("%019d", from.UnixNano()), "",
+		hrpc.Families(map[string][]string{invalidationFamily: {invalidationQualifier}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []InvalidationEvent
+	read := make(map[string]time.Time)
+	err = ScanEach(scan, func(res *hrpc.Result) bool {
+		for _, cell := range res.Cells {
+			row := string(cell.Row)
+			if _, ok := t.seen[row]; ok {
+				continue
+			}
+			var event InvalidationEvent
+			if err := json.Unmarshal(cell.Value, &event); err != nil {
+				logrus.Warnf("忽略无法解析的缓存失效事件 %s: %v", row, err)
+				continue
+			}
+			read[row] = event.PublishedAt
+			events = append(events, event)
+		}
+		return true
+	})
+	if err != nil {
+		// 本次读到的事件不标记为已读，下次重新读取
+		return nil, err
+	}
+
+	for row, publishedAt := range read {
+		t.seen[row] = publishedAt
+	}
+	// 下次从now-skew开始读取，更早的行不会再读到，不需要继续记录
+	t.cursor = now
+	for row, publishedAt := range t.seen {
+		if publishedAt.Before(now.Add(-t.skew)) {
+			delete(t.seen, row)
+		}
+	}
+	return events, nil
+}
+
+// sortedSet 把集合转换为排序的切片，空集合返回nil
+func sortedSet(set map[string]struct{}) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(set))
+	for item := range set {
+		out = append(out, item)
+	}
+	sort.Strings(out)
+	return out
+}
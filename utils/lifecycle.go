@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrShuttingDown 服务正在关闭，不再接受新的后台任务
+var ErrShuttingDown = errors.New("服务正在关闭，不再接受新任务")
+
+// StopFunc 停止一个后台组件，需在ctx截止前返回，返回被丢弃的任务数
+type StopFunc func(ctx context.Context) (dropped int, err error)
+
+// component 注册到生命周期管理器的后台组件
+type component struct {
+	name string
+	stop StopFunc
+}
+
+// ShutdownReport 单个组件的关闭结果
+type ShutdownReport struct {
+	Name     string
+	Duration time.Duration
+	Dropped  int
+	Err      error
+}
+
+// LifecycleManager 管理后台组件的关闭顺序
+// 组件按注册的相反顺序关闭，最先注册的HBase客户端最后关闭
+type LifecycleManager struct {
+	mu         sync.Mutex
+	components []component
+	stopping   bool
+}
+
+// 全局生命周期管理器
+var Lifecycle = &LifecycleManager{}
+
+// Register 注册后台组件
+func (m *LifecycleManager) Register(name string, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, stop: stop})
+}
+
+// Stopping 判断服务是否正在关闭
+func (m *LifecycleManager) Stopping() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopping
+}
+
+// Shutdown 按注册的相反顺序依次关闭所有组件，并记录每个组件丢弃的任务数
+// 即使ctx已经截止，也会调用每个组件的关闭函数，保证资源被释放
+func (m *LifecycleManager) Shutdown(ctx context.Context) []ShutdownReport {
+	m.mu.Lock()
+	m.stopping = true
+	components := make([]component, len(m.components))
+	copy(components, m.components)
+	m.mu.Unlock()
+
+	reports := make([]ShutdownReport, 0, len(components))
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		start := time.Now()
+		dropped, err := c.stop(ctx)
+
+		report := ShutdownReport{
+			Name:     c.name,
+			Duration: time.Since(start),
+			Dropped:  dropped,
+			Err:      err,
+		}
+		reports = append(reports, report)
+
+		switch {
+		case err != nil:
+			logrus.Errorf("关闭 %s 失败 [耗时: %v, 丢弃: %d]: %v", c.name, report.Duration, dropped, err)
+		case dropped > 0:
+			logrus.Warnf("%s 已关闭，%d 个未完成的任务被丢弃 [耗时: %v]", c.name, dropped, report.Duration)
+		default:
+			logrus.Infof("%s 已关闭 [耗时: %v]", c.name, report.Duration)
+		}
+	}
+	return reports
+}
+
+// TaskGroup 跟踪异步执行的后台任务，关闭时等待正在执行的任务完成
+type TaskGroup struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	closed   bool
+	inFlight int
+	rejected int // 关闭后被拒绝的任务数
+}
+
+// NewTaskGroup 创建后台任务组
+func NewTaskGroup() *TaskGroup {
+	return &TaskGroup{}
+}
+
+// Go 异步执行任务，任务组已关闭时拒绝执行并返回ErrShuttingDown
+func (g *TaskGroup) Go(fn func()) error {
+	g.mu.Lock()
+	if g.closed {
+		g.rejected++
+		g.mu.Unlock()
+		return ErrShuttingDown
+	}
+	g.inFlight++
+	g.wg.Add(1)
+	g.mu.Unlock()
+
+	go func() {
+		defer func() {
+			g.mu.Lock()
+			g.inFlight--
+			g.mu.Unlock()
+			g.wg.Done()
+		}()
+		fn()
+	}()
+	return nil
+}
+
+// Stop 停止接受新任务，并等待正在执行的任务完成或ctx截止
+// 返回截止时仍未完成以及关闭期间被拒绝的任务数
+func (g *TaskGroup) Stop(ctx context.Context) (int, error) {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inFlight + g.rejected, nil
+}
+
+// StatsSaves 异步保存电影统计信息的任务组
+var StatsSaves = NewTaskGroup()
+
+// CacheRefreshes 后台刷新已过期缓存项的任务组
+var CacheRefreshes = NewTaskGroup()
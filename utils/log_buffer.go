@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDField 日志中记录请求ID所使用的字段名
+const RequestIDField = "requestId"
+
+// LogEntry 捕获的一条日志记录
+type LogEntry struct {
+	Seq       uint64                 `json:"seq"`
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"requestId,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+
+	// Gap 只在推送给订阅者时设置：推送这条日志之前，因该订阅者消费过慢而丢弃的日志
+	Gap *LogGap `json:"-"`
+
+	level logrus.Level
+}
+
+// LogGap 推送给订阅者时丢弃的一段日志，可以用Query从Since开始补齐FirstSeq到LastSeq之间的日志
+// 丢弃时不区分订阅者的过滤条件，其中可能包含不满足过滤条件的日志
+type LogGap struct {
+	FirstSeq uint64    `json:"firstSeq"`
+	LastSeq  uint64    `json:"lastSeq"`
+	Count    int64     `json:"count"`
+	Since    time.Time `json:"since"` // 第一条被丢弃的日志的时间
+}
+
+// LogQuery 日志查询条件
+type LogQuery struct {
+	Level    *logrus.Level // 最低日志级别，nil 表示不过滤
+	Since    time.Time     // 起始时间（包含），零值表示不限制
+	Until    time.Time     // 结束时间（包含），零值表示不限制
+	Contains string        // 消息或字段中包含的子串（不区分大小写）
+	Limit    int           // 返回的最大条数，<=0 表示不限制
+}
+
+// Match 判断日志是否满足查询条件
+func (q LogQuery) Match(entry LogEntry) bool {
+	// logrus 中级别数值越小越严重
+	if q.Level != nil && entry.level > *q.Level {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Timestamp.After(q.Until) {
+		return false
+	}
+	if q.Contains != "" {
+		needle := strings.ToLower(q.Contains)
+		if strings.Contains(strings.ToLower(entry.Message), needle) ||
+			strings.Contains(strings.ToLower(entry.RequestID), needle) {
+			return true
+		}
+		for k, v := range entry.Fields {
+			if strings.Contains(strings.ToLower(fmt.Sprintf("%s=%v", k, v)), needle) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// LogBuffer 有界环形日志缓冲区，同时作为logrus的Hook捕获真实日志
+type LogBuffer struct {
+	entries     []LogEntry
+	capacity    int
+	next        int // 下一个写入位置
+	size        int
+	seq         uint64
+	mu          sync.RWMutex
+	subscribers map[chan LogEntry]*LogGap // 每个订阅者尚未通知的丢弃
+	subMu       sync.Mutex
+	dropped     int64 // 因订阅者消费过慢而丢弃的推送数
+}
+
+// NewLogBuffer 创建指定容量的日志缓冲区
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &LogBuffer{
+		entries:     make([]LogEntry, capacity),
+		capacity:    capacity,
+		subscribers: make(map[chan LogEntry]*LogGap),
+	}
+}
+
+// Levels 实现logrus.Hook，捕获所有级别的日志
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 实现logrus.Hook，将日志写入环形缓冲区并推送给订阅者
+func (b *LogBuffer) Fire(e *logrus.Entry) error {
+	entry := LogEntry{
+		Timestamp: e.Time,
+		Level:     strings.ToUpper(e.Level.String()),
+		Message:   e.Message,
+		level:     e.Level,
+	}
+
+	if len(e.Data) > 0 {
+		entry.Fields = make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			if k == RequestIDField {
+				entry.RequestID = fmt.Sprint(v)
+				continue
+			}
+			// error 类型无法直接序列化为JSON，转换为字符串
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry.Fields[k] = v
+		}
+		if len(entry.Fields) == 0 {
+			entry.Fields = nil
+		}
+	}
+
+	b.mu.Lock()
+	b.seq++
+	entry.Seq = b.seq
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % b.capacity
+	if b.size < b.capacity {
+		b.size++
+	}
+	// 在持有锁时推送，保证订阅者按Seq递增的顺序收到日志；publish不会阻塞
+	b.publish(entry)
+	b.mu.Unlock()
+	return nil
+}
+
+// publish 推送日志给所有订阅者，订阅者消费过慢时丢弃而不阻塞日志调用方
+// 丢弃的日志记录在该订阅者的下一条推送中（LogEntry.Gap），订阅者可以据此补齐
+func (b *LogBuffer) publish(entry LogEntry) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	for ch, gap := range b.subscribers {
+		delivery := entry
+		delivery.Gap = gap
+		select {
+		case ch <- delivery:
+			b.subscribers[ch] = nil
+		default:
+			b.dropped++
+			if gap == nil {
+				gap = &LogGap{FirstSeq: entry.Seq, Since: entry.Timestamp}
+				b.subscribers[ch] = gap
+			}
+			gap.LastSeq = entry.Seq
+			gap.Count++
+		}
+	}
+}
+
+// Query 按条件查询日志，结果按时间正序排列，超出Limit时保留最新的记录
+func (b *LogBuffer) Query(q LogQuery) []LogEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]LogEntry, 0)
+	// 从最新的记录向前遍历，便于按Limit截取最新记录
+	for i := 0; i < b.size; i++ {
+		idx := (b.next - 1 - i + b.capacity) % b.capacity
+		entry := b.entries[idx]
+		if !q.Match(entry) {
+			continue
+		}
+		result = append(result, entry)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+
+	// 反转为时间正序
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Subscribe 订阅新日志，返回接收通道和取消订阅函数
+func (b *LogBuffer) Subscribe(buffer int) (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, buffer)
+
+	b.subMu.Lock()
+	b.subscribers[ch] = nil
+	b.subMu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.subMu.Lock()
+			delete(b.subscribers, ch)
+			b.subMu.Unlock()
+		})
+	}
+	return ch, cancel
+}
+
+// Stats 获取日志缓冲区统计信息
+func (b *LogBuffer) Stats() map[string]interface{} {
+	b.mu.RLock()
+	size := b.size
+	total := b.seq
+	b.mu.RUnlock()
+
+	b.subMu.Lock()
+	subscribers := len(b.subscribers)
+	dropped := b.dropped
+	b.subMu.Unlock()
+
+	return map[string]interface{}{
+		"capacity":    b.capacity,
+		"size":        size,
+		"total":       total,
+		"subscribers": subscribers,
+		"dropped":     dropped,
+	}
+}
+
+// 全局日志缓冲区实例
+var SystemLogs *LogBuffer
+
+// InitLogBuffer 初始化日志缓冲区并注册为logrus Hook
+func InitLogBuffer(capacity int) {
+	SystemLogs = NewLogBuffer(capacity)
+	logrus.AddHook(SystemLogs)
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fire 写入一条日志
+func fire(b *LogBuffer, message string) {
+	b.Fire(&logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: message})
+}
+
+func TestLogBufferReportsGapToSlowSubscriber(t *testing.T) {
+	b := NewLogBuffer(100)
+	ch, cancel := b.Subscribe(1)
+	defer cancel()
+	fast, cancelFast := b.Subscribe(10)
+	defer cancelFast()
+
+	for i := 1; i <= 4; i++ {
+		fire(b, fmt.Sprint(i))
+	}
+	if entry := <-ch; entry.Seq != 1 || entry.Gap != nil {
+		t.Fatalf("第一条推送为 %d，gap %+v", entry.Seq, entry.Gap)
+	}
+
+	// 消费过慢时丢弃的日志在下一条推送中说明
+	fire(b, "5")
+	entry := <-ch
+	if entry.Seq != 5 || entry.Gap == nil {
+		t.Fatalf("推送为 %d，gap %+v，期望带gap的第5条", entry.Seq, entry.Gap)
+	}
+	if gap := *entry.Gap; gap.FirstSeq != 2 || gap.LastSeq != 4 || gap.Count != 3 {
+		t.Errorf("gap为 %+v，期望第2到4条共3条", gap)
+	}
+
+	// 按gap的起始时间可以从缓冲区补齐丢弃的日志
+	var missed []uint64
+	for _, e := range b.Query(LogQuery{Since: entry.Gap.Since}) {
+		if e.Seq >= entry.Gap.FirstSeq && e.Seq <= entry.Gap.LastSeq {
+			missed = append(missed, e.Seq)
+		}
+	}
+	if len(missed) != 3 {
+		t.Errorf("补齐的日志为 %v，期望3条", missed)
+	}
+
+	// gap只通知一次，其他订阅者不受影响
+	fire(b, "6")
+	if entry := <-ch; entry.Gap != nil {
+		t.Errorf("第6条推送不应再带gap: %+v", entry.Gap)
+	}
+	for i := 1; i <= 6; i++ {
+		if entry := <-fast; entry.Seq != uint64(i) || entry.Gap != nil {
+			t.Fatalf("及时消费的订阅者收到 %d，gap %+v", entry.Seq, entry.Gap)
+		}
+	}
+	if dropped := b.Stats()["dropped"]; dropped != int64(3) {
+		t.Errorf("丢弃数为 %v，期望 3", dropped)
+	}
+}
+
+func TestLogBufferGapsAccountForEveryEntry(t *testing.T) {
+	b := NewLogBuffer(1000)
+	ch, cancel := b.Subscribe(4)
+	defer cancel()
+
+	const writers, perWriter = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				fire(b, "x")
+			}
+		}()
+	}
+
+	// 收到的日志加上gap中的日志应覆盖每一条，且Seq递增
+	const total = writers*perWriter + 1
+	var seen int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var last uint64
+		for last < total {
+			entry := <-ch
+			if gap := entry.Gap; gap != nil {
+				if gap.FirstSeq != last+1 || gap.LastSeq != entry.Seq-1 || gap.Count != int64(gap.LastSeq-gap.FirstSeq+1) {
+					t.Errorf("上一条为 %d，gap为 %+v，当前为 %d", last, gap, entry.Seq)
+				}
+				seen += gap.Count
+			} else if entry.Seq != last+1 {
+				t.Errorf("上一条为 %d，当前为 %d，中间没有gap", last, entry.Seq)
+			}
+			seen++
+			last = entry.Seq
+			time.Sleep(10 * time.Microsecond)
+		}
+	}()
+	wg.Wait()
+
+	// 通道有空位后再写入最后一条，之前的丢弃随它一起通知
+	for len(ch) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	fire(b, "last")
+	<-done
+
+	if seen != total {
+		t.Errorf("收到和丢弃的日志共 %d 条，期望 %d 条", seen, total)
+	}
+}
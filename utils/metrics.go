@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metricsNamespace 所有指标的命名空间
+const metricsNamespace = "movieapi"
+
+var (
+	// HTTP请求计数，按路由、方法和状态码区分
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP请求总数",
+	}, []string{"method", "route", "status"})
+
+	// HTTP请求耗时分布
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP请求耗时（秒）",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HBase操作耗时分布，按表和操作类型区分
+	hbaseOpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "operation_duration_seconds",
+		Help:      "HBase操作耗时（秒），Scan从打开到结束计时",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"table", "operation"})
+
+	// HBase操作错误计数
+	hbaseOpErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "operation_errors_total",
+		Help:      "HBase操作失败次数",
+	}, []string{"table", "operation"})
+
+	// HBase操作重试次数
+	hbaseRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "retries_total",
+		Help:      "HBase操作重试次数",
+	}, []string{"table", "operation"})
+
+	// 每个表的熔断器状态
+	hbaseBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "circuit_breaker_state",
+		Help:      "熔断器状态：0关闭，1半开，2打开",
+	}, []string{"table"})
+
+	// 被熔断器拒绝的请求数
+	hbaseBreakerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "熔断器打开期间被拒绝的HBase请求数",
+	}, []string{"table"})
+
+	// HBase是否可用，0表示服务处于降级模式
+	hbaseAvailable = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "available",
+		Help:      "HBase是否可用：1可用，0降级",
+	})
+
+	// 每次Scan请求扫描的行数分布
+	hbaseRowsScanned = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "hbase",
+		Name:      "scan_rows",
+		Help:      "每次Scan请求返回的行数",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"table"})
+
+	// 缓存访问计数，按键前缀和结果（hit/miss）区分
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "缓存访问次数",
+	}, []string{"prefix", "result"})
+
+	// 缓存淘汰计数，按键前缀和原因区分
+	cacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "缓存项被淘汰的次数",
+	}, []string{"prefix", "reason"})
+
+	// 共享缓存（L2）访问计数，按键前缀和结果（hit/miss）区分
+	cacheSharedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "shared_requests_total",
+		Help:      "共享缓存访问次数",
+	}, []string{"prefix", "result"})
+
+	// 共享缓存访问失败计数，按操作区分
+	cacheSharedErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "shared_errors_total",
+		Help:      "共享缓存访问失败次数",
+	}, []string{"op"})
+
+	// 缓存失效事件计数，按方向（published 发布、applied 应用其他实例的事件）和结果区分
+	cacheInvalidationEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "invalidation_events_total",
+		Help:      "跨实例缓存失效事件数",
+	}, []string{"direction", "result"})
+
+	// 缓存失效的传播延迟：从数据写入到其他实例删除进程内缓存项
+	cacheInvalidationLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "invalidation_lag_seconds",
+		Help:      "缓存失效从写入到在其他实例生效的延迟（秒）",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2, 5, 10, 30, 60},
+	})
+
+	// 当前缓存项数量（包括尚未清理的过期项）
+	cacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "entries",
+		Help:      "当前缓存项数量",
+	})
+
+	// 缓存项的估算内存占用
+	cacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cache",
+		Name:      "bytes",
+		Help:      "缓存项估算占用的内存字节数",
+	})
+
+	// 随机写入完成数，按结果区分
+	writeManagerWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "write_manager",
+		Name:      "writes_total",
+		Help:      "随机写入任务完成数",
+	}, []string{"status"})
+
+	// 随机写入因队列已满被丢弃的任务数
+	writeManagerDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "write_manager",
+		Name:      "dropped_total",
+		Help:      "因写入队列已满而丢弃的任务数",
+	})
+
+	// 随机写入队列当前长度
+	writeManagerQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "write_manager",
+		Name:      "queue_depth",
+		Help:      "写入队列中等待处理的任务数",
+	})
+)
+
+// keyPrefix 获取缓存键的前缀，用于指标分类
+func keyPrefix(key string) string {
+	if idx := strings.Index(key, ":"); idx >= 0 {
+		return key[:idx]
+	}
+	return key
+}
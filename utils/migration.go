@@ -0,0 +1,442 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// 旧moviedata表的行键为电影ID，各列族的布局：
+//   movie  - title、genres，迁移到movies表的info列族
+//   link   - imdbId、tmdbId，迁移到links表的external列族
+//   rating - rating:<userId>、timestamp:<userId>，迁移到ratings（userId_movieId）和movie_ratings（movieId_userId）表
+//   tag    - tag:<userId>（或<userId>）、timestamp:<userId>，迁移到tags表（userId_movieId_timestamp）
+// rating列族中不带用户ID的rating、timestamp列是旧的汇总数据，评分统计会从movie_ratings表重新计算，不迁移
+
+// MigrationOptions 迁移参数
+type MigrationOptions struct {
+	BatchSize  int       // 每批扫描的旧表行数，每批完成后保存检查点
+	Workers    int       // 并发写入或读取新表的协程数
+	Checkpoint string    // 检查点文件，为空时不保存检查点
+	Progress   io.Writer // 进度输出，为nil时不输出
+}
+
+// MigrationStats 迁移进度，同时作为检查点保存
+type MigrationStats struct {
+	LastRow string `json:"last_row"` // 最后完成的旧表行键
+	Rows    int    `json:"rows"`     // 已处理的旧表行数
+	Movies  int    `json:"movies"`
+	Links   int    `json:"links"`
+	Ratings int    `json:"ratings"`
+	Tags    int    `json:"tags"`
+	Skipped int    `json:"skipped"` // 无法识别而跳过的单元格数
+}
+
+// ParityReport 新旧表逐行校验的结果
+type ParityReport struct {
+	Rows       int      // 检查的旧表行数
+	Checked    int      // 检查的新表行数
+	Mismatched int      // 缺失或不一致的新表行数
+	Examples   []string // 前若干个不一致的行
+}
+
+// maxParityExamples 校验结果中保留的不一致示例数
+const maxParityExamples = 20
+
+// migrationRow 由旧表数据拆分出的一行新表数据
+type migrationRow struct {
+	logical string // 逻辑表名，用于统计
+	table   string
+	row     string
+	values  map[string]map[string][]byte
+}
+
+// MigrateMovieData 把moviedata表的数据复制到拆分后的表
+// 设置了检查点文件时从上次完成的行键之后继续，每批完成后更新检查点
+func MigrateMovieData(ctx context.Context, opts MigrationOptions) (MigrationStats, error) {
+	opts = opts.withDefaults()
+
+	var stats MigrationStats
+	if err := loadCheckpoint(opts.Checkpoint, &stats); err != nil {
+		return stats, err
+	}
+	if stats.LastRow != "" {
+		opts.progressf("从检查点继续，已完成 %d 行，最后行键 %s\n", stats.Rows, stats.LastRow)
+	}
+
+	err := eachLegacyBatch(ctx, stats.LastRow, opts.BatchSize, func(batch []*hrpc.Result) error {
+		var rows []migrationRow
+		for _, res := range batch {
+			split, skipped := splitLegacyRow(res)
+			rows = append(rows, split...)
+			stats.Skipped += skipped
+		}
+
+		err := runParallel(opts.Workers, len(rows), func(i int) error {
+			put, err := hrpc.NewPutStr(ctx, rows[i].table, rows[i].row, rows[i].values)
+			if err != nil {
+				return err
+			}
+			if _, err := HBasePut(put); err != nil {
+				return fmt.Errorf("写入 %s 表的行 %s 失败: %w", rows[i].table, rows[i].row, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, r := range rows {
+			switch r.logical {
+			case "movies":
+				stats.Movies++
+			case "links":
+				stats.Links++
+			case "ratings":
+				stats.Ratings++
+			case "tags":
+				stats.Tags++
+			}
+		}
+		stats.Rows += len(batch)
+		stats.LastRow = string(batch[len(batch)-1].Cells[0].Row)
+		if err := saveCheckpoint(opts.Checkpoint, stats); err != nil {
+			return err
+		}
+		opts.progressf("已迁移 %d 行（电影 %d，链接 %d，评分 %d，标签 %d，跳过 %d 个单元格），最后行键 %s\n",
+			stats.Rows, stats.Movies, stats.Links, stats.Ratings, stats.Tags, stats.Skipped, stats.LastRow)
+		return nil
+	})
+	return stats, err
+}
+
+// VerifyMovieData 逐行校验moviedata表的每一行在拆分后的表中都有相同的值
+// 新表中多出的列不视为不一致
+func VerifyMovieData(ctx context.Context, opts MigrationOptions) (ParityReport, error) {
+	opts = opts.withDefaults()
+
+	var report ParityReport
+	var mu sync.Mutex
+	err := eachLegacyBatch(ctx, "", opts.BatchSize, func(batch []*hrpc.Result) error {
+		var rows []migrationRow
+		for _, res := range batch {
+			split, _ := splitLegacyRow(res)
+			rows = append(rows, split...)
+		}
+
+		err := runParallel(opts.Workers, len(rows), func(i int) error {
+			diff, err := compareRow(ctx, rows[i])
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checked++
+			if diff != "" {
+				report.Mismatched++
+				if len(report.Examples) < maxParityExamples {
+					report.Examples = append(report.Examples, diff)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		report.Rows += len(batch)
+		opts.progressf("已校验 %d 行，新表 %d 行，不一致 %d 行\n", report.Rows, report.Checked, report.Mismatched)
+		return nil
+	})
+	return report, err
+}
+
+// withDefaults 补全未设置的参数
+func (o MigrationOptions) withDefaults() MigrationOptions {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	if o.Workers <= 0 {
+		o.Workers = 8
+	}
+	if o.Progress == nil {
+		o.Progress = io.Discard
+	}
+	return o
+}
+
+// progressf 输出进度
+func (o MigrationOptions) progressf(format string, args ...interface{}) {
+	fmt.Fprintf(o.Progress, format, args...)
+}
+
+// eachLegacyBatch 从after之后按批扫描moviedata表
+// 每批使用新的扫描器，避免处理较慢时扫描器租约过期
+func eachLegacyBatch(ctx context.Context, after string, batchSize int, fn func([]*hrpc.Result) error) error {
+	start := ""
+	if after != "" {
+		start = after + "\x00"
+	}
+
+	for {
+		scan, err := hrpc.NewScanRangeStr(ctx, Tables().MovieData, start, "",
+			hrpc.NumberOfRows(uint32(batchSize)))
+		if err != nil {
+			return err
+		}
+
+		batch := make([]*hrpc.Result, 0, batchSize)
+		err = ScanEach(scan, func(res *hrpc.Result) bool {
+			if len(res.Cells) > 0 {
+				batch = append(batch, res)
+			}
+			return len(batch) < batchSize
+		})
+		if err != nil {
+			return fmt.Errorf("扫描 %s 表失败: %w", Tables().MovieData, err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		start = string(batch[len(batch)-1].Cells[0].Row) + "\x00"
+	}
+}
+
+// splitLegacyRow 把moviedata表的一行拆分为新表的行，返回无法识别的单元格数
+func splitLegacyRow(res *hrpc.Result) ([]migrationRow, int) {
+	tables := Tables()
+	movieID := string(res.Cells[0].Row)
+
+	info := make(map[string][]byte)
+	external := make(map[string][]byte)
+	type userCells struct {
+		value     []byte
+		timestamp []byte
+		cellTime  *uint64
+	}
+	ratings := make(map[string]*userCells)
+	tags := make(map[string]*userCells)
+	user := func(m map[string]*userCells, userID string) *userCells {
+		if m[userID] == nil {
+			m[userID] = &userCells{}
+		}
+		return m[userID]
+	}
+
+	skipped := 0
+	for _, cell := range res.Cells {
+		qualifier := string(cell.Qualifier)
+		switch string(cell.Family) {
+		case "movie":
+			info[qualifier] = cell.Value
+		case "link":
+			external[qualifier] = cell.Value
+		case "rating":
+			if userID, ok := strings.CutPrefix(qualifier, "rating:"); ok && userID != "" {
+				u := user(ratings, userID)
+				u.value, u.cellTime = cell.Value, cell.Timestamp
+			} else if userID, ok := strings.CutPrefix(qualifier, "timestamp:"); ok && userID != "" {
+				user(ratings, userID).timestamp = cell.Value
+			} else {
+				skipped++
+			}
+		case "tag":
+			if userID, ok := strings.CutPrefix(qualifier, "timestamp:"); ok && userID != "" {
+				user(tags, userID).timestamp = cell.Value
+			} else if userID := legacyTagUser(qualifier); userID != "" {
+				u := user(tags, userID)
+				u.value, u.cellTime = cell.Value, cell.Timestamp
+			} else {
+				skipped++
+			}
+		default:
+			skipped++
+		}
+	}
+
+	// 没有单独保存时间戳的评分和标签使用单元格的写入时间（毫秒），与随机写入一致
+	timestampOf := func(u *userCells) []byte {
+		if len(u.timestamp) > 0 {
+			return u.timestamp
+		}
+		if u.cellTime != nil {
+			return []byte(strconv.FormatUint(*u.cellTime, 10))
+		}
+		return []byte("0")
+	}
+
+	var rows []migrationRow
+	if len(info) > 0 {
+		rows = append(rows, migrationRow{"movies", tables.Movies, movieID, map[string]map[string][]byte{"info": info}})
+	}
+	if len(external) > 0 {
+		rows = append(rows, migrationRow{"links", tables.Links, movieID, map[string]map[string][]byte{"external": external}})
+	}
+	for _, userID := range sortedUserIDs(ratings) {
+		u := ratings[userID]
+		if u.value == nil {
+			skipped++ // 只有时间戳没有评分
+			continue
+		}
+		values := map[string]map[string][]byte{"data": {"rating": u.value, "timestamp": timestampOf(u)}}
+		rows = append(rows,
+			migrationRow{"ratings", tables.Ratings, userID + "_" + movieID, values},
+			migrationRow{"movie_ratings", tables.MovieRatings, movieID + "_" + userID, values})
+	}
+	for _, userID := range sortedUserIDs(tags) {
+		u := tags[userID]
+		if u.value == nil {
+			skipped++
+			continue
+		}
+		ts := timestampOf(u)
+		rows = append(rows, migrationRow{"tags", tables.Tags, userID + "_" + movieID + "_" + string(ts),
+			map[string]map[string][]byte{"data": {"tag": u.value}}})
+	}
+	return rows, skipped
+}
+
+// legacyTagUser 从tag列族的列名中取出用户ID，列名为tag:<userId>或<userId>
+func legacyTagUser(qualifier string) string {
+	userID := strings.TrimPrefix(qualifier, "tag:")
+	if _, err := strconv.ParseUint(userID, 10, 64); err != nil {
+		return ""
+	}
+	return userID
+}
+
+// sortedUserIDs 按字母顺序返回用户ID，使写入顺序稳定
+func sortedUserIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// compareRow 读取新表中的行并与期望值比较，一致时返回空字符串
+func compareRow(ctx context.Context, want migrationRow) (string, error) {
+	families := make(map[string][]string, len(want.values))
+	for family := range want.values {
+		families[family] = nil
+	}
+	get, err := hrpc.NewGetStr(ctx, want.table, want.row, hrpc.Families(families))
+	if err != nil {
+		return "", err
+	}
+	res, err := HBaseGet(get)
+	if err != nil {
+		return "", fmt.Errorf("读取 %s 表的行 %s 失败: %w", want.table, want.row, err)
+	}
+	if len(res.Cells) == 0 {
+		return fmt.Sprintf("%s 表缺少行 %s", want.table, want.row), nil
+	}
+
+	got := make(map[string][]byte, len(res.Cells))
+	for _, cell := range res.Cells {
+		got[string(cell.Family)+":"+string(cell.Qualifier)] = cell.Value
+	}
+	var diffs []string
+	for family, columns := range want.values {
+		for qualifier, value := range columns {
+			column := family + ":" + qualifier
+			if actual, ok := got[column]; !ok {
+				diffs = append(diffs, column+" 缺失")
+			} else if !bytes.Equal(actual, value) {
+				diffs = append(diffs, fmt.Sprintf("%s 为 %q，期望 %q", column, actual, value))
+			}
+		}
+	}
+	if len(diffs) == 0 {
+		return "", nil
+	}
+	sort.Strings(diffs)
+	return fmt.Sprintf("%s 表的行 %s: %s", want.table, want.row, strings.Join(diffs, "; ")), nil
+}
+
+// runParallel 以最多workers个协程执行fn(0..n-1)，返回第一个错误
+func runParallel(workers, n int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, workers)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(i); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// loadCheckpoint 读取检查点，文件不存在时保持零值
+func loadCheckpoint(path string, stats *MigrationStats) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取检查点失败: %w", err)
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return fmt.Errorf("解析检查点 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// saveCheckpoint 写入检查点，先写临时文件再重命名，中断时不会留下不完整的检查点
+func saveCheckpoint(path string, stats MigrationStats) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("保存检查点失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存检查点失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存检查点失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存检查点失败: %w", err)
+	}
+	return nil
+}
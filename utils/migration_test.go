@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// memoryBackend 进程内的HBase访问方式，按表保存单元格，用于测试迁移和校验
+type memoryBackend struct {
+	mu     sync.Mutex
+	tables map[string]map[string][]*hrpc.Cell // 表 -> 行键 -> 单元格
+}
+
+// useMemoryBackend 替换全局HBase客户端，测试结束时恢复
+func useMemoryBackend(t *testing.T) *memoryBackend {
+	t.Helper()
+	b := &memoryBackend{tables: map[string]map[string][]*hrpc.Cell{}}
+	prev := hbaseClient
+	hbaseClient = b
+	t.Cleanup(func() { hbaseClient = prev })
+	return b
+}
+
+// cell 构造单元格，ts为0时不设置写入时间
+func cell(row, family, qualifier, value string, ts uint64) *hrpc.Cell {
+	c := &hrpc.Cell{Row: []byte(row), Family: []byte(family), Qualifier: []byte(qualifier), Value: []byte(value)}
+	if ts > 0 {
+		c.Timestamp = &ts
+	}
+	return c
+}
+
+// add 写入测试数据
+func (b *memoryBackend) add(table string, cells ...*hrpc.Cell) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tables[table] == nil {
+		b.tables[table] = map[string][]*hrpc.Cell{}
+	}
+	for _, c := range cells {
+		b.tables[table][string(c.Row)] = append(b.tables[table][string(c.Row)], c)
+	}
+}
+
+// values 按“列族:列”返回一行的值
+func (b *memoryBackend) values(table, row string) map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cells, ok := b.tables[table][row]
+	if !ok {
+		return nil
+	}
+	values := make(map[string]string, len(cells))
+	for _, c := range cells {
+		values[string(c.Family)+":"+string(c.Qualifier)] = string(c.Value)
+	}
+	return values
+}
+
+// rows 返回表中的行键
+func (b *memoryBackend) rows(table string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rowsLocked(table)
+}
+
+func (b *memoryBackend) Get(get *hrpc.Get) (*hrpc.Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &hrpc.Result{Cells: b.tables[string(get.Table())][string(get.Key())]}, nil
+}
+
+func (b *memoryBackend) Put(put *hrpc.Mutate) (*hrpc.Result, error) {
+	table, row := string(put.Table()), string(put.Key())
+	values := put.Values()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var cells []*hrpc.Cell
+	for _, c := range b.tables[table][row] {
+		if _, replaced := values[string(c.Family)][string(c.Qualifier)]; !replaced {
+			cells = append(cells, c)
+		}
+	}
+	for family, columns := range values {
+		for qualifier, value := range columns {
+			cells = append(cells, cell(row, family, qualifier, string(value), 0))
+		}
+	}
+	if b.tables[table] == nil {
+		b.tables[table] = map[string][]*hrpc.Cell{}
+	}
+	b.tables[table][row] = cells
+	return &hrpc.Result{}, nil
+}
+
+func (b *memoryBackend) Scan(scan *hrpc.Scan) hrpc.Scanner {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start, stop := string(scan.StartRow()), string(scan.StopRow())
+	s := &memoryScanner{}
+	for _, row := range b.rowsLocked(string(scan.Table())) {
+		if row >= start && (stop == "" || row < stop) {
+			s.results = append(s.results, &hrpc.Result{Cells: b.tables[string(scan.Table())][row]})
+		}
+	}
+	return s
+}
+
+// rowsLocked 返回排序后的行键，调用方需持有锁
+func (b *memoryBackend) rowsLocked(table string) []string {
+	var rows []string
+	for row := range b.tables[table] {
+		rows = append(rows, row)
+	}
+	sort.Strings(rows)
+	return rows
+}
+
+func (b *memoryBackend) Close() {}
+
+// memoryScanner 依次返回扫描开始时的结果
+type memoryScanner struct {
+	results []*hrpc.Result
+}
+
+func (s *memoryScanner) Next() (*hrpc.Result, error) {
+	if len(s.results) == 0 {
+		return nil, io.EOF
+	}
+	res := s.results[0]
+	s.results = s.results[1:]
+	return res, nil
+}
+
+func (s *memoryScanner) Close() error { return nil }
+
+func (s *memoryScanner) GetScanMetrics() map[string]int64 { return nil }
+
+func TestSplitLegacyRow(t *testing.T) {
+	tables := Tables()
+	type want struct {
+		table  string
+		row    string
+		values map[string]string // 列名 -> 值
+	}
+	cases := []struct {
+		name        string
+		cells       []*hrpc.Cell
+		want        []want
+		wantSkipped int
+	}{
+		{
+			name: "电影信息和外部链接",
+			cells: []*hrpc.Cell{
+				cell("1", "movie", "title", "Toy Story (1995)", 0),
+				cell("1", "movie", "genres", "Animation|Children", 0),
+				cell("1", "link", "imdbId", "0114709", 0),
+				cell("1", "link", "tmdbId", "862", 0),
+			},
+			want: []want{
+				{tables.Movies, "1", map[string]string{"title": "Toy Story (1995)", "genres": "Animation|Children"}},
+				{tables.Links, "1", map[string]string{"imdbId": "0114709", "tmdbId": "862"}},
+			},
+		},
+		{
+			name: "评分按用户拆分到两张表",
+			cells: []*hrpc.Cell{
+				cell("1", "rating", "rating:7", "4.5", 1700000000123),
+				cell("1", "rating", "timestamp:7", "964982703", 0),
+				cell("1", "rating", "rating:12", "3.0", 0),
+				cell("1", "rating", "timestamp:12", "964983000", 0),
+			},
+			want: []want{
+				{tables.Ratings, "12_1", map[string]string{"rating": "3.0", "timestamp": "964983000"}},
+				{tables.MovieRatings, "1_12", map[string]string{"rating": "3.0", "timestamp": "964983000"}},
+				{tables.Ratings, "7_1", map[string]string{"rating": "4.5", "timestamp": "964982703"}},
+				{tables.MovieRatings, "1_7", map[string]string{"rating": "4.5", "timestamp": "964982703"}},
+			},
+		},
+		{
+			// 没有timestamp列时使用单元格的写入时间
+			name:  "评分时间使用单元格写入时间",
+			cells: []*hrpc.Cell{cell("1", "rating", "rating:7", "4.5", 1700000000123)},
+			want: []want{
+				{tables.Ratings, "7_1", map[string]string{"rating": "4.5", "timestamp": "1700000000123"}},
+				{tables.MovieRatings, "1_7", map[string]string{"rating": "4.5", "timestamp": "1700000000123"}},
+			},
+		},
+		{
+			name:  "没有任何时间时为0",
+			cells: []*hrpc.Cell{cell("1", "rating", "rating:7", "4.5", 0)},
+			want: []want{
+				{tables.Ratings, "7_1", map[string]string{"rating": "4.5", "timestamp": "0"}},
+				{tables.MovieRatings, "1_7", map[string]string{"rating": "4.5", "timestamp": "0"}},
+			},
+		},
+		{
+			name: "标签列名带或不带tag:前缀",
+			cells: []*hrpc.Cell{
+				cell("1", "tag", "tag:7", "pixar", 0),
+				cell("1", "tag", "timestamp:7", "1139045764", 0),
+				cell("1", "tag", "12", "fun", 1700000000456),
+			},
+			want: []want{
+				{tables.Tags, "12_1_1700000000456", map[string]string{"tag": "fun"}},
+				{tables.Tags, "7_1_1139045764", map[string]string{"tag": "pixar"}},
+			},
+		},
+		{
+			// 汇总列、不认识的列族、非数字的标签列和只有时间戳的用户都跳过
+			name: "跳过无法识别的单元格",
+			cells: []*hrpc.Cell{
+				cell("1", "rating", "rating", "3.9", 0),
+				cell("1", "rating", "timestamp", "964982703", 0),
+				cell("1", "rating", "rating:", "4.0", 0),
+				cell("1", "rating", "timestamp:9", "964982703", 0),
+				cell("1", "tag", "tag:abc", "bad", 0),
+				cell("1", "tag", "timestamp:8", "1139045764", 0),
+				cell("1", "stats", "avg", "3.9", 0),
+			},
+			wantSkipped: 7,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, skipped := splitLegacyRow(&hrpc.Result{Cells: tc.cells})
+			if skipped != tc.wantSkipped {
+				t.Errorf("跳过 %d 个单元格，期望 %d", skipped, tc.wantSkipped)
+			}
+			if len(rows) != len(tc.want) {
+				t.Fatalf("拆分出 %d 行，期望 %d 行: %+v", len(rows), len(tc.want), rows)
+			}
+			for i, w := range tc.want {
+				got := rows[i]
+				values := make(map[string]string)
+				for _, columns := range got.values {
+					for qualifier, value := range columns {
+						values[qualifier] = string(value)
+					}
+				}
+				if got.table != w.table || got.row != w.row || !reflect.DeepEqual(values, w.values) {
+					t.Errorf("第 %d 行为 %s/%s %v，期望 %s/%s %v", i, got.table, got.row, values, w.table, w.row, w.values)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "migrate.json")
+
+	// 文件不存在或未设置路径时从头开始
+	var stats MigrationStats
+	if err := loadCheckpoint(path, &stats); err != nil || stats != (MigrationStats{}) {
+		t.Fatalf("检查点不存在时返回 %+v, %v", stats, err)
+	}
+	if err := saveCheckpoint("", MigrationStats{Rows: 1}); err != nil {
+		t.Fatalf("未设置路径时返回 %v", err)
+	}
+
+	want := MigrationStats{LastRow: "42", Rows: 42, Movies: 40, Links: 39, Ratings: 1000, Tags: 12, Skipped: 3}
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatalf("保存检查点失败: %v", err)
+	}
+	want.Rows = 50
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatalf("覆盖检查点失败: %v", err)
+	}
+	var got MigrationStats
+	if err := loadCheckpoint(path, &got); err != nil || got != want {
+		t.Fatalf("读取检查点为 %+v, %v，期望 %+v", got, err, want)
+	}
+
+	// 临时文件在重命名后不应残留
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("目录中有 %d 个文件，期望只有检查点", len(entries))
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadCheckpoint(path, &got); err == nil || !strings.Contains(err.Error(), "解析检查点") {
+		t.Errorf("检查点损坏时返回 %v，期望解析错误", err)
+	}
+}
+
+func TestMigrateAndVerifyMovieData(t *testing.T) {
+	b := useMemoryBackend(t)
+	tables := Tables()
+	for _, id := range []string{"1", "2", "3"} {
+		b.add(tables.MovieData,
+			cell(id, "movie", "title", "Movie "+id, 0),
+			cell(id, "link", "imdbId", "tt"+id, 0),
+			cell(id, "rating", "rating:7", "4.0", 0),
+			cell(id, "rating", "timestamp:7", "964982703", 0),
+			cell(id, "rating", "rating", "4.0", 0),
+		)
+	}
+	b.add(tables.MovieData, cell("2", "tag", "7", "classic", 1700000000000))
+
+	checkpoint := filepath.Join(t.TempDir(), "migrate.json")
+	var progress bytes.Buffer
+	opts := MigrationOptions{BatchSize: 2, Workers: 4, Checkpoint: checkpoint, Progress: &progress}
+	ctx := context.Background()
+
+	stats, err := MigrateMovieData(ctx, opts)
+	if err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	want := MigrationStats{LastRow: "3", Rows: 3, Movies: 3, Links: 3, Ratings: 3, Tags: 1, Skipped: 3}
+	if stats != want {
+		t.Fatalf("迁移结果为 %+v，期望 %+v", stats, want)
+	}
+	if rows := b.rows(tables.MovieRatings); !reflect.DeepEqual(rows, []string{"1_7", "2_7", "3_7"}) {
+		t.Errorf("movie_ratings表的行为 %v", rows)
+	}
+	if v := b.values(tables.Tags, "7_2_1700000000000"); v["data:tag"] != "classic" {
+		t.Errorf("tags表的行为 %v", v)
+	}
+	if strings.Count(progress.String(), "已迁移") != 2 {
+		t.Errorf("按批输出的进度为 %q", progress.String())
+	}
+
+	report, err := VerifyMovieData(ctx, opts)
+	if err != nil || report.Rows != 3 || report.Checked != 13 || report.Mismatched != 0 {
+		t.Fatalf("校验结果为 %+v, %v", report, err)
+	}
+
+	// 新表中的值被修改或缺失时报告不一致
+	b.add(tables.Ratings, cell("7_1", "data", "rating", "1.0", 0))
+	b.mu.Lock()
+	delete(b.tables[tables.Links], "3")
+	b.mu.Unlock()
+	report, err = VerifyMovieData(ctx, opts)
+	if err != nil || report.Mismatched != 2 || len(report.Examples) != 2 {
+		t.Fatalf("校验结果为 %+v, %v，期望 2 行不一致", report, err)
+	}
+
+	// 从检查点继续时只处理之后新增的行，统计累加
+	b.add(tables.MovieData, cell("4", "movie", "title", "Movie 4", 0))
+	progress.Reset()
+	stats, err = MigrateMovieData(ctx, opts)
+	if err != nil {
+		t.Fatalf("继续迁移失败: %v", err)
+	}
+	want.LastRow, want.Rows, want.Movies = "4", 4, 4
+	if stats != want {
+		t.Errorf("继续迁移的结果为 %+v，期望 %+v", stats, want)
+	}
+	if !strings.Contains(progress.String(), "从检查点继续") {
+		t.Errorf("进度中没有从检查点继续: %q", progress.String())
+	}
+	if v := b.values(tables.Ratings, "7_1"); v["data:rating"] != "1.0" {
+		t.Error("从检查点继续时不应重新写入已完成的行")
+	}
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"gohbase/config"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redis中缓存值的格式：首字节为格式，其后为该前缀注册的CacheCodec编码的数据
+const (
+	redisValueEmpty   byte = 0 // 缓存的空结果，没有数据
+	redisValueEncoded byte = 1
+)
+
+// redisTagTTL 标签集合的最短保留时间，集合中已过期的键在失效时删除不会有影响
+const redisTagTTL = 24 * time.Hour
+
+// redisErrorLogInterval Redis持续不可用时错误日志的最小间隔
+const redisErrorLogInterval = 10 * time.Second
+
+// RedisCache 通过Redis协议访问的共享缓存，多个实例共用同一份缓存
+// 只保存注册了序列化方式（RegisterCacheCodec）的缓存项；Redis不可用时读取视为未命中，写入被放弃，不影响请求
+type RedisCache struct {
+	client     *respClient
+	addr       string
+	namespace  string // 所有键的前缀，多个服务共用一个Redis时区分各自的数据
+	timeout    time.Duration
+	defaultTTL time.Duration
+
+	hits       atomic.Int64
+	misses     atomic.Int64
+	errors     atomic.Int64
+	lastErrLog atomic.Int64
+}
+
+// NewRedisCache 根据配置创建Redis缓存，连接在第一次访问时建立
+func NewRedisCache(conf *config.RedisConfig, defaultTTL time.Duration) *RedisCache {
+	return &RedisCache{
+		client:     newRESPClient(conf),
+		addr:       conf.Addr,
+		namespace:  conf.KeyPrefix,
+		timeout:    conf.Timeout,
+		defaultTTL: defaultTTL,
+	}
+}
+
+// dataKey 缓存项在Redis中的键
+func (r *RedisCache) dataKey(key string) string {
+	return r.namespace + "k:" + key
+}
+
+// tagKey 标签对应的键集合在Redis中的键
+func (r *RedisCache) tagKey(tag string) string {
+	return r.namespace + "t:" + tag
+}
+
+// context 单次访问Redis使用的context
+func (r *RedisCache) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), r.timeout)
+}
+
+// Get 读取缓存项，空结果返回nil和true，没有注册序列化方式的键总是未命中
+func (r *RedisCache) Get(key string) (interface{}, bool) {
+	codec, ok := codecFor(key)
+	if !ok {
+		return nil, false
+	}
+
+	ctx, cancel := r.context()
+	defer cancel()
+	reply, err := r.client.do(ctx, "GET", r.dataKey(key))
+	if err != nil {
+		r.recordError("GET", err)
+		return nil, false
+	}
+	if reply.null || len(reply.str) == 0 {
+		r.misses.Add(1)
+		cacheSharedRequests.WithLabelValues(keyPrefix(key), "miss").Inc()
+		return nil, false
+	}
+
+	var value interface{}
+	switch reply.str[0] {
+	case redisValueEmpty:
+	case redisValueEncoded:
+		if value, err = codec.Decode(reply.str[1:]); err != nil {
+			// 通常是部署了新版本后数据结构发生了变化，当作未命中，重新加载后覆盖
+			r.recordError("decode", err)
+			return nil, false
+		}
+	default:
+		r.misses.Add(1)
+		cacheSharedRequests.WithLabelValues(keyPrefix(key), "miss").Inc()
+		return nil, false
+	}
+	r.hits.Add(1)
+	cacheSharedRequests.WithLabelValues(keyPrefix(key), "hit").Inc()
+	return value, true
+}
+
+// SetWithTags 写入缓存项并登记依赖标签，value为nil表示空结果；duration为0时使用默认过期时间，小于0时不写入
+func (r *RedisCache) SetWithTags(key string, value interface{}, duration time.Duration, tags ...string) {
+	if duration == 0 {
+		duration = r.defaultTTL
+	}
+	if duration <= 0 {
+		return
+	}
+	codec, ok := codecFor(key)
+	if !ok {
+		return
+	}
+
+	var data []byte
+	if _, empty := value.(emptyResult); empty || value == nil {
+		data = []byte{redisValueEmpty}
+	} else {
+		encoded, err := codec.Encode(value)
+		if err != nil {
+			r.recordError("encode", err)
+			return
+		}
+		data = append([]byte{redisValueEncoded}, encoded...)
+	}
+
+	dataKey := r.dataKey(key)
+	cmds := [][][]byte{respArgs("SET", dataKey, data, "PX", duration.Milliseconds())}
+	tagTTL := max(duration, redisTagTTL).Milliseconds()
+	for _, tag := range tags {
+		cmds = append(cmds,
+			respArgs("SADD", r.tagKey(tag), dataKey),
+			respArgs("PEXPIRE", r.tagKey(tag), tagTTL))
+	}
+
+	ctx, cancel := r.context()
+	defer cancel()
+	replies, err := r.client.pipeline(ctx, cmds)
+	if err == nil {
+		for _, reply := range replies {
+			if err = reply.err(); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		r.recordError("SET", err)
+	}
+}
+
+// Delete 删除缓存项，返回缓存项是否存在
+func (r *RedisCache) Delete(key string) bool {
+	if _, ok := codecFor(key); !ok {
+		// 没有注册序列化方式的键不会写入Redis
+		return false
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	reply, err := r.client.do(ctx, "DEL", r.dataKey(key))
+	if err != nil {
+		r.recordError("DEL", err)
+		return false
+	}
+	return reply.num > 0
+}
+
+// InvalidateTags 删除依赖任一标签的缓存项，返回删除的数量
+func (r *RedisCache) InvalidateTags(tags ...string) int {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	removed := 0
+	for _, tag := range tags {
+		tagKey := r.tagKey(tag)
+		reply, err := r.client.do(ctx, "SMEMBERS", tagKey)
+		if err != nil {
+			r.recordError("SMEMBERS", err)
+			continue
+		}
+		args := []interface{}{"DEL", tagKey}
+		for _, member := range reply.array {
+			args = append(args, member.str)
+		}
+		deleted, err := r.client.do(ctx, args...)
+		if err != nil {
+			r.recordError("DEL", err)
+			continue
+		}
+		// DEL的结果包含标签集合本身
+		if deleted.num > 0 && len(reply.array) > 0 {
+			removed += int(deleted.num) - 1
+		}
+	}
+	return removed
+}
+
+// InvalidatePrefix 删除键以prefix开头的缓存项，返回删除的数量
+// 使用SCAN逐批查找，不会长时间阻塞Redis
+func (r *RedisCache) InvalidatePrefix(prefix string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*r.timeout)
+	defer cancel()
+
+	pattern := r.dataKey(escapeGlob(prefix)) + "*"
+	removed := 0
+	cursor := "0"
+	for {
+		reply, err := r.client.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", 500)
+		if err != nil {
+			r.recordError("SCAN", err)
+			return removed
+		}
+		if len(reply.array) != 2 {
+			r.recordError("SCAN", errRESPProtocol)
+			return removed
+		}
+		cursor = string(reply.array[0].str)
+		if keys := reply.array[1].array; len(keys) > 0 {
+			args := []interface{}{"DEL"}
+			for _, key := range keys {
+				args = append(args, key.str)
+			}
+			deleted, err := r.client.do(ctx, args...)
+			if err != nil {
+				r.recordError("DEL", err)
+				return removed
+			}
+			removed += int(deleted.num)
+		}
+		if cursor == "0" {
+			return removed
+		}
+	}
+}
+
+// Ping 检查Redis是否可用
+func (r *RedisCache) Ping(ctx context.Context) error {
+	_, err := r.client.do(ctx, "PING")
+	return err
+}
+
+// Close 关闭连接池
+func (r *RedisCache) Close(ctx context.Context) (int, error) {
+	r.client.Close()
+	return 0, nil
+}
+
+// Stats 共享缓存的访问统计
+func (r *RedisCache) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"backend": "redis",
+		"addr":    r.addr,
+		"hits":    r.hits.Load(),
+		"misses":  r.misses.Load(),
+		"errors":  r.errors.Load(),
+	}
+}
+
+// recordError 记录访问Redis失败，Redis持续不可用时限制日志频率
+func (r *RedisCache) recordError(op string, err error) {
+	r.errors.Add(1)
+	cacheSharedErrors.WithLabelValues(op).Inc()
+
+	now := time.Now().UnixNano()
+	last := r.lastErrLog.Load()
+	if now-last < int64(redisErrorLogInterval) || !r.lastErrLog.CompareAndSwap(last, now) {
+		return
+	}
+	var replyErr *respReplyError
+	if errors.As(err, &replyErr) {
+		logrus.Errorf("共享缓存 %s 失败: %v", op, err)
+		return
+	}
+	logrus.Warnf("共享缓存 %s 失败，本次按未命中处理: %v", op, err)
+}
+
+// escapeGlob 转义Redis匹配模式中的特殊字符
+func escapeGlob(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gohbase/config"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tsuna/gohbase/hrpc"
+	"google.golang.org/protobuf/proto"
+)
+
+const testRedisPassword = "secret"
+
+// newTestRedisCache 启动替身服务并创建连接到它的RedisCache
+func newTestRedisCache(t *testing.T, keyPrefix string) (*RedisCache, *respStandIn) {
+	t.Helper()
+	standIn, err := newRESPStandIn(testRedisPassword)
+	if err != nil {
+		t.Fatalf("启动替身服务失败: %v", err)
+	}
+	t.Cleanup(func() { standIn.Close() })
+	return connectTestRedisCache(t, standIn, keyPrefix, testRedisPassword), standIn
+}
+
+// connectTestRedisCache 创建连接到已有替身服务的RedisCache
+func connectTestRedisCache(t *testing.T, standIn *respStandIn, keyPrefix, password string) *RedisCache {
+	cache := NewRedisCache(&config.RedisConfig{
+		Addr:      standIn.Addr(),
+		Password:  password,
+		KeyPrefix: keyPrefix,
+		PoolSize:  2,
+		Timeout:   time.Second,
+	}, time.Minute)
+	t.Cleanup(func() { cache.Close(context.Background()) })
+	return cache
+}
+
+// rawValue 读取替身服务中保存的原始值
+func (s *respStandIn) rawValue(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.lookup(key)
+	if v == nil {
+		return nil, false
+	}
+	return v.str, true
+}
+
+// testResults 带时间戳和不带时间戳的单元格各一个的扫描结果
+func testResults() []*hrpc.Result {
+	return []*hrpc.Result{
+		{Cells: []*hrpc.Cell{
+			{Row: []byte("1"), Family: []byte("info"), Qualifier: []byte("title"), Value: []byte("Toy Story"), Timestamp: proto.Uint64(1700000000000)},
+			{Row: []byte("1"), Family: []byte("info"), Qualifier: []byte("genres"), Value: []byte{0, 0xff, '|'}},
+		}},
+		{Cells: []*hrpc.Cell{
+			{Row: []byte("2"), Family: []byte("info"), Qualifier: []byte("title"), Value: []byte{}},
+		}},
+	}
+}
+
+// equalResults 比较扫描结果的行键、列、值和时间戳
+func equalResults(a, b []*hrpc.Result) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i].Cells) != len(b[i].Cells) {
+			return false
+		}
+		for j, x := range a[i].Cells {
+			y := b[i].Cells[j]
+			if !bytes.Equal(x.Row, y.Row) || !bytes.Equal(x.Family, y.Family) ||
+				!bytes.Equal(x.Qualifier, y.Qualifier) || !bytes.Equal(x.Value, y.Value) {
+				return false
+			}
+			if (x.Timestamp == nil) != (y.Timestamp == nil) || (x.Timestamp != nil && *x.Timestamp != *y.Timestamp) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestResultsCodecRoundTrip(t *testing.T) {
+	want := testResults()
+	data, err := ResultsCodec.Encode(want)
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	got, err := ResultsCodec.Decode(data)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if !equalResults(got.([]*hrpc.Result), want) {
+		t.Errorf("解码结果与原值不同: %v", got)
+	}
+
+	// nil结果解码为没有单元格的结果
+	data, _ = ResultsCodec.Encode([]*hrpc.Result{nil})
+	got, err = ResultsCodec.Decode(data)
+	if results := got.([]*hrpc.Result); err != nil || len(results) != 1 || len(results[0].Cells) != 0 {
+		t.Errorf("nil结果解码为 %v, %v", got, err)
+	}
+
+	if _, err := ResultsCodec.Encode("not results"); err == nil {
+		t.Error("类型不符时应返回错误")
+	}
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	cases := []struct {
+		codec CacheCodec
+		value interface{}
+	}{
+		{JSONCodec[[]string](), []string{"1", "2", "3"}},
+		{JSONCodec[[]string](), []string{}},
+		{JSONCodec[map[string]float64](), map[string]float64{"avg": 3.5, "count": 10}},
+		{JSONCodec[[]map[string]interface{}](), []map[string]interface{}{{"userId": "7", "tag": "funny"}}},
+	}
+	for _, tc := range cases {
+		data, err := tc.codec.Encode(tc.value)
+		if err != nil {
+			t.Fatalf("编码 %v 失败: %v", tc.value, err)
+		}
+		got, err := tc.codec.Decode(data)
+		if err != nil {
+			t.Fatalf("解码 %s 失败: %v", data, err)
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(tc.value) || !reflect.DeepEqual(got, tc.value) {
+			t.Errorf("解码结果为 %#v，期望 %#v", got, tc.value)
+		}
+	}
+
+	if _, err := JSONCodec[[]string]().Encode(map[string]float64{}); err == nil {
+		t.Error("类型不符时应返回错误")
+	}
+}
+
+func TestRedisCacheGetSet(t *testing.T) {
+	cache, standIn := newTestRedisCache(t, "test:")
+
+	if _, ok := cache.Get("movies_by_rating:4-5"); ok {
+		t.Fatal("空缓存不应命中")
+	}
+
+	ids := []string{"1", "2", "3"}
+	cache.SetWithTags("movies_by_rating:4-5", ids, 0, "table:movies")
+	got, ok := cache.Get("movies_by_rating:4-5")
+	if !ok || !reflect.DeepEqual(got, ids) {
+		t.Errorf("读取结果为 %#v, %v", got, ok)
+	}
+
+	results := testResults()
+	cache.SetWithTags("scan_movies:0-10", results, time.Hour)
+	got, ok = cache.Get("scan_movies:0-10")
+	if !ok || !equalResults(got.([]*hrpc.Result), results) {
+		t.Errorf("扫描结果读取为 %v, %v", got, ok)
+	}
+
+	// 值带格式前缀，键带配置的前缀
+	raw, ok := standIn.rawValue("test:k:movies_by_rating:4-5")
+	if !ok || raw[0] != redisValueEncoded || string(raw[1:]) != `["1","2","3"]` {
+		t.Errorf("Redis中的值为 %q", raw)
+	}
+
+	// 没有注册序列化方式的键不写入Redis
+	before := standIn.Len()
+	cache.SetWithTags("unregistered:1", "value", 0)
+	if _, ok := cache.Get("unregistered:1"); ok {
+		t.Error("未注册序列化方式的键不应命中")
+	}
+	if standIn.Len() != before {
+		t.Error("未注册序列化方式的键不应写入Redis")
+	}
+
+	// 过期时间小于0时不写入
+	cache.SetWithTags("movies_by_rating:0-1", ids, -1)
+	if _, ok := cache.Get("movies_by_rating:0-1"); ok {
+		t.Error("过期时间小于0时不应写入")
+	}
+
+	stats := cache.Stats()
+	if stats["hits"].(int64) != 2 || stats["errors"].(int64) != 0 {
+		t.Errorf("统计不正确: %v", stats)
+	}
+}
+
+func TestRedisCacheEmptyMarker(t *testing.T) {
+	cache, standIn := newTestRedisCache(t, "test:")
+
+	for _, value := range []interface{}{nil, emptyResult{}} {
+		key := fmt.Sprintf("movies_by_rating:%T", value)
+		cache.SetWithTags(key, value, 0)
+		raw, ok := standIn.rawValue("test:k:" + key)
+		if !ok || !bytes.Equal(raw, []byte{redisValueEmpty}) {
+			t.Errorf("空结果在Redis中保存为 %q", raw)
+		}
+		got, ok := cache.Get(key)
+		if !ok || got != nil {
+			t.Errorf("空结果读取为 %#v, %v，期望 nil, true", got, ok)
+		}
+	}
+
+	// 无法识别的格式按未命中处理
+	cache.client.do(context.Background(), "SET", "test:k:movies_by_rating:bad", []byte{9, 'x'})
+	if _, ok := cache.Get("movies_by_rating:bad"); ok {
+		t.Error("未知格式的值不应命中")
+	}
+}
+
+func TestRedisCacheInvalidateTags(t *testing.T) {
+	cache, standIn := newTestRedisCache(t, "test:")
+
+	ids := []string{"1"}
+	cache.SetWithTags("movies_by_rating:a", ids, 0, "movie:1")
+	cache.SetWithTags("movies_by_rating:b", ids, 0, "movie:1", "table:movies")
+	cache.SetWithTags("movies_by_rating:c", ids, 0, "movie:2")
+
+	if n := cache.InvalidateTags("movie:1"); n != 2 {
+		t.Errorf("删除了 %d 项，期望2项", n)
+	}
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true} {
+		if _, ok := cache.Get("movies_by_rating:" + key); ok != want {
+			t.Errorf("失效后 %s 的命中为 %v，期望 %v", key, ok, want)
+		}
+	}
+	if _, ok := standIn.rawValue("test:t:movie:1"); ok {
+		t.Error("标签集合应一并删除")
+	}
+
+	// b已删除，table:movies中只剩已不存在的键
+	if n := cache.InvalidateTags("table:movies", "movie:404"); n != 0 {
+		t.Errorf("删除了 %d 项，期望0项", n)
+	}
+	if n := cache.InvalidateTags("movie:2"); n != 1 {
+		t.Errorf("删除了 %d 项，期望1项", n)
+	}
+	if standIn.Len() != 0 {
+		t.Errorf("失效后仍有 %d 个键", standIn.Len())
+	}
+}
+
+func TestRedisCacheInvalidatePrefix(t *testing.T) {
+	cache, standIn := newTestRedisCache(t, "test:")
+	other := connectTestRedisCache(t, standIn, "other:", testRedisPassword)
+
+	ids := []string{"1"}
+	keys := []string{
+		"movies_by_rating:a*1", "movies_by_rating:ab",
+		"movies_by_rating:?x", "movies_by_rating:zx",
+		`movies_by_rating:c\d`, "movies_by_rating:cd",
+		"movies_by_rating:[1]", "movies_by_rating:1",
+	}
+	for _, key := range keys {
+		cache.SetWithTags(key, ids, 0)
+	}
+	other.SetWithTags("movies_by_rating:a*1", ids, 0)
+
+	// 前缀中的匹配模式字符按原样匹配
+	cases := []struct {
+		prefix string
+		want   int
+		gone   string
+		kept   string
+	}{
+		{"movies_by_rating:a*", 1, "movies_by_rating:a*1", "movies_by_rating:ab"},
+		{"movies_by_rating:?", 1, "movies_by_rating:?x", "movies_by_rating:zx"},
+		{`movies_by_rating:c\`, 1, `movies_by_rating:c\d`, "movies_by_rating:cd"},
+		{"movies_by_rating:[", 1, "movies_by_rating:[1]", "movies_by_rating:1"},
+	}
+	for _, tc := range cases {
+		if n := cache.InvalidatePrefix(tc.prefix); n != tc.want {
+			t.Errorf("前缀 %q 删除了 %d 项，期望 %d 项", tc.prefix, n, tc.want)
+		}
+		if _, ok := cache.Get(tc.gone); ok {
+			t.Errorf("%s 应已删除", tc.gone)
+		}
+		if _, ok := cache.Get(tc.kept); !ok {
+			t.Errorf("%s 不应删除", tc.kept)
+		}
+	}
+	if _, ok := other.Get("movies_by_rating:a*1"); !ok {
+		t.Error("其他键前缀的数据不应删除")
+	}
+
+	// 超过一批SCAN的数量时按游标继续查找
+	for i := 0; i < 1200; i++ {
+		cache.SetWithTags(fmt.Sprintf("scan_movies:%04d", i), []*hrpc.Result{}, 0)
+	}
+	if n := cache.InvalidatePrefix("scan_movies:"); n != 1200 {
+		t.Errorf("删除了 %d 项，期望1200项", n)
+	}
+	if n := cache.InvalidatePrefix("movies_by_rating:"); n != 4 {
+		t.Errorf("删除了 %d 项，期望4项", n)
+	}
+}
+
+func TestRedisCacheUnavailable(t *testing.T) {
+	_, standIn := newTestRedisCache(t, "test:")
+	cache := connectTestRedisCache(t, standIn, "test:", "wrong")
+
+	cache.SetWithTags("movies_by_rating:a", []string{"1"}, 0)
+	if _, ok := cache.Get("movies_by_rating:a"); ok {
+		t.Error("认证失败时应按未命中处理")
+	}
+	if standIn.Len() != 0 {
+		t.Error("认证失败时不应写入")
+	}
+	if cache.Stats()["errors"].(int64) == 0 {
+		t.Error("应记录访问错误")
+	}
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态时返回的错误
+var ErrCircuitOpen = errors.New("HBase熔断器已打开，请求被拒绝")
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// CircuitBreaker 单个表的熔断器
+// 连续失败达到阈值后打开，打开超过OpenTimeout后进入半开状态，只放行一个探测请求
+type CircuitBreaker struct {
+	table    string
+	policy   config.BreakerConfig
+	mu       sync.Mutex
+	state    string
+	failures int       // 连续失败次数
+	openedAt time.Time // 最近一次打开的时间
+	probing  bool      // 半开状态下是否已有探测请求在进行
+	rejected int64     // 被拒绝的请求数
+}
+
+// newCircuitBreaker 创建熔断器
+func newCircuitBreaker(table string, policy config.BreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{
+		table:  table,
+		policy: policy,
+		state:  BreakerClosed,
+	}
+	hbaseBreakerState.WithLabelValues(table).Set(0)
+	return b
+}
+
+// Allow 判断是否放行请求
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 阈值小于等于0表示不启用熔断
+	if b.policy.FailureThreshold <= 0 {
+		return nil
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			b.rejected++
+			hbaseBreakerRejections.WithLabelValues(b.table).Inc()
+			return fmt.Errorf("表 %s: %w", b.table, ErrCircuitOpen)
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			hbaseBreakerRejections.WithLabelValues(b.table).Inc()
+			return fmt.Errorf("表 %s: %w", b.table, ErrCircuitOpen)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record 记录请求结果，请求被取消或超时不计入失败
+func (b *CircuitBreaker) Record(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		b.Release()
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			logrus.Infof("表 %s 的熔断器已恢复", b.table)
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.policy.FailureThreshold) {
+		logrus.Warnf("表 %s 连续失败 %d 次，熔断器打开: %v", b.table, b.failures, err)
+		b.openedAt = time.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// Release 释放半开状态下的探测名额，不计入成功或失败
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// setState 切换状态并更新指标，调用方需持有锁
+func (b *CircuitBreaker) setState(state string) {
+	b.state = state
+	value := 0.0
+	switch state {
+	case BreakerHalfOpen:
+		value = 1
+	case BreakerOpen:
+		value = 2
+	}
+	hbaseBreakerState.WithLabelValues(b.table).Set(value)
+}
+
+// Snapshot 获取熔断器当前状态
+func (b *CircuitBreaker) Snapshot() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := map[string]interface{}{
+		"state":               b.state,
+		"consecutiveFailures": b.failures,
+		"rejected":            b.rejected,
+	}
+	if b.state != BreakerClosed {
+		snapshot["openedAt"] = b.openedAt.Format(time.RFC3339)
+	}
+	return snapshot
+}
+
+// resilience 全局重试与熔断配置
+var resilience = struct {
+	mu       sync.RWMutex
+	policies map[string]config.RetryConfig
+	breaker  config.BreakerConfig
+	breakers map[string]*CircuitBreaker
+}{
+	policies: map[string]config.RetryConfig{},
+	breaker:  config.BreakerConfig{FailureThreshold: 5, OpenTimeout: 30 * time.Second},
+	breakers: map[string]*CircuitBreaker{},
+}
+
+// InitResilience 初始化HBase调用的重试与熔断策略
+func InitResilience(conf *config.ResilienceConfig) {
+	resilience.mu.Lock()
+	defer resilience.mu.Unlock()
+
+	resilience.policies = map[string]config.RetryConfig{
+		"Get":  conf.Get,
+		"Scan": conf.Scan,
+		"Put":  conf.Put,
+	}
+	resilience.breaker = conf.Breaker
+	for _, b := range resilience.breakers {
+		b.mu.Lock()
+		b.policy = conf.Breaker
+		b.mu.Unlock()
+	}
+}
+
+// retryPolicy 获取操作类型对应的重试策略
+func retryPolicy(op string) config.RetryConfig {
+	resilience.mu.RLock()
+	defer resilience.mu.RUnlock()
+	return resilience.policies[op]
+}
+
+// breakerFor 获取表对应的熔断器，不存在时创建
+func breakerFor(table string) *CircuitBreaker {
+	resilience.mu.RLock()
+	b, ok := resilience.breakers[table]
+	resilience.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	resilience.mu.Lock()
+	defer resilience.mu.Unlock()
+	if b, ok := resilience.breakers[table]; ok {
+		return b
+	}
+	b = newCircuitBreaker(table, resilience.breaker)
+	resilience.breakers[table] = b
+	return b
+}
+
+// BreakerStates 获取所有表的熔断器状态
+func BreakerStates() map[string]interface{} {
+	resilience.mu.RLock()
+	defer resilience.mu.RUnlock()
+
+	states := make(map[string]interface{}, len(resilience.breakers))
+	for table, b := range resilience.breakers {
+		states[table] = b.Snapshot()
+	}
+	return states
+}
+
+// RetryPolicies 获取各操作类型的重试策略，用于状态展示
+func RetryPolicies() map[string]interface{} {
+	resilience.mu.RLock()
+	defer resilience.mu.RUnlock()
+
+	policies := make(map[string]interface{}, len(resilience.policies))
+	for op, p := range resilience.policies {
+		policies[op] = map[string]interface{}{
+			"maxRetries":     p.MaxRetries,
+			"initialBackoff": p.InitialBackoff.String(),
+			"maxBackoff":     p.MaxBackoff.String(),
+			"multiplier":     p.Multiplier,
+		}
+	}
+	return policies
+}
+
+// withRetry 在熔断器保护下执行操作，失败时按策略进行指数退避重试
+func withRetry(ctx context.Context, op, table string, fn func() error) error {
+	policy := retryPolicy(op)
+	breaker := breakerFor(table)
+	backoff := policy.InitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return err
+		}
+
+		err := fn()
+		breaker.Record(err)
+		if err == nil || attempt >= policy.MaxRetries || !isRetryable(ctx, err) {
+			return err
+		}
+
+		hbaseRetries.WithLabelValues(table, op).Inc()
+		logrus.Debugf("HBase %s %s 失败，%v 后第 %d 次重试: %v", op, table, backoff, attempt+1, err)
+
+		if err := sleepWithContext(ctx, jitter(backoff)); err != nil {
+			return err
+		}
+		backoff = nextBackoff(backoff, policy)
+	}
+}
+
+// isRetryable 判断错误是否值得重试：请求已取消、超时、熔断打开或处于降级模式时不重试
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrCircuitOpen) &&
+		!errors.Is(err, ErrHBaseUnavailable) &&
+		!errors.Is(err, errThriftRejected) &&
+		!errors.Is(err, errThriftUnsupported)
+}
+
+// nextBackoff 计算下一次重试的等待时间
+func nextBackoff(current time.Duration, policy config.RetryConfig) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	next := time.Duration(float64(current) * multiplier)
+	if policy.MaxBackoff > 0 && next > policy.MaxBackoff {
+		next = policy.MaxBackoff
+	}
+	return next
+}
+
+// jitter 在等待时间上增加随机抖动，避免大量请求同时重试
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// sleepWithContext 等待指定时间，期间请求被取消则提前返回
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"gohbase/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errHBase = errors.New("RegionServer不可用")
+
+// breakerState 返回熔断器当前状态
+func breakerState(b *CircuitBreaker) string {
+	return b.Snapshot()["state"].(string)
+}
+
+// expireOpen 使打开状态已持续超过OpenTimeout，下一个请求进入半开状态
+func expireOpen(b *CircuitBreaker) {
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-b.policy.OpenTimeout - time.Second)
+	b.mu.Unlock()
+}
+
+// openBreaker 创建熔断器并使其打开
+func openBreaker(t *testing.T) *CircuitBreaker {
+	t.Helper()
+	b := newCircuitBreaker("test", config.BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("第 %d 个请求被拒绝: %v", i+1, err)
+		}
+		b.Record(errHBase)
+	}
+	if state := breakerState(b); state != BreakerOpen {
+		t.Fatalf("连续失败3次后状态为 %s，期望 %s", state, BreakerOpen)
+	}
+	return b
+}
+
+func TestCircuitBreakerOpensAtThreshold(t *testing.T) {
+	b := newCircuitBreaker("test", config.BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	// 成功的请求清零连续失败次数
+	b.Record(errHBase)
+	b.Record(errHBase)
+	b.Record(nil)
+	b.Record(errHBase)
+	b.Record(errHBase)
+	if state := breakerState(b); state != BreakerClosed {
+		t.Fatalf("未连续失败3次时状态为 %s，期望 %s", state, BreakerClosed)
+	}
+
+	// 请求取消或超时不计入失败
+	b.Record(context.Canceled)
+	b.Record(context.DeadlineExceeded)
+	if state := breakerState(b); state != BreakerClosed {
+		t.Fatalf("请求取消后状态为 %s，期望 %s", state, BreakerClosed)
+	}
+
+	b.Record(errHBase)
+	if state := breakerState(b); state != BreakerOpen {
+		t.Fatalf("连续失败3次后状态为 %s，期望 %s", state, BreakerOpen)
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("打开状态下返回 %v，期望 ErrCircuitOpen", err)
+		}
+	}
+	if rejected := b.Snapshot()["rejected"]; rejected != int64(2) {
+		t.Errorf("拒绝数为 %v，期望 2", rejected)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	cases := []struct {
+		name      string
+		finish    func(b *CircuitBreaker)
+		wantState string
+		wantAllow bool // 探测结束后下一个请求是否放行
+	}{
+		{name: "探测成功后关闭", finish: func(b *CircuitBreaker) { b.Record(nil) }, wantState: BreakerClosed, wantAllow: true},
+		{name: "探测失败后重新打开", finish: func(b *CircuitBreaker) { b.Record(errHBase) }, wantState: BreakerOpen},
+		{name: "探测被取消时释放名额", finish: func(b *CircuitBreaker) { b.Record(context.Canceled) }, wantState: BreakerHalfOpen, wantAllow: true},
+		{name: "探测未访问HBase时释放名额", finish: func(b *CircuitBreaker) { b.Release() }, wantState: BreakerHalfOpen, wantAllow: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := openBreaker(t)
+			if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("未到OpenTimeout时返回 %v，期望 ErrCircuitOpen", err)
+			}
+
+			expireOpen(b)
+			if err := b.Allow(); err != nil {
+				t.Fatalf("超过OpenTimeout后探测请求被拒绝: %v", err)
+			}
+			if state := breakerState(b); state != BreakerHalfOpen {
+				t.Fatalf("探测期间状态为 %s，期望 %s", state, BreakerHalfOpen)
+			}
+			// 探测进行中只放行一个请求
+			if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("探测进行中返回 %v，期望 ErrCircuitOpen", err)
+			}
+
+			tc.finish(b)
+			if state := breakerState(b); state != tc.wantState {
+				t.Fatalf("探测结束后状态为 %s，期望 %s", state, tc.wantState)
+			}
+			if err := b.Allow(); (err == nil) != tc.wantAllow {
+				t.Errorf("探测结束后下一个请求返回 %v，期望放行 %v", err, tc.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerConcurrentProbe(t *testing.T) {
+	b := openBreaker(t)
+	expireOpen(b)
+
+	const n = 50
+	var allowed atomic.Int32
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if b.Allow() == nil {
+				allowed.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := allowed.Load(); got != 1 {
+		t.Fatalf("半开状态下放行了 %d 个请求，期望 1 个", got)
+	}
+	if rejected := b.Snapshot()["rejected"]; rejected != int64(n-1) {
+		t.Errorf("拒绝数为 %v，期望 %d", rejected, n-1)
+	}
+
+	b.Record(nil)
+	allowed.Store(0)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() == nil {
+				allowed.Add(1)
+			}
+			b.Record(nil)
+		}()
+	}
+	wg.Wait()
+	if got := allowed.Load(); got != n {
+		t.Errorf("关闭后放行了 %d 个请求，期望 %d 个", got, n)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker("test", config.BreakerConfig{FailureThreshold: 0, OpenTimeout: time.Minute})
+	for i := 0; i < 10; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("阈值为0时请求被拒绝: %v", err)
+		}
+		b.Record(errHBase)
+	}
+	if state := breakerState(b); state != BreakerClosed {
+		t.Errorf("阈值为0时状态为 %s，期望 %s", state, BreakerClosed)
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// respClient Redis协议客户端，维护到Redis（或兼容Redis协议的服务）的连接池
+// 每条连接同一时间只承载一个调用，发生传输或协议错误的连接直接丢弃
+type respClient struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	poolSize int
+
+	mu     sync.Mutex
+	idle   []*respConn
+	closed bool
+}
+
+// respConn 一条Redis连接
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// newRESPClient 根据配置创建Redis客户端，连接在第一次调用时建立
+func newRESPClient(conf *config.RedisConfig) *respClient {
+	return &respClient{
+		addr:     conf.Addr,
+		password: conf.Password,
+		db:       conf.DB,
+		timeout:  conf.Timeout,
+		poolSize: conf.PoolSize,
+	}
+}
+
+// acquire 从连接池取出一条空闲连接，没有空闲连接时新建，新连接先完成认证和选择数据库
+func (c *respClient) acquire(ctx context.Context) (*respConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("Redis客户端已关闭")
+	}
+	if n := len(c.idle); n > 0 {
+		rc := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return rc, nil
+	}
+	c.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("连接Redis %s 失败: %w", c.addr, err)
+	}
+	rc := &respConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+
+	var setup [][][]byte
+	if c.password != "" {
+		setup = append(setup, respArgs("AUTH", c.password))
+	}
+	if c.db != 0 {
+		setup = append(setup, respArgs("SELECT", c.db))
+	}
+	if len(setup) > 0 {
+		replies, err := c.roundTrip(ctx, rc, setup)
+		if err == nil {
+			for _, reply := range replies {
+				if err = reply.err(); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("初始化Redis连接失败: %w", err)
+		}
+	}
+	return rc, nil
+}
+
+// release 归还连接，连接已损坏、客户端已关闭或连接池已满时关闭连接
+func (c *respClient) release(rc *respConn, broken bool) {
+	c.mu.Lock()
+	if !broken && !c.closed && len(c.idle) < c.poolSize {
+		c.idle = append(c.idle, rc)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	rc.conn.Close()
+}
+
+// Close 关闭所有空闲连接，正在使用的连接在归还时关闭
+func (c *respClient) Close() {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.closed = true
+	c.mu.Unlock()
+
+	for _, rc := range idle {
+		rc.conn.Close()
+	}
+}
+
+// do 执行一条命令，服务端的错误回复以*respReplyError返回
+func (c *respClient) do(ctx context.Context, args ...interface{}) (respValue, error) {
+	replies, err := c.pipeline(ctx, [][][]byte{respArgs(args...)})
+	if err != nil {
+		return respValue{}, err
+	}
+	return replies[0], replies[0].err()
+}
+
+// pipeline 一次发送多条命令并按顺序读取回复，单条命令的错误回复保留在对应的回复中
+func (c *respClient) pipeline(ctx context.Context, cmds [][][]byte) ([]respValue, error) {
+	rc, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := c.roundTrip(ctx, rc, cmds)
+	// 错误回复不影响连接状态，传输或协议错误说明连接上可能残留未读完的数据
+	c.release(rc, err != nil)
+	return replies, err
+}
+
+// roundTrip 在指定连接上发送命令并读取回复，ctx取消时立即中断阻塞的读写
+func (c *respClient) roundTrip(ctx context.Context, rc *respConn, cmds [][][]byte) ([]respValue, error) {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	rc.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		rc.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	transportErr := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("Redis调用 %s 失败: %w", cmds[0][0], err)
+	}
+
+	for _, args := range cmds {
+		writeRESPCommand(rc.writer, args)
+	}
+	if err := rc.writer.Flush(); err != nil {
+		return nil, transportErr(err)
+	}
+
+	replies := make([]respValue, 0, len(cmds))
+	for range cmds {
+		reply, err := readRESPValue(rc.reader)
+		if err != nil {
+			return nil, transportErr(err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// respArgs 把命令参数转换为批量字符串，支持string、[]byte和整数
+func respArgs(args ...interface{}) [][]byte {
+	out := make([][]byte, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case []byte:
+			out[i] = v
+		case string:
+			out[i] = []byte(v)
+		case int:
+			out[i] = []byte(strconv.Itoa(v))
+		case int64:
+			out[i] = []byte(strconv.FormatInt(v, 10))
+		default:
+			out[i] = []byte(fmt.Sprint(v))
+		}
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RESP（Redis序列化协议）的类型前缀
+const (
+	respSimpleString byte = '+'
+	respError        byte = '-'
+	respInteger      byte = ':'
+	respBulkString   byte = '$'
+	respArray        byte = '*'
+)
+
+// respMaxLength 单个字符串或数组允许的最大长度，避免错误的数据导致分配过大的内存
+const respMaxLength = 512 << 20
+
+// errRESPProtocol 数据不符合RESP格式，连接上的后续数据不可信
+var errRESPProtocol = errors.New("RESP协议错误")
+
+// respReplyError 服务端返回的错误回复，如 ERR unknown command，不影响连接状态
+type respReplyError struct {
+	Message string
+}
+
+func (e *respReplyError) Error() string {
+	return "Redis返回错误: " + e.Message
+}
+
+// respValue 一个RESP回复
+type respValue struct {
+	kind  byte
+	str   []byte // 简单字符串或批量字符串的内容
+	num   int64
+	array []respValue
+	null  bool // 空批量字符串（$-1）或空数组（*-1）
+}
+
+// writeRESPCommand 以批量字符串数组的形式写出一条命令
+func writeRESPCommand(w *bufio.Writer, args [][]byte) {
+	w.WriteByte(respArray)
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+	for _, arg := range args {
+		writeRESPBulk(w, arg)
+	}
+}
+
+// writeRESPBulk 写出一个批量字符串，nil写为空批量字符串
+func writeRESPBulk(w *bufio.Writer, b []byte) {
+	w.WriteByte(respBulkString)
+	if b == nil {
+		w.WriteString("-1\r\n")
+		return
+	}
+	w.WriteString(strconv.Itoa(len(b)))
+	w.WriteString("\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// readRESPValue 读取一个回复，错误回复以respValue返回而不是error
+func readRESPValue(r *bufio.Reader) (respValue, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return respValue{}, err
+	}
+	line, err := readRESPLine(r)
+	if err != nil {
+		return respValue{}, err
+	}
+
+	switch kind {
+	case respSimpleString, respError:
+		return respValue{kind: kind, str: line}, nil
+	case respInteger:
+		n, err := strconv.ParseInt(string(line), 10, 64)
+		if err != nil {
+			return respValue{}, fmt.Errorf("%w: 无效的整数 %q", errRESPProtocol, line)
+		}
+		return respValue{kind: kind, num: n}, nil
+	case respBulkString:
+		n, err := respLength(line)
+		if err != nil {
+			return respValue{}, err
+		}
+		if n < 0 {
+			return respValue{kind: kind, null: true}, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return respValue{}, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return respValue{}, fmt.Errorf("%w: 批量字符串没有以CRLF结尾", errRESPProtocol)
+		}
+		return respValue{kind: kind, str: buf[:n]}, nil
+	case respArray:
+		n, err := respLength(line)
+		if err != nil {
+			return respValue{}, err
+		}
+		if n < 0 {
+			return respValue{kind: kind, null: true}, nil
+		}
+		array := make([]respValue, 0, min(n, 1024))
+		for i := 0; i < n; i++ {
+			item, err := readRESPValue(r)
+			if err != nil {
+				return respValue{}, err
+			}
+			array = append(array, item)
+		}
+		return respValue{kind: kind, array: array}, nil
+	default:
+		return respValue{}, fmt.Errorf("%w: 未知的类型前缀 %q", errRESPProtocol, kind)
+	}
+}
+
+// readRESPLine 读取以CRLF结尾的一行，不含CRLF
+func readRESPLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("%w: 行过长", errRESPProtocol)
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: 行没有以CRLF结尾", errRESPProtocol)
+	}
+	return append([]byte(nil), line[:len(line)-2]...), nil
+}
+
+// respLength 解析批量字符串或数组的长度，-1表示空值
+func respLength(line []byte) (int, error) {
+	n, err := strconv.Atoi(string(line))
+	if err != nil || n < -1 || n > respMaxLength {
+		return 0, fmt.Errorf("%w: 无效的长度 %q", errRESPProtocol, line)
+	}
+	return n, nil
+}
+
+// err 把错误回复转换为error
+func (v respValue) err() error {
+	if v.kind == respError {
+		return &respReplyError{Message: string(v.str)}
+	}
+	return nil
+}
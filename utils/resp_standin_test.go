@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// respStandIn 进程内的Redis协议替身服务，实现共享缓存用到的命令，用于测试RedisCache
+// 支持 PING、AUTH、SELECT、GET、SET（PX/EX）、DEL、EXISTS、SADD、SMEMBERS、PEXPIRE、SCAN（MATCH/COUNT）和 FLUSHDB
+type respStandIn struct {
+	listener net.Listener
+	password string
+
+	mu      sync.Mutex
+	data    map[string]*standInValue
+	cursors map[int]string // SCAN游标对应的下一批的起始键
+	cursor  int            // 最近分配的SCAN游标
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// standInValue 替身服务中的一个值，字符串或集合
+type standInValue struct {
+	str       []byte
+	set       map[string]struct{}
+	expiresAt time.Time // 零值表示不过期
+}
+
+// newRESPStandIn 在127.0.0.1的随机端口启动替身服务，password不为空时要求先认证
+func newRESPStandIn(password string) (*respStandIn, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &respStandIn{
+		listener: listener,
+		password: password,
+		data:     make(map[string]*standInValue),
+		cursors:  make(map[int]string),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 替身服务的监听地址
+func (s *respStandIn) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止监听并断开所有连接
+func (s *respStandIn) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Len 未过期的键的数量
+func (s *respStandIn) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.data {
+		if s.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *respStandIn) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle 逐条读取命令并回复，直到连接关闭或出现协议错误
+func (s *respStandIn) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	authed := s.password == ""
+	for {
+		cmd, err := readRESPValue(reader)
+		if err != nil {
+			return
+		}
+		if cmd.kind != respArray || len(cmd.array) == 0 {
+			return
+		}
+		args := make([][]byte, len(cmd.array))
+		for i, arg := range cmd.array {
+			args[i] = arg.str
+		}
+
+		var reply respValue
+		name := strings.ToUpper(string(args[0]))
+		switch {
+		case name == "AUTH":
+			if len(args) == 2 && string(args[1]) == s.password {
+				authed = true
+				reply = standInOK
+			} else {
+				reply = standInError("WRONGPASS invalid password")
+			}
+		case !authed:
+			reply = standInError("NOAUTH Authentication required.")
+		default:
+			reply = s.execute(name, args[1:])
+		}
+
+		writeRESPValue(writer, reply)
+		// 流水线中的命令全部处理完再发送
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeRESPValue 写出任意回复
+func writeRESPValue(w *bufio.Writer, v respValue) {
+	switch v.kind {
+	case respSimpleString, respError:
+		w.WriteByte(v.kind)
+		w.Write(v.str)
+		w.WriteString("\r\n")
+	case respInteger:
+		w.WriteByte(respInteger)
+		w.WriteString(strconv.FormatInt(v.num, 10))
+		w.WriteString("\r\n")
+	case respBulkString:
+		if v.null {
+			writeRESPBulk(w, nil)
+			return
+		}
+		writeRESPBulk(w, v.str)
+	case respArray:
+		w.WriteByte(respArray)
+		if v.null {
+			w.WriteString("-1\r\n")
+			return
+		}
+		w.WriteString(strconv.Itoa(len(v.array)))
+		w.WriteString("\r\n")
+		for _, item := range v.array {
+			writeRESPValue(w, item)
+		}
+	}
+}
+
+var standInOK = respValue{kind: respSimpleString, str: []byte("OK")}
+
+func standInError(message string) respValue {
+	return respValue{kind: respError, str: []byte(message)}
+}
+
+func standInInteger(n int) respValue {
+	return respValue{kind: respInteger, num: int64(n)}
+}
+
+func standInBulk(b []byte) respValue {
+	return respValue{kind: respBulkString, str: b, null: b == nil}
+}
+
+// execute 执行一条命令
+func (s *respStandIn) execute(name string, args [][]byte) respValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch name {
+	case "PING":
+		return respValue{kind: respSimpleString, str: []byte("PONG")}
+	case "SELECT":
+		return standInOK
+	case "FLUSHDB":
+		s.data = make(map[string]*standInValue)
+		return standInOK
+	case "GET":
+		if len(args) != 1 {
+			return standInError("ERR wrong number of arguments for 'get' command")
+		}
+		v := s.lookup(string(args[0]))
+		if v == nil {
+			return standInBulk(nil)
+		}
+		if v.set != nil {
+			return standInError("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		return standInBulk(v.str)
+	case "SET":
+		return s.executeSet(args)
+	case "DEL", "EXISTS":
+		n := 0
+		for _, key := range args {
+			if s.lookup(string(key)) != nil {
+				n++
+				if name == "DEL" {
+					delete(s.data, string(key))
+				}
+			}
+		}
+		return standInInteger(n)
+	case "SADD":
+		if len(args) < 2 {
+			return standInError("ERR wrong number of arguments for 'sadd' command")
+		}
+		v := s.lookup(string(args[0]))
+		if v == nil {
+			v = &standInValue{set: make(map[string]struct{})}
+			s.data[string(args[0])] = v
+		} else if v.set == nil {
+			return standInError("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		added := 0
+		for _, member := range args[1:] {
+			if _, ok := v.set[string(member)]; !ok {
+				v.set[string(member)] = struct{}{}
+				added++
+			}
+		}
+		return standInInteger(added)
+	case "SMEMBERS":
+		if len(args) != 1 {
+			return standInError("ERR wrong number of arguments for 'smembers' command")
+		}
+		reply := respValue{kind: respArray, array: []respValue{}}
+		if v := s.lookup(string(args[0])); v != nil {
+			for member := range v.set {
+				reply.array = append(reply.array, standInBulk([]byte(member)))
+			}
+		}
+		return reply
+	case "PEXPIRE":
+		if len(args) != 2 {
+			return standInError("ERR wrong number of arguments for 'pexpire' command")
+		}
+		ms, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return standInError("ERR value is not an integer or out of range")
+		}
+		v := s.lookup(string(args[0]))
+		if v == nil {
+			return standInInteger(0)
+		}
+		v.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return standInInteger(1)
+	case "SCAN":
+		return s.executeScan(args)
+	default:
+		return standInError("ERR unknown command '" + name + "'")
+	}
+}
+
+// executeSet SET key value [PX milliseconds | EX seconds]
+func (s *respStandIn) executeSet(args [][]byte) respValue {
+	if len(args) != 2 && len(args) != 4 {
+		return standInError("ERR syntax error")
+	}
+	v := &standInValue{str: append([]byte(nil), args[1]...)}
+	if len(args) == 4 {
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil || n <= 0 {
+			return standInError("ERR invalid expire time in 'set' command")
+		}
+		switch strings.ToUpper(string(args[2])) {
+		case "PX":
+			v.expiresAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+		case "EX":
+			v.expiresAt = time.Now().Add(time.Duration(n) * time.Second)
+		default:
+			return standInError("ERR syntax error")
+		}
+	}
+	s.data[string(args[0])] = v
+	return standInOK
+}
+
+// executeScan SCAN cursor [MATCH pattern] [COUNT count]，按键排序逐批返回
+// 与Redis相同，扫描期间一直存在的键都会被返回，删除已返回的键不影响后续的批次
+func (s *respStandIn) executeScan(args [][]byte) respValue {
+	if len(args) == 0 {
+		return standInError("ERR wrong number of arguments for 'scan' command")
+	}
+	cursor, err := strconv.Atoi(string(args[0]))
+	if err != nil || cursor < 0 {
+		return standInError("ERR invalid cursor")
+	}
+	pattern, count := "*", 10
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count <= 0 {
+				return standInError("ERR syntax error")
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if cursor != 0 {
+		from, ok := s.cursors[cursor]
+		if !ok {
+			return standInError("ERR invalid cursor")
+		}
+		delete(s.cursors, cursor)
+		start = sort.SearchStrings(keys, from)
+	}
+	end := min(start+count, len(keys))
+	matched := respValue{kind: respArray, array: []respValue{}}
+	for _, key := range keys[start:end] {
+		if s.lookup(key) != nil && globMatch(pattern, key) {
+			matched.array = append(matched.array, standInBulk([]byte(key)))
+		}
+	}
+	next := 0
+	if end < len(keys) {
+		s.cursor++
+		next = s.cursor
+		s.cursors[next] = keys[end]
+	}
+	return respValue{kind: respArray, array: []respValue{standInBulk([]byte(strconv.Itoa(next))), matched}}
+}
+
+// lookup 查找未过期的值，已过期的值被删除，调用方需持有锁
+func (s *respStandIn) lookup(key string) *standInValue {
+	v, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	if !v.expiresAt.IsZero() && time.Now().After(v.expiresAt) {
+		delete(s.data, key)
+		return nil
+	}
+	return v
+}
+
+// errGlobSyntax 匹配模式以单独的反斜杠结尾
+var errGlobSyntax = errors.New("匹配模式语法错误")
+
+// globMatch Redis匹配模式，支持 *、? 和反斜杠转义
+func globMatch(pattern, s string) bool {
+	ok, err := globMatchAt(pattern, s)
+	return ok && err == nil
+}
+
+func globMatchAt(pattern, s string) (bool, error) {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if ok, err := globMatchAt(pattern[1:], s[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		case '?':
+			if len(s) == 0 {
+				return false, nil
+			}
+			pattern, s = pattern[1:], s[1:]
+			continue
+		case '\\':
+			if len(pattern) == 1 {
+				return false, errGlobSyntax
+			}
+			pattern = pattern[1:]
+		}
+		if len(s) == 0 || s[0] != pattern[0] {
+			return false, nil
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0, nil
+}
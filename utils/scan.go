@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// bestEffortKey context中保存部分结果状态的键
+type bestEffortKey struct{}
+
+// bestEffortState 记录一次请求是否因容忍错误而产生了部分结果
+type bestEffortState struct {
+	partial atomic.Bool
+}
+
+// WithBestEffort 返回允许部分结果的context
+// 在此context下，HBase读取失败会被记录并跳过，而不是中止整个请求
+func WithBestEffort(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(bestEffortKey{}).(*bestEffortState); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, bestEffortKey{}, &bestEffortState{})
+}
+
+// IsPartial 判断当前请求是否返回了不完整的结果，不完整的结果不应写入缓存
+func IsPartial(ctx context.Context) bool {
+	state, ok := ctx.Value(bestEffortKey{}).(*bestEffortState)
+	return ok && state.partial.Load()
+}
+
+// AllowsPartial 判断当前请求是否允许部分结果
+func AllowsPartial(ctx context.Context) bool {
+	_, ok := ctx.Value(bestEffortKey{}).(*bestEffortState)
+	return ok
+}
+
+// markPartial 把当前请求标记为返回了不完整的结果，用于共享其他加载的不完整结果
+func markPartial(ctx context.Context) {
+	if state, ok := ctx.Value(bestEffortKey{}).(*bestEffortState); ok {
+		state.partial.Store(true)
+	}
+}
+
+// ToleratePartial 在允许部分结果时吞掉读取错误并标记结果不完整，否则原样返回错误
+// 请求被取消或超时产生的错误始终返回
+func ToleratePartial(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	state, ok := ctx.Value(bestEffortKey{}).(*bestEffortState)
+	if !ok {
+		return err
+	}
+
+	state.partial.Store(true)
+	logrus.Warnf("读取HBase失败，返回部分结果: %v", err)
+	return nil
+}
+
+// ScanEach 遍历扫描结果，fn返回false时提前结束扫描
+// 扫描正常结束（io.EOF）返回nil，真实错误会返回给调用方，而不是当作扫描结束处理
+func ScanEach(scan *hrpc.Scan, fn func(*hrpc.Result) bool) error {
+	ctx := scan.Context()
+	scanner := HBaseScan(scan)
+	defer scanner.Close()
+
+	for {
+		res, err := scanner.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ToleratePartial(ctx, fmt.Errorf("扫描表 %s 失败: %w", scan.Table(), err))
+		}
+
+		if !fn(res) {
+			return nil
+		}
+	}
+}
+
+// ScanAll 扫描并返回所有结果
+func ScanAll(scan *hrpc.Scan) ([]*hrpc.Result, error) {
+	var results []*hrpc.Result
+	err := ScanEach(scan, func(res *hrpc.Result) bool {
+		results = append(results, res)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
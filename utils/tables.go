@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"gohbase/config"
+)
+
+// Tables 获取带命名空间的HBase表名，所有Get/Scan/Put都通过它获取表名
+// 不同环境配置不同的tables.namespace即可共用一个集群
+func Tables() config.TableConfig {
+	return config.Current().Tables.Qualified("")
+}
+
+// WriteTables 获取随机写入使用的表名
+// 配置了write_generator.namespace时写入该命名空间，避免模拟数据混入业务表
+func WriteTables() config.TableConfig {
+	conf := config.Current()
+	return conf.Tables.Qualified(conf.WriteGenerator.Namespace)
+}
+
+// ResolveTable 把逻辑表名（如 movies、movie_ratings）解析为 namespace:table
+func ResolveTable(logical string) (string, error) {
+	name, ok := Tables().Names()[logical]
+	if !ok {
+		return "", fmt.Errorf("未知的逻辑表名: %s", logical)
+	}
+	return name, nil
+}
+
+// TableNames 获取所有逻辑表名到实际表名的映射，用于状态展示
+func TableNames() map[string]interface{} {
+	names := make(map[string]interface{})
+	for logical, name := range Tables().Names() {
+		names[logical] = name
+	}
+	if ns := config.Current().WriteGenerator.Namespace; ns != "" {
+		write := WriteTables()
+		names["writeGenerator"] = map[string]string{
+			"ratings":       write.Ratings,
+			"movie_ratings": write.MovieRatings,
+		}
+	}
+	return names
+}
@@ -0,0 +1,449 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"gohbase/config"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tsuna/gohbase/hrpc"
+	"github.com/tsuna/gohbase/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// thriftBackend 通过HBase Thrift2网关访问数据，把hrpc请求转换为THBaseService调用
+// 与原生RPC客户端实现相同的接口，上层的重试、熔断、指标和追踪逻辑不需要区分访问方式
+type thriftBackend struct {
+	client *thriftClient
+}
+
+// newThriftBackend 创建Thrift2访问方式的实现
+func newThriftBackend(conf *config.HBaseConfig) *thriftBackend {
+	return &thriftBackend{client: newThriftClient(conf)}
+}
+
+// thriftRegion 满足hrpc请求转换为protobuf时对region的要求
+// Thrift网关自行定位region，请求中的region信息不会被使用
+type thriftRegion struct {
+	hrpc.RegionInfo
+}
+
+// RegionSpecifier 返回空的region描述
+func (thriftRegion) RegionSpecifier() *pb.RegionSpecifier {
+	return &pb.RegionSpecifier{Type: hrpc.RegionSpecifierRegionName, Value: []byte{}}
+}
+
+// Get 转换为 THBaseService.get
+func (b *thriftBackend) Get(get *hrpc.Get) (*hrpc.Result, error) {
+	get.SetRegion(thriftRegion{})
+	req := get.ToProto().(*pb.GetRequest).Get
+	tget, err := encodeTGet(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.client.get(get.Context(), get.Table(), tget)
+	if err != nil {
+		return nil, err
+	}
+	return res.toResult(), nil
+}
+
+// Put 转换为 THBaseService.put，只支持Put类型的变更
+func (b *thriftBackend) Put(put *hrpc.Mutate) (*hrpc.Result, error) {
+	put.SetRegion(thriftRegion{})
+	mutation := put.ToProto().(*pb.MutateRequest).Mutation
+	if mutation.GetMutateType() != pb.MutationProto_PUT {
+		return nil, fmt.Errorf("%w: 不支持 %s 类型的变更", errThriftUnsupported, put.Description())
+	}
+
+	if err := b.client.put(put.Context(), put.Table(), encodeTPut(mutation)); err != nil {
+		return nil, err
+	}
+	return &hrpc.Result{}, nil
+}
+
+// Scan 转换为 openScanner/getScannerRows/closeScanner，扫描器在第一次Next时打开
+func (b *thriftBackend) Scan(scan *hrpc.Scan) hrpc.Scanner {
+	s := &thriftScanner{client: b.client, scan: scan}
+	scan.SetRegion(thriftRegion{})
+	req := scan.ToProto().(*pb.ScanRequest)
+	s.caching = toThriftI32(req.GetNumberOfRows())
+	if s.caching <= 0 {
+		s.caching = 1
+	}
+	s.tscan, s.err = encodeTScan(req.Scan, s.caching)
+	return s
+}
+
+// Close 关闭连接池
+func (b *thriftBackend) Close() {
+	b.client.Close()
+}
+
+// thriftScanner 基于Thrift2扫描器接口的hrpc.Scanner实现，每次按caching批量拉取行
+type thriftScanner struct {
+	client  *thriftClient
+	scan    *hrpc.Scan
+	tscan   func(w *thriftWriter)
+	caching int32
+
+	mu      sync.Mutex
+	id      int32
+	opened  bool
+	done    bool
+	err     error // 打开或拉取时的错误，返回一次后转为io.EOF
+	results []*thriftResult
+}
+
+// Next 返回下一行，所有行返回后返回io.EOF
+func (s *thriftScanner) Next() (*hrpc.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := s.scan.Context()
+	for len(s.results) == 0 {
+		if s.err != nil {
+			err := s.err
+			s.err = nil
+			s.close()
+			return nil, err
+		}
+		if s.done {
+			return nil, io.EOF
+		}
+
+		if !s.opened {
+			s.id, s.err = s.client.openScanner(ctx, s.scan.Table(), s.tscan)
+			if s.err != nil {
+				// 打开失败时服务端没有扫描器需要关闭
+				s.done = true
+				continue
+			}
+			s.opened = true
+		}
+
+		rows, err := s.client.getScannerRows(ctx, s.id, s.caching)
+		if err != nil {
+			s.err = err
+			continue
+		}
+		// 返回的行数少于请求的数量说明已经扫描到结尾
+		if len(rows) < int(s.caching) {
+			s.close()
+		}
+		s.results = rows
+	}
+
+	res := s.results[0]
+	s.results = s.results[1:]
+	return res.toResult(), nil
+}
+
+// Close 提前结束扫描并释放服务端的扫描器
+func (s *thriftScanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = nil
+	s.close()
+	return nil
+}
+
+// close 关闭服务端扫描器，调用方需持有锁
+func (s *thriftScanner) close() {
+	s.done = true
+	if !s.opened {
+		return
+	}
+	s.opened = false
+
+	// 扫描的ctx可能已经取消，使用独立的ctx释放服务端资源
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.timeout)
+	defer cancel()
+	if err := s.client.closeScanner(ctx, s.id); err != nil {
+		logrus.Debugf("关闭Thrift扫描器 %d 失败: %v", s.id, err)
+	}
+}
+
+// GetScanMetrics Thrift接口不提供扫描指标
+func (s *thriftScanner) GetScanMetrics() map[string]int64 {
+	return nil
+}
+
+// toResult 转换为hrpc.Result，与原生客户端返回的结构一致
+func (r *thriftResult) toResult() *hrpc.Result {
+	if r == nil {
+		return &hrpc.Result{}
+	}
+	result := &hrpc.Result{Cells: make([]*hrpc.Cell, 0, len(r.cells))}
+	for _, c := range r.cells {
+		cell := &hrpc.Cell{
+			Row:       r.row,
+			Family:    c.family,
+			Qualifier: c.qualifier,
+			Value:     c.value,
+		}
+		if c.timestamp != nil {
+			cell.Timestamp = proto.Uint64(uint64(*c.timestamp))
+		}
+		result.Cells = append(result.Cells, cell)
+	}
+	return result
+}
+
+// encodeTGet 把protobuf的Get转换为TGet
+// TGet: 1 row, 2 columns, 4 timeRange, 5 maxVersions, 6 filterString
+func encodeTGet(get *pb.Get) (func(w *thriftWriter), error) {
+	if get.StoreLimit != nil || get.StoreOffset != nil || get.GetExistenceOnly() {
+		return nil, fmt.Errorf("%w: Get不支持storeLimit、storeOffset或existenceOnly", errThriftUnsupported)
+	}
+	filter, err := filterString(get.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w *thriftWriter) {
+		w.field(thriftString, 1)
+		w.binary(get.Row)
+		writeColumns(w, 2, get.Column)
+		writeTimeRange(w, 4, get.TimeRange)
+		if get.MaxVersions != nil {
+			w.field(thriftI32, 5)
+			w.i32(toThriftI32(get.GetMaxVersions()))
+		}
+		if filter != "" {
+			w.field(thriftString, 6)
+			w.str(filter)
+		}
+	}, nil
+}
+
+// encodeTScan 把protobuf的Scan转换为TScan
+// TScan: 1 startRow, 2 stopRow, 3 columns, 4 caching, 5 maxVersions, 6 timeRange, 7 filterString, 11 reversed
+func encodeTScan(scan *pb.Scan, caching int32) (func(w *thriftWriter), error) {
+	if scan.StoreLimit != nil || scan.StoreOffset != nil {
+		return nil, fmt.Errorf("%w: Scan不支持storeLimit或storeOffset", errThriftUnsupported)
+	}
+	filter, err := filterString(scan.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w *thriftWriter) {
+		if len(scan.StartRow) > 0 {
+			w.field(thriftString, 1)
+			w.binary(scan.StartRow)
+		}
+		if len(scan.StopRow) > 0 {
+			w.field(thriftString, 2)
+			w.binary(scan.StopRow)
+		}
+		writeColumns(w, 3, scan.Column)
+		w.field(thriftI32, 4)
+		w.i32(caching)
+		if scan.MaxVersions != nil {
+			w.field(thriftI32, 5)
+			w.i32(toThriftI32(scan.GetMaxVersions()))
+		}
+		writeTimeRange(w, 6, scan.TimeRange)
+		if filter != "" {
+			w.field(thriftString, 7)
+			w.str(filter)
+		}
+		if scan.GetReversed() {
+			w.field(thriftBool, 11)
+			w.boolean(true)
+		}
+	}, nil
+}
+
+// encodeTPut 把protobuf的Put转换为TPut
+// TPut: 1 row, 2 columnValues, 3 timestamp, 5 attributes
+func encodeTPut(m *pb.MutationProto) func(w *thriftWriter) {
+	return func(w *thriftWriter) {
+		w.field(thriftString, 1)
+		w.binary(m.Row)
+
+		count := 0
+		for _, cv := range m.ColumnValue {
+			count += len(cv.QualifierValue)
+		}
+		w.field(thriftList, 2)
+		w.list(thriftStruct, count)
+		for _, cv := range m.ColumnValue {
+			for _, qv := range cv.QualifierValue {
+				w.field(thriftString, 1)
+				w.binary(cv.Family)
+				w.field(thriftString, 2)
+				w.binary(qv.Qualifier)
+				w.field(thriftString, 3)
+				w.binary(qv.Value)
+				if qv.Timestamp != nil {
+					w.field(thriftI64, 4)
+					w.i64(int64(qv.GetTimestamp()))
+				}
+				w.stop()
+			}
+		}
+
+		if m.Timestamp != nil {
+			w.field(thriftI64, 3)
+			w.i64(int64(m.GetTimestamp()))
+		}
+
+		// 属性中包含TTL等设置
+		if len(m.Attribute) > 0 {
+			w.field(thriftMap, 5)
+			w.buf.WriteByte(thriftString)
+			w.buf.WriteByte(thriftString)
+			w.i32(int32(len(m.Attribute)))
+			for _, attr := range m.Attribute {
+				w.str(attr.GetName())
+				w.binary(attr.Value)
+			}
+		}
+	}
+}
+
+// writeColumns 写入list<TColumn>，TColumn: 1 family, 2 qualifier
+// 只指定列族时整个列族都会返回
+func writeColumns(w *thriftWriter, id int16, columns []*pb.Column) {
+	if len(columns) == 0 {
+		return
+	}
+	count := 0
+	for _, c := range columns {
+		count += max(len(c.Qualifier), 1)
+	}
+
+	w.field(thriftList, id)
+	w.list(thriftStruct, count)
+	for _, c := range columns {
+		if len(c.Qualifier) == 0 {
+			w.field(thriftString, 1)
+			w.binary(c.Family)
+			w.stop()
+			continue
+		}
+		for _, q := range c.Qualifier {
+			w.field(thriftString, 1)
+			w.binary(c.Family)
+			w.field(thriftString, 2)
+			w.binary(q)
+			w.stop()
+		}
+	}
+}
+
+// writeTimeRange 写入TTimeRange: 1 minStamp, 2 maxStamp，未限制时间范围时不写入
+func writeTimeRange(w *thriftWriter, id int16, tr *pb.TimeRange) {
+	if tr == nil || (tr.From == nil && tr.To == nil) {
+		return
+	}
+	to := int64(math.MaxInt64)
+	if tr.To != nil && tr.GetTo() < math.MaxInt64 {
+		to = int64(tr.GetTo())
+	}
+	w.field(thriftStruct, id)
+	w.field(thriftI64, 1)
+	w.i64(int64(tr.GetFrom()))
+	w.field(thriftI64, 2)
+	w.i64(to)
+	w.stop()
+}
+
+// filterPrefix gohbase过滤器类名的公共前缀
+const filterPrefix = "org.apache.hadoop.hbase.filter."
+
+// filterString 把protobuf过滤器转换为HBase过滤器语言，未设置过滤器时返回空字符串
+// 只支持常用的过滤器，其余过滤器返回errThriftUnsupported
+func filterString(f *pb.Filter) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+
+	name := strings.TrimPrefix(f.GetName(), filterPrefix)
+	unmarshal := func(m proto.Message) error {
+		if err := proto.Unmarshal(f.SerializedFilter, m); err != nil {
+			return fmt.Errorf("解析过滤器 %s 失败: %w", name, err)
+		}
+		return nil
+	}
+
+	switch name {
+	case "PrefixFilter":
+		var pf pb.PrefixFilter
+		if err := unmarshal(&pf); err != nil {
+			return "", err
+		}
+		return "PrefixFilter(" + quoteFilterArg(pf.Prefix) + ")", nil
+	case "PageFilter":
+		var pf pb.PageFilter
+		if err := unmarshal(&pf); err != nil {
+			return "", err
+		}
+		return "PageFilter(" + strconv.FormatInt(pf.GetPageSize(), 10) + ")", nil
+	case "ColumnPrefixFilter":
+		var cf pb.ColumnPrefixFilter
+		if err := unmarshal(&cf); err != nil {
+			return "", err
+		}
+		return "ColumnPrefixFilter(" + quoteFilterArg(cf.Prefix) + ")", nil
+	case "MultipleColumnPrefixFilter":
+		var mf pb.MultipleColumnPrefixFilter
+		if err := unmarshal(&mf); err != nil {
+			return "", err
+		}
+		args := make([]string, len(mf.SortedPrefixes))
+		for i, p := range mf.SortedPrefixes {
+			args[i] = quoteFilterArg(p)
+		}
+		return "MultipleColumnPrefixFilter(" + strings.Join(args, ",") + ")", nil
+	case "ColumnCountGetFilter":
+		var cf pb.ColumnCountGetFilter
+		if err := unmarshal(&cf); err != nil {
+			return "", err
+		}
+		return "ColumnCountGetFilter(" + strconv.Itoa(int(cf.GetLimit())) + ")", nil
+	case "InclusiveStopFilter":
+		var sf pb.InclusiveStopFilter
+		if err := unmarshal(&sf); err != nil {
+			return "", err
+		}
+		return "InclusiveStopFilter(" + quoteFilterArg(sf.StopRowKey) + ")", nil
+	case "KeyOnlyFilter":
+		return "KeyOnlyFilter()", nil
+	case "FirstKeyOnlyFilter":
+		return "FirstKeyOnlyFilter()", nil
+	case "FilterList":
+		var fl pb.FilterList
+		if err := unmarshal(&fl); err != nil {
+			return "", err
+		}
+		op := " AND "
+		if fl.GetOperator() == pb.FilterList_MUST_PASS_ONE {
+			op = " OR "
+		}
+		parts := make([]string, 0, len(fl.Filters))
+		for _, sub := range fl.Filters {
+			s, err := filterString(sub)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, "("+s+")")
+		}
+		return strings.Join(parts, op), nil
+	default:
+		return "", fmt.Errorf("%w: 过滤器 %s 无法转换为Thrift过滤器语言", errThriftUnsupported, name)
+	}
+}
+
+// quoteFilterArg 按过滤器语言的规则给参数加单引号，参数中的单引号写两次
+func quoteFilterArg(b []byte) string {
+	return "'" + strings.ReplaceAll(string(b), "'", "''") + "'"
+}
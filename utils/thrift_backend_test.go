@@ -0,0 +1,408 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tsuna/gohbase/filter"
+	"github.com/tsuna/gohbase/hrpc"
+)
+
+// forEachTransport 分别以buffered和framed传输运行同一组用例
+func forEachTransport(t *testing.T, run func(t *testing.T, f *fakeTHBase, b *thriftBackend)) {
+	for _, tc := range []struct {
+		name   string
+		framed bool
+	}{
+		{"buffered", false},
+		{"framed", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeTHBase(t, tc.framed)
+			run(t, f, f.backend(t))
+		})
+	}
+}
+
+// checkField 检查请求中字段的类型和值
+func checkField(t *testing.T, s tstruct, id int16, typ byte, want string) {
+	t.Helper()
+	got, ok := s[id]
+	if !ok {
+		t.Errorf("字段 %d 不存在", id)
+		return
+	}
+	if got.typ != typ {
+		t.Errorf("字段 %d 的类型为 %d，期望 %d", id, got.typ, typ)
+	}
+	if want != "" && string(s.bytes(id)) != want {
+		t.Errorf("字段 %d 的值为 %q，期望 %q", id, s.bytes(id), want)
+	}
+}
+
+// cellMap 把结果转换为 "列族:列" -> 值
+func cellMap(res *hrpc.Result) map[string]string {
+	out := map[string]string{}
+	for _, c := range res.Cells {
+		out[string(c.Family)+":"+string(c.Qualifier)] = string(c.Value)
+	}
+	return out
+}
+
+func TestThriftBackendGet(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+		f.put("1", "info:title", "Toy Story")
+		f.put("1", "info:genres", "Animation")
+		f.put("1", "stats:count", "10")
+
+		get, err := hrpc.NewGetStr(context.Background(), "movies", "1",
+			hrpc.Families(map[string][]string{"info": nil}),
+			hrpc.Filters(filter.NewColumnPrefixFilter([]byte("ti'"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := b.Get(get)
+		if err != nil {
+			t.Fatalf("Get失败: %v", err)
+		}
+		cells := cellMap(res)
+		if len(cells) != 2 || cells["info:title"] != "Toy Story" || cells["info:genres"] != "Animation" {
+			t.Errorf("返回的单元格不正确: %v", cells)
+		}
+		for _, c := range res.Cells {
+			if string(c.Row) != "1" || c.Timestamp == nil || int64(*c.Timestamp) != fakeTimestamp {
+				t.Errorf("单元格的行键或时间戳不正确: %+v", c)
+			}
+		}
+
+		calls := f.callsOf("get")
+		if len(calls) != 1 {
+			t.Fatalf("get调用了 %d 次", len(calls))
+		}
+		args := calls[0].args
+		checkField(t, args, 1, thriftString, "movies")
+		checkField(t, args, 2, thriftStruct, "")
+		tget, _ := args[2].val.(tstruct)
+		checkField(t, tget, 1, thriftString, "1")
+		checkField(t, tget, 2, thriftList, "")
+		checkField(t, tget, 6, thriftString, "ColumnPrefixFilter('ti''')")
+		columns := tget.structs(2)
+		if len(columns) != 1 || string(columns[0].bytes(1)) != "info" {
+			t.Fatalf("TGet的列不正确: %v", columns)
+		}
+		if _, ok := columns[0][2]; ok {
+			t.Errorf("只指定列族时不应写入qualifier")
+		}
+
+		// 行不存在时返回空结果
+		get, _ = hrpc.NewGetStr(context.Background(), "movies", "404")
+		res, err = b.Get(get)
+		if err != nil || len(res.Cells) != 0 {
+			t.Errorf("不存在的行应返回空结果: %v, %v", res.Cells, err)
+		}
+	})
+}
+
+func TestThriftBackendPut(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+		put, err := hrpc.NewPutStr(context.Background(), "ratings", "u1_m1",
+			map[string]map[string][]byte{"info": {"rating": []byte("4.5")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Put(put); err != nil {
+			t.Fatalf("Put失败: %v", err)
+		}
+		if v, ok := f.value("u1_m1", "info:rating"); !ok || v != "4.5" {
+			t.Errorf("写入的值为 %q", v)
+		}
+
+		calls := f.callsOf("put")
+		if len(calls) != 1 {
+			t.Fatalf("put调用了 %d 次", len(calls))
+		}
+		checkField(t, calls[0].args, 1, thriftString, "ratings")
+		tput, _ := calls[0].args[2].val.(tstruct)
+		checkField(t, tput, 1, thriftString, "u1_m1")
+		checkField(t, tput, 2, thriftList, "")
+		values := tput.structs(2)
+		if len(values) != 1 {
+			t.Fatalf("TPut的单元格数为 %d", len(values))
+		}
+		checkField(t, values[0], 1, thriftString, "info")
+		checkField(t, values[0], 2, thriftString, "rating")
+		checkField(t, values[0], 3, thriftString, "4.5")
+
+		// Thrift2的put不能表达删除
+		del, _ := hrpc.NewDelStr(context.Background(), "ratings", "u1_m1", nil)
+		if _, err := b.Put(del); !errors.Is(err, errThriftUnsupported) {
+			t.Errorf("删除应返回errThriftUnsupported，实际为 %v", err)
+		}
+	})
+}
+
+func TestThriftBackendScan(t *testing.T) {
+	cases := []struct {
+		name        string
+		start, stop string
+		caching     uint32
+		wantRows    []string
+		wantFetches int // getScannerRows的调用次数
+	}{
+		// 最后一批为空，说明已到结尾
+		{"full batches", "r1", "r5", 2, []string{"r1", "r2", "r3", "r4"}, 3},
+		// 最后一批不足caching行，不再继续拉取
+		{"short batch", "r1", "", 2, []string{"r1", "r2", "r3", "r4", "r5"}, 3},
+		{"single batch", "r2", "r4", 10, []string{"r2", "r3"}, 1},
+		{"empty range", "x", "", 2, nil, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+				for _, row := range []string{"r1", "r2", "r3", "r4", "r5"} {
+					f.put(row, "info:title", "title "+row)
+					f.put(row, "info:year", "1995")
+					f.put(row, "stats:count", "1")
+				}
+
+				scan, err := hrpc.NewScanRangeStr(context.Background(), "movies", tc.start, tc.stop,
+					hrpc.Families(map[string][]string{"info": {"title"}}),
+					hrpc.Filters(filter.NewPrefixFilter([]byte("r"))),
+					hrpc.NumberOfRows(tc.caching))
+				if err != nil {
+					t.Fatal(err)
+				}
+				scanner := b.Scan(scan)
+				var rows []string
+				for {
+					res, err := scanner.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatalf("Next失败: %v", err)
+					}
+					if cells := cellMap(res); len(cells) != 1 || cells["info:title"] == "" {
+						t.Errorf("返回的单元格不正确: %v", cells)
+					}
+					rows = append(rows, string(res.Cells[0].Row))
+				}
+				if _, err := scanner.Next(); err != io.EOF {
+					t.Errorf("扫描结束后应继续返回io.EOF，实际为 %v", err)
+				}
+				scanner.Close()
+
+				if len(rows) != len(tc.wantRows) {
+					t.Fatalf("返回的行为 %v，期望 %v", rows, tc.wantRows)
+				}
+				for i := range rows {
+					if rows[i] != tc.wantRows[i] {
+						t.Fatalf("返回的行为 %v，期望 %v", rows, tc.wantRows)
+					}
+				}
+				if n := len(f.callsOf("getScannerRows")); n != tc.wantFetches {
+					t.Errorf("getScannerRows调用了 %d 次，期望 %d 次", n, tc.wantFetches)
+				}
+				if n := len(f.callsOf("closeScanner")); n != 1 {
+					t.Errorf("closeScanner调用了 %d 次", n)
+				}
+
+				open := f.callsOf("openScanner")
+				if len(open) != 1 {
+					t.Fatalf("openScanner调用了 %d 次", len(open))
+				}
+				checkField(t, open[0].args, 1, thriftString, "movies")
+				tscan, _ := open[0].args[2].val.(tstruct)
+				checkField(t, tscan, 1, thriftString, tc.start)
+				if tc.stop == "" {
+					if _, ok := tscan[2]; ok {
+						t.Errorf("未指定stopRow时不应写入字段2")
+					}
+				} else {
+					checkField(t, tscan, 2, thriftString, tc.stop)
+				}
+				checkField(t, tscan, 3, thriftList, "")
+				checkField(t, tscan, 4, thriftI32, "")
+				if got := tscan[4].val.(int32); got != int32(tc.caching) {
+					t.Errorf("caching为 %d，期望 %d", got, tc.caching)
+				}
+				checkField(t, tscan, 7, thriftString, "PrefixFilter('r')")
+				columns := tscan.structs(3)
+				if len(columns) != 1 || string(columns[0].bytes(1)) != "info" || string(columns[0].bytes(2)) != "title" {
+					t.Errorf("TScan的列不正确: %v", columns)
+				}
+
+				// 假网关从1开始分配扫描器ID
+				fetch := f.callsOf("getScannerRows")[0].args
+				checkField(t, fetch, 1, thriftI32, "")
+				checkField(t, fetch, 2, thriftI32, "")
+				if fetch[1].val.(int32) != 1 || fetch[2].val.(int32) != int32(tc.caching) {
+					t.Errorf("getScannerRows的参数不正确: %v", fetch)
+				}
+			})
+		})
+	}
+}
+
+func TestThriftBackendScanClosedEarly(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+		for _, row := range []string{"r1", "r2", "r3"} {
+			f.put(row, "info:title", row)
+		}
+		scan, _ := hrpc.NewScanRangeStr(context.Background(), "movies", "", "", hrpc.NumberOfRows(1))
+		scanner := b.Scan(scan)
+		if _, err := scanner.Next(); err != nil {
+			t.Fatalf("Next失败: %v", err)
+		}
+		scanner.Close()
+		if n := len(f.callsOf("closeScanner")); n != 1 {
+			t.Errorf("提前关闭时closeScanner调用了 %d 次", n)
+		}
+		if _, err := scanner.Next(); err != io.EOF {
+			t.Errorf("关闭后应返回io.EOF，实际为 %v", err)
+		}
+	})
+}
+
+func TestThriftBackendServiceErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		fault    fakeFault
+		kind     string // thriftServiceError的Kind，应用异常时为空
+		rejected bool
+	}{
+		{"get TIOError", "get", fakeFault{exception: 1, message: "region offline"}, "TIOError", false},
+		{"get TIllegalArgument", "get", fakeFault{exception: 2, message: "bad row"}, "TIllegalArgument", true},
+		{"put TIllegalArgument", "put", fakeFault{exception: 2, message: "no family"}, "TIllegalArgument", true},
+		{"openScanner TIOError", "openScanner", fakeFault{exception: 1, message: "table disabled"}, "TIOError", false},
+		{"getScannerRows TIOError", "getScannerRows", fakeFault{exception: 1, message: "lease expired"}, "TIOError", false},
+		{"get TApplicationException", "get", fakeFault{application: true, message: "Invalid method name"}, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+				f.put("r1", "info:title", "title")
+				f.fault(tc.method, tc.fault)
+
+				err := callThrift(b, tc.method)
+				if err == nil {
+					t.Fatal("期望返回错误")
+				}
+				if tc.kind == "" {
+					var appErr *thriftApplicationError
+					if !errors.As(err, &appErr) || appErr.Message != tc.fault.message {
+						t.Errorf("期望应用异常，实际为 %v", err)
+					}
+				} else {
+					var svcErr *thriftServiceError
+					if !errors.As(err, &svcErr) {
+						t.Fatalf("期望thriftServiceError，实际为 %T: %v", err, err)
+					}
+					if svcErr.Kind != tc.kind || svcErr.Method != tc.method || svcErr.Message != tc.fault.message {
+						t.Errorf("异常解码不正确: %+v", svcErr)
+					}
+				}
+				if got := errors.Is(err, errThriftRejected); got != tc.rejected {
+					t.Errorf("errors.Is(err, errThriftRejected) = %v，期望 %v", got, tc.rejected)
+				}
+
+				// 声明的异常不影响连接，后续调用复用同一条连接
+				f.fault(tc.method, fakeFault{})
+				if err := callThrift(b, tc.method); err != nil {
+					t.Fatalf("清除异常后调用失败: %v", err)
+				}
+				if n := f.connCount(); n != 1 {
+					t.Errorf("建立了 %d 条连接，期望复用1条", n)
+				}
+			})
+		})
+	}
+}
+
+// callThrift 通过对应的hrpc请求触发一次方法调用，扫描会读完所有行
+func callThrift(b *thriftBackend, method string) error {
+	ctx := context.Background()
+	switch method {
+	case "put":
+		put, _ := hrpc.NewPutStr(ctx, "movies", "r1", map[string]map[string][]byte{"info": {"title": []byte("x")}})
+		_, err := b.Put(put)
+		return err
+	case "openScanner", "getScannerRows":
+		scan, _ := hrpc.NewScanStr(ctx, "movies")
+		scanner := b.Scan(scan)
+		defer scanner.Close()
+		for {
+			if _, err := scanner.Next(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	default:
+		get, _ := hrpc.NewGetStr(ctx, "movies", "r1")
+		_, err := b.Get(get)
+		return err
+	}
+}
+
+func TestThriftBackendScanErrorThenEOF(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+		f.put("r1", "info:title", "title")
+		f.fault("getScannerRows", fakeFault{exception: 1, message: "lease expired"})
+
+		scan, _ := hrpc.NewScanStr(context.Background(), "movies")
+		scanner := b.Scan(scan)
+		if _, err := scanner.Next(); err == nil || err == io.EOF {
+			t.Fatalf("期望返回拉取错误，实际为 %v", err)
+		}
+		if _, err := scanner.Next(); err != io.EOF {
+			t.Errorf("错误返回一次后应返回io.EOF，实际为 %v", err)
+		}
+		if n := len(f.callsOf("closeScanner")); n != 1 {
+			t.Errorf("拉取失败后closeScanner调用了 %d 次", n)
+		}
+	})
+}
+
+func TestThriftBackendCancelledContext(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, f *fakeTHBase, b *thriftBackend) {
+		f.put("r1", "info:title", "title")
+		f.fault("get", fakeFault{hang: true})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		get, _ := hrpc.NewGetStr(ctx, "movies", "r1")
+		start := time.Now()
+		_, err := b.Get(get)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("期望context.Canceled，实际为 %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("取消后 %v 才返回", elapsed)
+		}
+
+		// 中断的连接上可能残留响应，不能再复用
+		f.fault("get", fakeFault{})
+		get, _ = hrpc.NewGetStr(context.Background(), "movies", "r1")
+		if _, err := b.Get(get); err != nil {
+			t.Fatalf("取消后重新调用失败: %v", err)
+		}
+		if n := f.connCount(); n != 2 {
+			t.Errorf("建立了 %d 条连接，期望丢弃被中断的连接后新建1条", n)
+		}
+
+		// 已取消的ctx不发起调用
+		before := len(f.callsOf("get"))
+		get, _ = hrpc.NewGetStr(ctx, "movies", "r1")
+		if _, err := b.Get(get); !errors.Is(err, context.Canceled) {
+			t.Errorf("期望context.Canceled，实际为 %v", err)
+		}
+		if after := len(f.callsOf("get")); after != before {
+			t.Errorf("已取消的ctx仍发起了调用")
+		}
+	})
+}
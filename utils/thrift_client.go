@@ -0,0 +1,380 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gohbase/config"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// errThriftRejected 网关认为请求无效（TIllegalArgument或TApplicationException），重试没有意义
+	errThriftRejected = errors.New("Thrift网关拒绝了请求")
+	// errThriftUnsupported 请求中包含无法通过Thrift2接口表达的选项
+	errThriftUnsupported = errors.New("Thrift网关不支持该请求")
+)
+
+// thriftServiceError THBaseService声明的异常：TIOError或TIllegalArgument
+type thriftServiceError struct {
+	Method  string
+	Kind    string
+	Message string
+}
+
+func (e *thriftServiceError) Error() string {
+	return fmt.Sprintf("Thrift调用 %s 返回 %s: %s", e.Method, e.Kind, e.Message)
+}
+
+// Unwrap TIllegalArgument视为请求被拒绝，TIOError可以重试
+func (e *thriftServiceError) Unwrap() error {
+	if e.Kind == "TIllegalArgument" {
+		return errThriftRejected
+	}
+	return nil
+}
+
+// Unwrap 应用异常通常是方法不存在或参数无法解析，视为请求被拒绝
+func (e *thriftApplicationError) Unwrap() error {
+	return errThriftRejected
+}
+
+// thriftClient THBaseService客户端，维护到Thrift2网关的连接池
+// 每条连接同一时间只承载一个调用，发生传输或协议错误的连接直接丢弃
+type thriftClient struct {
+	addr     string
+	framed   bool
+	timeout  time.Duration
+	poolSize int
+
+	mu     sync.Mutex
+	idle   []*thriftConn
+	closed bool
+}
+
+// newThriftClient 根据配置创建Thrift客户端，连接在第一次调用时建立
+func newThriftClient(conf *config.HBaseConfig) *thriftClient {
+	return &thriftClient{
+		addr:     conf.ThriftAddr(),
+		framed:   conf.ThriftFramed,
+		timeout:  conf.ThriftTimeout,
+		poolSize: conf.ThriftPoolSize,
+	}
+}
+
+// acquire 从连接池取出一条空闲连接，没有空闲连接时新建
+func (c *thriftClient) acquire(ctx context.Context) (*thriftConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("Thrift客户端已关闭")
+	}
+	if n := len(c.idle); n > 0 {
+		tc := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return tc, nil
+	}
+	c.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("连接Thrift网关 %s 失败: %w", c.addr, err)
+	}
+	return &thriftConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// release 归还连接，连接已损坏、客户端已关闭或连接池已满时关闭连接
+func (c *thriftClient) release(tc *thriftConn, broken bool) {
+	c.mu.Lock()
+	if !broken && !c.closed && len(c.idle) < c.poolSize {
+		c.idle = append(c.idle, tc)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	tc.conn.Close()
+}
+
+// Close 关闭所有空闲连接，正在使用的连接在归还时关闭
+func (c *thriftClient) Close() {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.closed = true
+	c.mu.Unlock()
+
+	for _, tc := range idle {
+		tc.conn.Close()
+	}
+}
+
+// call 发起一次THBaseService调用
+// args写入参数结构体的字段，result解码返回结构体中的success字段，无返回值的方法传nil
+func (c *thriftClient) call(ctx context.Context, method string, args func(w *thriftWriter), result func(r *thriftReader, typ byte)) error {
+	// ctx已结束时不再发起调用，避免复用的连接上先发出请求再被中断
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tc, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.roundTrip(ctx, tc, method, args, result)
+
+	// 服务端声明的异常不影响连接状态，其余错误说明连接上可能残留未读完的数据
+	var svcErr *thriftServiceError
+	var appErr *thriftApplicationError
+	broken := err != nil && !errors.As(err, &svcErr) && !errors.As(err, &appErr)
+	c.release(tc, broken)
+	return err
+}
+
+// roundTrip 在指定连接上发送请求并读取响应，ctx取消时立即中断阻塞的读写
+func (c *thriftClient) roundTrip(ctx context.Context, tc *thriftConn, method string, args func(w *thriftWriter), result func(r *thriftReader, typ byte)) error {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	tc.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		tc.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	// 传输错误优先返回ctx的错误，避免被当作可重试的网络故障
+	transportErr := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("Thrift调用 %s 失败: %w", method, err)
+	}
+
+	tc.seq++
+	w := &thriftWriter{}
+	w.messageBegin(method, thriftCall, tc.seq)
+	args(w)
+	w.stop()
+	if err := w.writeTo(tc.conn, c.framed); err != nil {
+		return transportErr(err)
+	}
+
+	r := newThriftReader(tc.reader, c.framed)
+	name, typ, seq := r.messageBegin()
+	if r.err != nil {
+		return transportErr(r.err)
+	}
+	if typ == thriftException {
+		err := readApplicationError(r)
+		if r.err != nil {
+			return transportErr(r.err)
+		}
+		return err
+	}
+	if typ != thriftReply || name != method || seq != tc.seq {
+		return transportErr(fmt.Errorf("%w: 响应 %s#%d 与请求 %s#%d 不匹配", errThriftProtocol, name, seq, method, tc.seq))
+	}
+
+	var svcErr error
+	for r.err == nil {
+		ft, id := r.field()
+		if ft == thriftStop {
+			break
+		}
+		switch {
+		case id == 0 && result != nil:
+			result(r, ft)
+		case (id == 1 || id == 2) && ft == thriftStruct:
+			svcErr = readServiceError(r, method, id)
+		default:
+			r.skip(ft)
+		}
+	}
+	if r.err != nil {
+		return transportErr(r.err)
+	}
+	return svcErr
+}
+
+// readServiceError 解码TIOError(字段1)或TIllegalArgument(字段2)
+func readServiceError(r *thriftReader, method string, id int16) error {
+	err := &thriftServiceError{Method: method, Kind: "TIOError"}
+	if id == 2 {
+		err.Kind = "TIllegalArgument"
+	}
+	for r.err == nil {
+		typ, fid := r.field()
+		if typ == thriftStop {
+			break
+		}
+		if fid == 1 && typ == thriftString {
+			err.Message = r.str()
+		} else {
+			r.skip(typ)
+		}
+	}
+	return err
+}
+
+// get 调用 TResult get(1: binary table, 2: TGet tget)
+func (c *thriftClient) get(ctx context.Context, table []byte, tget func(w *thriftWriter)) (*thriftResult, error) {
+	var res *thriftResult
+	err := c.call(ctx, "get", func(w *thriftWriter) {
+		w.field(thriftString, 1)
+		w.binary(table)
+		w.field(thriftStruct, 2)
+		tget(w)
+		w.stop()
+	}, func(r *thriftReader, typ byte) {
+		if typ != thriftStruct {
+			r.skip(typ)
+			return
+		}
+		res = readThriftResult(r)
+	})
+	return res, err
+}
+
+// put 调用 void put(1: binary table, 2: TPut tput)
+func (c *thriftClient) put(ctx context.Context, table []byte, tput func(w *thriftWriter)) error {
+	return c.call(ctx, "put", func(w *thriftWriter) {
+		w.field(thriftString, 1)
+		w.binary(table)
+		w.field(thriftStruct, 2)
+		tput(w)
+		w.stop()
+	}, nil)
+}
+
+// openScanner 调用 i32 openScanner(1: binary table, 2: TScan tscan)
+func (c *thriftClient) openScanner(ctx context.Context, table []byte, tscan func(w *thriftWriter)) (int32, error) {
+	var id int32
+	found := false
+	err := c.call(ctx, "openScanner", func(w *thriftWriter) {
+		w.field(thriftString, 1)
+		w.binary(table)
+		w.field(thriftStruct, 2)
+		tscan(w)
+		w.stop()
+	}, func(r *thriftReader, typ byte) {
+		if typ != thriftI32 {
+			r.skip(typ)
+			return
+		}
+		id = r.i32()
+		found = true
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("%w: openScanner 没有返回扫描器ID", errThriftProtocol)
+	}
+	return id, err
+}
+
+// getScannerRows 调用 list<TResult> getScannerRows(1: i32 scannerId, 2: i32 numRows)
+func (c *thriftClient) getScannerRows(ctx context.Context, scannerID, numRows int32) ([]*thriftResult, error) {
+	var rows []*thriftResult
+	err := c.call(ctx, "getScannerRows", func(w *thriftWriter) {
+		w.field(thriftI32, 1)
+		w.i32(scannerID)
+		w.field(thriftI32, 2)
+		w.i32(numRows)
+	}, func(r *thriftReader, typ byte) {
+		if typ != thriftList {
+			r.skip(typ)
+			return
+		}
+		elem, n := r.list()
+		rows = make([]*thriftResult, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			if elem != thriftStruct {
+				r.skip(elem)
+				continue
+			}
+			rows = append(rows, readThriftResult(r))
+		}
+	})
+	return rows, err
+}
+
+// closeScanner 调用 void closeScanner(1: i32 scannerId)
+func (c *thriftClient) closeScanner(ctx context.Context, scannerID int32) error {
+	return c.call(ctx, "closeScanner", func(w *thriftWriter) {
+		w.field(thriftI32, 1)
+		w.i32(scannerID)
+	}, nil)
+}
+
+// thriftCell TColumnValue中的一个单元格
+type thriftCell struct {
+	family    []byte
+	qualifier []byte
+	value     []byte
+	timestamp *int64
+}
+
+// thriftResult TResult：一行数据
+type thriftResult struct {
+	row   []byte
+	cells []thriftCell
+}
+
+// readThriftResult 解码TResult(1: binary row, 2: list<TColumnValue> columnValues)
+func readThriftResult(r *thriftReader) *thriftResult {
+	res := &thriftResult{}
+	for r.err == nil {
+		typ, id := r.field()
+		if typ == thriftStop {
+			break
+		}
+		switch {
+		case id == 1 && typ == thriftString:
+			res.row = r.binary()
+		case id == 2 && typ == thriftList:
+			elem, n := r.list()
+			res.cells = make([]thriftCell, 0, n)
+			for i := 0; i < n && r.err == nil; i++ {
+				if elem != thriftStruct {
+					r.skip(elem)
+					continue
+				}
+				res.cells = append(res.cells, readThriftCell(r))
+			}
+		default:
+			r.skip(typ)
+		}
+	}
+	return res
+}
+
+// readThriftCell 解码TColumnValue(1: family, 2: qualifier, 3: value, 4: timestamp)
+func readThriftCell(r *thriftReader) thriftCell {
+	var cell thriftCell
+	for r.err == nil {
+		typ, id := r.field()
+		if typ == thriftStop {
+			break
+		}
+		switch {
+		case id == 1 && typ == thriftString:
+			cell.family = r.binary()
+		case id == 2 && typ == thriftString:
+			cell.qualifier = r.binary()
+		case id == 3 && typ == thriftString:
+			cell.value = r.binary()
+		case id == 4 && typ == thriftI64:
+			ts := r.i64()
+			cell.timestamp = &ts
+		default:
+			r.skip(typ)
+		}
+	}
+	return cell
+}
@@ -0,0 +1,411 @@
+package utils
+
+import (
+	"bufio"
+	"gohbase/config"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTimestamp 假网关返回的单元格时间戳
+const fakeTimestamp int64 = 1700000000000
+
+// tfield 解码后的一个Thrift字段，保留字段类型以便检查请求的编码
+type tfield struct {
+	typ byte
+	val any
+}
+
+// tstruct 按字段ID保存的Thrift结构体
+type tstruct map[int16]tfield
+
+// bytes 返回binary字段的值，字段不存在时返回nil
+func (s tstruct) bytes(id int16) []byte {
+	v, _ := s[id].val.([]byte)
+	return v
+}
+
+// structs 返回list<struct>字段的元素
+func (s tstruct) structs(id int16) []tstruct {
+	list, _ := s[id].val.([]any)
+	out := make([]tstruct, 0, len(list))
+	for _, v := range list {
+		if st, ok := v.(tstruct); ok {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// readTStruct 解码任意结构体
+func readTStruct(r *thriftReader) tstruct {
+	s := tstruct{}
+	for r.err == nil {
+		typ, id := r.field()
+		if typ == thriftStop {
+			break
+		}
+		s[id] = tfield{typ: typ, val: readTValue(r, typ)}
+	}
+	return s
+}
+
+// readTValue 按类型解码任意值，列表和集合解码为[]any，map解码为键值交替的[]any
+func readTValue(r *thriftReader, typ byte) any {
+	switch typ {
+	case thriftBool:
+		return r.boolean()
+	case thriftByte:
+		return r.byte()
+	case thriftI16:
+		return r.i16()
+	case thriftI32:
+		return r.i32()
+	case thriftI64, thriftDouble:
+		return r.i64()
+	case thriftString:
+		return r.binary()
+	case thriftStruct:
+		return readTStruct(r)
+	case thriftList, thriftSet:
+		elem, n := r.list()
+		list := make([]any, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			list = append(list, readTValue(r, elem))
+		}
+		return list
+	case thriftMap:
+		kt, vt := r.byte(), r.byte()
+		n := r.length()
+		list := make([]any, 0, 2*n)
+		for i := 0; i < n && r.err == nil; i++ {
+			list = append(list, readTValue(r, kt), readTValue(r, vt))
+		}
+		return list
+	default:
+		r.skip(typ)
+		return nil
+	}
+}
+
+// fakeCall 假网关收到的一次调用
+type fakeCall struct {
+	method string
+	args   tstruct
+}
+
+// fakeFault 让假网关对某个方法返回异常或不响应
+type fakeFault struct {
+	exception   int16 // 1 TIOError，2 TIllegalArgument
+	application bool  // 返回TApplicationException
+	hang        bool  // 读取请求后不响应，直到客户端关闭连接
+	message     string
+}
+
+// fakeScanner 假网关中打开的扫描器
+type fakeScanner struct {
+	rows    []string
+	columns []tstruct
+}
+
+// fakeTHBase 进程内的THBaseService实现，数据保存在内存中，只有一张表
+// 记录收到的每次调用，测试据此检查请求的字段编号和类型
+type fakeTHBase struct {
+	ln     net.Listener
+	framed bool
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	rows     map[string]map[string]string // 行键 -> "列族:列" -> 值
+	calls    []fakeCall
+	faults   map[string]fakeFault
+	scanners map[int32]*fakeScanner
+	nextID   int32
+	conns    int
+	conn     []net.Conn
+}
+
+// newFakeTHBase 启动假网关，测试结束时关闭
+func newFakeTHBase(t *testing.T, framed bool) *fakeTHBase {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	f := &fakeTHBase{
+		ln:       ln,
+		framed:   framed,
+		rows:     map[string]map[string]string{},
+		faults:   map[string]fakeFault{},
+		scanners: map[int32]*fakeScanner{},
+	}
+	f.wg.Add(1)
+	go f.accept()
+	t.Cleanup(f.close)
+	return f
+}
+
+// backend 创建连接到假网关的Thrift访问方式
+func (f *fakeTHBase) backend(t *testing.T) *thriftBackend {
+	host, port, _ := net.SplitHostPort(f.ln.Addr().String())
+	b := newThriftBackend(&config.HBaseConfig{
+		ThriftHost:     host,
+		ThriftPort:     port,
+		ThriftFramed:   f.framed,
+		ThriftTimeout:  5 * time.Second,
+		ThriftPoolSize: 2,
+	})
+	t.Cleanup(b.Close)
+	return b
+}
+
+// put 写入测试数据
+func (f *fakeTHBase) put(row, column, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rows[row] == nil {
+		f.rows[row] = map[string]string{}
+	}
+	f.rows[row][column] = value
+}
+
+// value 读取单元格的值
+func (f *fakeTHBase) value(row, column string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.rows[row][column]
+	return v, ok
+}
+
+// fault 设置方法的异常，传入零值时清除
+func (f *fakeTHBase) fault(method string, fault fakeFault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fault == (fakeFault{}) {
+		delete(f.faults, method)
+		return
+	}
+	f.faults[method] = fault
+}
+
+// callsOf 返回某个方法收到的调用
+func (f *fakeTHBase) callsOf(method string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeCall
+	for _, c := range f.calls {
+		if c.method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// connCount 返回已接受的连接数
+func (f *fakeTHBase) connCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+func (f *fakeTHBase) close() {
+	f.ln.Close()
+	f.mu.Lock()
+	for _, c := range f.conn {
+		c.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+func (f *fakeTHBase) accept() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		f.conn = append(f.conn, conn)
+		f.mu.Unlock()
+		f.wg.Add(1)
+		go f.serve(conn)
+	}
+}
+
+// serve 依次处理一条连接上的调用
+func (f *fakeTHBase) serve(conn net.Conn) {
+	defer f.wg.Done()
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	for {
+		r := newThriftReader(br, f.framed)
+		name, typ, seq := r.messageBegin()
+		args := readTStruct(r)
+		if r.err != nil || typ != thriftCall {
+			return
+		}
+		w := f.handle(name, seq, args)
+		if w == nil {
+			io.Copy(io.Discard, br)
+			return
+		}
+		if err := w.writeTo(conn, f.framed); err != nil {
+			return
+		}
+	}
+}
+
+// handle 执行一次调用并编码响应，需要不响应时返回nil
+func (f *fakeTHBase) handle(name string, seq int32, args tstruct) *thriftWriter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fakeCall{method: name, args: args})
+
+	w := &thriftWriter{}
+	fault, faulty := f.faults[name]
+	switch {
+	case faulty && fault.hang:
+		return nil
+	case faulty && fault.application:
+		w.messageBegin(name, thriftException, seq)
+		w.field(thriftString, 1)
+		w.str(fault.message)
+		w.field(thriftI32, 2)
+		w.i32(1)
+		w.stop()
+		return w
+	}
+
+	w.messageBegin(name, thriftReply, seq)
+	if faulty {
+		f.writeException(w, fault.exception, fault.message)
+		return w
+	}
+
+	switch name {
+	case "get":
+		tget, _ := args[2].val.(tstruct)
+		row := string(tget.bytes(1))
+		w.field(thriftStruct, 0)
+		f.writeResult(w, row, tget.structs(2))
+	case "put":
+		tput, _ := args[2].val.(tstruct)
+		row := string(tput.bytes(1))
+		if f.rows[row] == nil {
+			f.rows[row] = map[string]string{}
+		}
+		for _, cv := range tput.structs(2) {
+			f.rows[row][string(cv.bytes(1))+":"+string(cv.bytes(2))] = string(cv.bytes(3))
+		}
+	case "openScanner":
+		tscan, _ := args[2].val.(tstruct)
+		start, stop := string(tscan.bytes(1)), string(tscan.bytes(2))
+		s := &fakeScanner{columns: tscan.structs(3)}
+		for row := range f.rows {
+			if row >= start && (stop == "" || row < stop) {
+				s.rows = append(s.rows, row)
+			}
+		}
+		sort.Strings(s.rows)
+		f.nextID++
+		f.scanners[f.nextID] = s
+		w.field(thriftI32, 0)
+		w.i32(f.nextID)
+	case "getScannerRows":
+		id, _ := args[1].val.(int32)
+		numRows, _ := args[2].val.(int32)
+		s, ok := f.scanners[id]
+		if !ok {
+			f.writeException(w, 2, "Invalid scanner Id")
+			return w
+		}
+		n := min(int(numRows), len(s.rows))
+		w.field(thriftList, 0)
+		w.list(thriftStruct, n)
+		for _, row := range s.rows[:n] {
+			f.writeResult(w, row, s.columns)
+		}
+		s.rows = s.rows[n:]
+	case "closeScanner":
+		id, _ := args[1].val.(int32)
+		if _, ok := f.scanners[id]; !ok {
+			f.writeException(w, 2, "Invalid scanner Id")
+			return w
+		}
+		delete(f.scanners, id)
+	default:
+		w = &thriftWriter{}
+		w.messageBegin(name, thriftException, seq)
+		w.field(thriftString, 1)
+		w.str("Invalid method name: '" + name + "'")
+		w.field(thriftI32, 2)
+		w.i32(1)
+	}
+	w.stop()
+	return w
+}
+
+// writeException 写入返回结构体中的TIOError(1)或TIllegalArgument(2)字段
+func (f *fakeTHBase) writeException(w *thriftWriter, id int16, message string) {
+	w.field(thriftStruct, id)
+	w.field(thriftString, 1)
+	w.str(message)
+	w.stop()
+	w.stop()
+}
+
+// writeResult 按列过滤后写入一行TResult，行不存在时写入空的TResult
+func (f *fakeTHBase) writeResult(w *thriftWriter, row string, columns []tstruct) {
+	cells := f.rows[row]
+	var keys []string
+	for key := range cells {
+		family, qualifier, _ := strings.Cut(key, ":")
+		if matchColumns(columns, family, qualifier) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if cells != nil {
+		w.field(thriftString, 1)
+		w.str(row)
+	}
+	w.field(thriftList, 2)
+	w.list(thriftStruct, len(keys))
+	for _, key := range keys {
+		family, qualifier, _ := strings.Cut(key, ":")
+		w.field(thriftString, 1)
+		w.str(family)
+		w.field(thriftString, 2)
+		w.str(qualifier)
+		w.field(thriftString, 3)
+		w.str(cells[key])
+		w.field(thriftI64, 4)
+		w.i64(fakeTimestamp)
+		w.stop()
+	}
+	w.stop()
+}
+
+// matchColumns 判断单元格是否在请求的列中，未指定列时返回全部列
+func matchColumns(columns []tstruct, family, qualifier string) bool {
+	if len(columns) == 0 {
+		return true
+	}
+	for _, c := range columns {
+		if string(c.bytes(1)) != family {
+			continue
+		}
+		if _, ok := c[2]; !ok || string(c.bytes(2)) == qualifier {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+)
+
+// Thrift二进制协议的字段类型
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+// Thrift消息类型
+const (
+	thriftCall      byte = 1
+	thriftReply     byte = 2
+	thriftException byte = 3
+)
+
+const (
+	// thriftVersion1 严格模式的消息头版本号，低位为消息类型
+	thriftVersion1 uint32 = 0x80010000
+	// thriftMaxLength 单个字符串、容器或帧允许的最大长度，避免错误的数据导致分配过大的内存
+	thriftMaxLength = 64 << 20
+	// thriftMaxDepth 跳过未知字段时允许的最大嵌套深度
+	thriftMaxDepth = 64
+)
+
+// errThriftProtocol 响应不符合Thrift协议，连接不能再复用
+var errThriftProtocol = errors.New("thrift协议错误")
+
+// thriftWriter 按Thrift二进制协议编码一条消息
+type thriftWriter struct {
+	buf bytes.Buffer
+}
+
+// messageBegin 写入严格模式的消息头
+func (w *thriftWriter) messageBegin(name string, typ byte, seq int32) {
+	w.i32(int32(thriftVersion1 | uint32(typ)))
+	w.str(name)
+	w.i32(seq)
+}
+
+// field 写入字段头
+func (w *thriftWriter) field(typ byte, id int16) {
+	w.buf.WriteByte(typ)
+	w.i16(id)
+}
+
+// stop 写入结构体结束标记
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(thriftStop)
+}
+
+// list 写入列表头
+func (w *thriftWriter) list(elem byte, size int) {
+	w.buf.WriteByte(elem)
+	w.i32(int32(size))
+}
+
+func (w *thriftWriter) boolean(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *thriftWriter) i16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) i32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) i64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+func (w *thriftWriter) binary(v []byte) {
+	w.i32(int32(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *thriftWriter) str(v string) {
+	w.i32(int32(len(v)))
+	w.buf.WriteString(v)
+}
+
+// writeTo 把编码好的消息写入连接，framed为true时先写4字节的帧长度
+func (w *thriftWriter) writeTo(conn io.Writer, framed bool) error {
+	if framed {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(w.buf.Len()))
+		if _, err := conn.Write(size[:]); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(w.buf.Bytes())
+	return err
+}
+
+// thriftReader 按Thrift二进制协议解码消息
+// 出现第一个错误后后续读取都返回零值，调用方在合适的位置检查err即可
+type thriftReader struct {
+	r   io.Reader
+	err error
+}
+
+// newThriftReader 创建解码器，framed为true时先读取完整的一帧
+func newThriftReader(conn *bufio.Reader, framed bool) *thriftReader {
+	if !framed {
+		return &thriftReader{r: conn}
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return &thriftReader{err: err}
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > thriftMaxLength {
+		return &thriftReader{err: fmt.Errorf("%w: 帧长度 %d 超过上限", errThriftProtocol, n)}
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(conn, frame); err != nil {
+		return &thriftReader{err: err}
+	}
+	return &thriftReader{r: bytes.NewReader(frame)}
+}
+
+// fail 记录第一个错误
+func (r *thriftReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// read 读取定长的字节
+func (r *thriftReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.fail(err)
+		return nil
+	}
+	return b
+}
+
+// messageBegin 读取严格模式的消息头
+func (r *thriftReader) messageBegin() (name string, typ byte, seq int32) {
+	version := uint32(r.i32())
+	if r.err != nil {
+		return "", 0, 0
+	}
+	if version&0xffff0000 != thriftVersion1 {
+		r.fail(fmt.Errorf("%w: 不支持的消息头 %#x，请确认网关使用二进制协议", errThriftProtocol, version))
+		return "", 0, 0
+	}
+	name = r.str()
+	seq = r.i32()
+	return name, byte(version & 0xff), seq
+}
+
+// field 读取字段头，遇到结束标记时typ为thriftStop
+func (r *thriftReader) field() (typ byte, id int16) {
+	typ = r.byte()
+	if typ == thriftStop || r.err != nil {
+		return thriftStop, 0
+	}
+	return typ, r.i16()
+}
+
+// list 读取列表或集合头
+func (r *thriftReader) list() (elem byte, size int) {
+	elem = r.byte()
+	size = r.length()
+	return elem, size
+}
+
+// length 读取长度并检查上限
+func (r *thriftReader) length() int {
+	n := r.i32()
+	if n < 0 || n > thriftMaxLength {
+		r.fail(fmt.Errorf("%w: 长度 %d 无效", errThriftProtocol, n))
+		return 0
+	}
+	return int(n)
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *thriftReader) boolean() bool {
+	return r.byte() != 0
+}
+
+func (r *thriftReader) i16() int16 {
+	b := r.read(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (r *thriftReader) i32() int32 {
+	b := r.read(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *thriftReader) i64() int64 {
+	b := r.read(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (r *thriftReader) binary() []byte {
+	n := r.length()
+	if r.err != nil {
+		return nil
+	}
+	return r.read(n)
+}
+
+func (r *thriftReader) str() string {
+	return string(r.binary())
+}
+
+// skip 跳过不关心的字段
+func (r *thriftReader) skip(typ byte) {
+	r.skipDepth(typ, 0)
+}
+
+func (r *thriftReader) skipDepth(typ byte, depth int) {
+	if depth > thriftMaxDepth {
+		r.fail(fmt.Errorf("%w: 嵌套过深", errThriftProtocol))
+		return
+	}
+	switch typ {
+	case thriftBool, thriftByte:
+		r.read(1)
+	case thriftI16:
+		r.read(2)
+	case thriftI32:
+		r.read(4)
+	case thriftI64, thriftDouble:
+		r.read(8)
+	case thriftString:
+		r.binary()
+	case thriftStruct:
+		for r.err == nil {
+			ft, _ := r.field()
+			if ft == thriftStop {
+				return
+			}
+			r.skipDepth(ft, depth+1)
+		}
+	case thriftMap:
+		kt, vt := r.byte(), r.byte()
+		n := r.length()
+		for i := 0; i < n && r.err == nil; i++ {
+			r.skipDepth(kt, depth+1)
+			r.skipDepth(vt, depth+1)
+		}
+	case thriftSet, thriftList:
+		et, n := r.list()
+		for i := 0; i < n && r.err == nil; i++ {
+			r.skipDepth(et, depth+1)
+		}
+	default:
+		r.fail(fmt.Errorf("%w: 未知的字段类型 %d", errThriftProtocol, typ))
+	}
+}
+
+// thriftConn 一条到Thrift网关的连接
+type thriftConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int32
+}
+
+// thriftApplicationError 网关返回的TApplicationException，如方法不存在
+type thriftApplicationError struct {
+	Message string
+	Type    int32
+}
+
+func (e *thriftApplicationError) Error() string {
+	return fmt.Sprintf("thrift应用异常(类型 %d): %s", e.Type, e.Message)
+}
+
+// readApplicationError 解码TApplicationException
+func readApplicationError(r *thriftReader) error {
+	appErr := &thriftApplicationError{}
+	for r.err == nil {
+		typ, id := r.field()
+		if typ == thriftStop {
+			break
+		}
+		switch {
+		case id == 1 && typ == thriftString:
+			appErr.Message = r.str()
+		case id == 2 && typ == thriftI32:
+			appErr.Type = r.i32()
+		default:
+			r.skip(typ)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return appErr
+}
+
+// toThriftI32 把无符号数转换为i32字段的值，超出范围时取最大值
+func toThriftI32(v uint32) int32 {
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(v)
+}
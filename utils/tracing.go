@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"gohbase/config"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 本服务使用的Tracer名称
+const tracerName = "gohbase"
+
+// Tracer 获取全局Tracer，未初始化追踪时为no-op实现
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// InitTracing 根据配置初始化链路追踪，返回用于刷新并关闭导出器的函数
+func InitTracing(conf *config.TracingConfig) (func(context.Context) error, error) {
+	// 无论是否导出，都注册传播器以透传上游的追踪上下文
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+
+	switch strings.ToLower(conf.Exporter) {
+	case "", "none":
+		logrus.Info("链路追踪未启用")
+		return noop, nil
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(conf.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case "file":
+		file, err = os.OpenFile(conf.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	default:
+		return noop, fmt.Errorf("未知的链路追踪导出方式: %s", conf.Exporter)
+	}
+	if err != nil {
+		return noop, fmt.Errorf("创建链路追踪导出器失败: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(conf.ServiceName),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("创建链路追踪资源失败: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	logrus.Infof("链路追踪已启用 [导出方式: %s]", conf.Exporter)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}, nil
+}
+
+// StartSpan 启动一个内部Span
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordSpanError 在Span上记录错误
+func RecordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// DetachedContext 返回保留追踪上下文但不会随原请求取消的context，用于后台任务
+func DetachedContext(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 缓存预热的状态
+const (
+	WarmupDisabled = "disabled"
+	WarmupRunning  = "running"
+	WarmupDone     = "done"
+)
+
+// WarmupFunc 执行一次缓存预热，返回加载成功和失败的数量
+type WarmupFunc func(ctx context.Context) (loaded, failed int)
+
+// WarmupStatus 缓存预热的进度
+type WarmupStatus struct {
+	State      string    `json:"state"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+	Loaded     int       `json:"loaded"`
+	Failed     int       `json:"failed"`
+	TimedOut   bool      `json:"timedOut,omitempty"`
+}
+
+// WarmupTracker 跟踪启动时的缓存预热，预热进行中时就绪检查不通过
+type WarmupTracker struct {
+	mu     sync.Mutex
+	status WarmupStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// CacheWarmup 全局缓存预热状态
+var CacheWarmup = &WarmupTracker{status: WarmupStatus{State: WarmupDisabled}}
+
+// Start 在后台执行预热，最长执行timeout，只能调用一次
+// 调用返回前状态已变为running，因此在启动HTTP服务之前调用可以保证预热完成前不会就绪
+func (w *WarmupTracker) Start(timeout time.Duration, fn WarmupFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	w.mu.Lock()
+	w.status = WarmupStatus{State: WarmupRunning, StartedAt: time.Now()}
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	w.mu.Unlock()
+
+	go func() {
+		defer close(w.done)
+		defer cancel()
+
+		logrus.Info("开始预热缓存...")
+		loaded, failed := fn(ctx)
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+		w.mu.Lock()
+		w.status.State = WarmupDone
+		w.status.FinishedAt = time.Now()
+		w.status.Loaded, w.status.Failed, w.status.TimedOut = loaded, failed, timedOut
+		elapsed := w.status.FinishedAt.Sub(w.status.StartedAt)
+		w.mu.Unlock()
+
+		if timedOut {
+			logrus.Warnf("缓存预热超时，已加载 %d 项，失败 %d 项 [耗时: %v]", loaded, failed, elapsed)
+			return
+		}
+		logrus.Infof("缓存预热完成，已加载 %d 项，失败 %d 项 [耗时: %v]", loaded, failed, elapsed)
+	}()
+}
+
+// Status 获取预热进度
+func (w *WarmupTracker) Status() WarmupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Stop 取消尚未完成的预热并等待其退出，作为生命周期组件在关闭时调用，ctx截止时预热仍未退出则记为丢弃
+func (w *WarmupTracker) Stop(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.mu.Unlock()
+	if cancel == nil {
+		return 0, nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return 0, nil
+	case <-ctx.Done():
+		return 1, nil
+	}
+}
@@ -3,12 +3,14 @@ package utils
 import (
 	"context"
 	"fmt"
+	"gohbase/config"
 	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/tsuna/gohbase/hrpc"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // WriteManager 写入管理器
@@ -19,6 +21,9 @@ type WriteManager struct {
 	mu             sync.Mutex
 	writeStats     map[string]int // 记录每个电影ID的写入次数
 	writeStatsTime time.Time      // 统计开始时间
+	queue          chan task      // 当前的写入任务队列
+	done           chan struct{}  // 写入协程和工作协程全部退出后关闭
+	closed         bool           // 服务关闭后不再接受启动请求
 }
 
 // 全局写入管理器
@@ -30,23 +35,29 @@ var WriteManagerInstance = &WriteManager{
 	writeStatsTime: time.Now(),
 }
 
-// StartRandomWrites 开始随机写入操作
-func (wm *WriteManager) StartRandomWrites() {
+// StartRandomWrites 开始随机写入操作，服务关闭期间返回ErrShuttingDown
+func (wm *WriteManager) StartRandomWrites() error {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
+	if wm.closed {
+		return ErrShuttingDown
+	}
 	if wm.isRunning {
-		return
+		return nil
 	}
 
 	wm.isRunning = true
 	wm.stopChan = make(chan struct{})
+	wm.queue = make(chan task, config.Current().WriteGenerator.QueueSize)
+	wm.done = make(chan struct{})
 	wm.resetStats()
 
 	// 开启协程进行异步写入
-	go wm.writeRoutine()
+	go wm.writeRoutine(wm.stopChan, wm.queue, wm.done)
 
 	logrus.Info("随机写入服务已启动")
+	return nil
 }
 
 // StopRandomWrites 停止随机写入操作
@@ -64,6 +75,30 @@ func (wm *WriteManager) StopRandomWrites() {
 	logrus.Info("随机写入服务已停止")
 }
 
+// Shutdown 停止产生新的写入任务，并等待队列中的任务写完或ctx截止
+// 返回截止时仍未写入的任务数
+func (wm *WriteManager) Shutdown(ctx context.Context) (int, error) {
+	wm.mu.Lock()
+	wm.closed = true
+	if wm.isRunning {
+		close(wm.stopChan)
+		wm.isRunning = false
+	}
+	queue, done := wm.queue, wm.done
+	wm.mu.Unlock()
+
+	if done == nil {
+		return 0, nil
+	}
+
+	select {
+	case <-done:
+		return 0, nil
+	case <-ctx.Done():
+		return len(queue), nil
+	}
+}
+
 // IsRunning 检查写入服务是否正在运行
 func (wm *WriteManager) IsRunning() bool {
 	wm.mu.Lock()
@@ -71,6 +106,21 @@ func (wm *WriteManager) IsRunning() bool {
 	return wm.isRunning
 }
 
+// Status 获取写入服务的运行状态和队列使用情况
+func (wm *WriteManager) Status() map[string]interface{} {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	status := map[string]interface{}{
+		"running": wm.isRunning,
+	}
+	if wm.isRunning && wm.queue != nil {
+		status["queueDepth"] = len(wm.queue)
+		status["queueCapacity"] = cap(wm.queue)
+	}
+	return status
+}
+
 // GetLogs 获取写入日志
 func (wm *WriteManager) GetLogs() []map[string]interface{} {
 	wm.mu.Lock()
@@ -136,34 +186,48 @@ func (wm *WriteManager) resetStats() {
 	wm.writeLogs = make([]map[string]interface{}, 0, 100)
 }
 
-// 写入协程
-func (wm *WriteManager) writeRoutine() {
-	// 创建工作池，使用5个协程处理写入操作
-	workerCount := 5
-	taskChan := make(chan task, 20)
+// 写入协程，停止时关闭任务队列，等待工作协程写完队列中的任务后关闭done
+func (wm *WriteManager) writeRoutine(stopChan <-chan struct{}, taskChan chan task, done chan<- struct{}) {
+	// 创建工作池处理写入操作
+	conf := config.Current().WriteGenerator
+	var wg sync.WaitGroup
 
 	// 启动工作协程
-	for i := 0; i < workerCount; i++ {
-		go worker(taskChan)
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go worker(taskChan, &wg)
 	}
 
-	// 每3秒执行一批写入
-	ticker := time.NewTicker(3 * time.Second)
+	defer func() {
+		close(taskChan)
+		wg.Wait()
+		close(done)
+	}()
+
+	// 按配置的间隔执行一批写入，间隔在配置重新加载后生效
+	interval := conf.Interval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-wm.stopChan:
+		case <-stopChan:
 			return
 		case <-ticker.C:
-			// 生成1-5个随机写入任务
-			taskCount := rand.Intn(5) + 1
+			conf := config.Current().WriteGenerator
+			if conf.Interval != interval {
+				interval = conf.Interval
+				ticker.Reset(interval)
+			}
+
+			// 生成随机数量的写入任务
+			taskCount := conf.MinBatch + rand.Intn(conf.MaxBatch-conf.MinBatch+1)
 
 			for i := 0; i < taskCount; i++ {
-				// 随机选择1-100之间的电影ID
-				movieID := fmt.Sprintf("%d", rand.Intn(100)+1)
-				// 随机用户ID（1-1000）
-				userID := fmt.Sprintf("%d", rand.Intn(1000)+1)
+				// 随机选择电影ID
+				movieID := fmt.Sprintf("%d", rand.Intn(conf.MaxMovieID)+1)
+				// 随机用户ID
+				userID := fmt.Sprintf("%d", rand.Intn(conf.MaxUserID)+1)
 				// 随机评分（1-5，支持0.5分）
 				rating := float64(rand.Intn(10)+1) / 2.0
 
@@ -197,9 +261,11 @@ func (wm *WriteManager) writeRoutine() {
 				select {
 				case taskChan <- t:
 					// 任务成功提交
+					writeManagerQueueDepth.Set(float64(len(taskChan)))
 				default:
 					// 通道已满，记录错误
 					logrus.Warn("写入队列已满，丢弃任务")
+					writeManagerDropped.Inc()
 					wm.mu.Lock()
 					logEntry["status"] = "failed"
 					logEntry["error"] = "写入队列已满"
@@ -218,9 +284,13 @@ type task struct {
 	logEntry map[string]interface{}
 }
 
-// 工作协程
-func worker(taskChan <-chan task) {
+// 工作协程，任务队列关闭且为空时退出
+func worker(taskChan <-chan task, wg *sync.WaitGroup) {
+	defer wg.Done()
+
 	for t := range taskChan {
+		writeManagerQueueDepth.Set(float64(len(taskChan)))
+
 		// 执行写入操作
 		err := writeRating(t.movieID, t.userID, t.rating)
 
@@ -230,8 +300,10 @@ func worker(taskChan <-chan task) {
 			t.logEntry["status"] = "failed"
 			t.logEntry["error"] = err.Error()
 			logrus.Errorf("写入评分失败: %v", err)
+			writeManagerWrites.WithLabelValues("failed").Inc()
 		} else {
 			t.logEntry["status"] = "success"
+			writeManagerWrites.WithLabelValues("success").Inc()
 		}
 		WriteManagerInstance.mu.Unlock()
 
@@ -242,7 +314,10 @@ func worker(taskChan <-chan task) {
 
 // writeRating 写入评分数据到HBase
 func writeRating(movieID, userID string, rating float64) error {
-	ctx := context.Background()
+	ctx, span := StartSpan(context.Background(), "writeManager.writeRating",
+		attribute.String("movie.id", movieID), attribute.String("user.id", userID))
+	defer span.End()
+
 	timestamp := time.Now().UnixNano() / 1000000 // 转为毫秒
 
 	// 1. 写入ratings表（userId_movieId格式）
@@ -254,12 +329,12 @@ func writeRating(movieID, userID string, rating float64) error {
 		},
 	}
 
-	putRequest, err := hrpc.NewPutStr(ctx, "ratings", ratingKey, values)
+	putRequest, err := hrpc.NewPutStr(ctx, WriteTables().Ratings, ratingKey, values)
 	if err != nil {
 		return fmt.Errorf("创建ratings表Put请求失败: %v", err)
 	}
 
-	_, err = hbaseClient.Put(putRequest)
+	_, err = HBasePut(putRequest)
 	if err != nil {
 		return fmt.Errorf("ratings表写入失败: %v", err)
 	}
@@ -273,12 +348,12 @@ func writeRating(movieID, userID string, rating float64) error {
 		},
 	}
 
-	putRequest, err = hrpc.NewPutStr(ctx, "movie_ratings", movieRatingKey, values)
+	putRequest, err = hrpc.NewPutStr(ctx, WriteTables().MovieRatings, movieRatingKey, values)
 	if err != nil {
 		return fmt.Errorf("创建movie_ratings表Put请求失败: %v", err)
 	}
 
-	_, err = hbaseClient.Put(putRequest)
+	_, err = HBasePut(putRequest)
 	if err != nil {
 		return fmt.Errorf("movie_ratings表写入失败: %v", err)
 	}